@@ -0,0 +1,45 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/phntom/goalert/internal/config"
+	"gopkg.in/yaml.v3"
+	"io"
+	"os"
+)
+
+// configCommand validates the configuration the bot would start with and prints it with secrets masked, noting
+// which languages are incomplete.
+//
+//	goalert-bot config check [-file config.yaml]
+func configCommand(args []string) int {
+	if len(args) == 0 || args[0] != "check" {
+		fmt.Fprintln(os.Stderr, "usage: goalert-bot config check [-file path]")
+		return 2
+	}
+	flags := flag.NewFlagSet("config check", flag.ExitOnError)
+	file := flags.String("file", os.Getenv("CONFIG_FILE"), "configuration file, defaults to $CONFIG_FILE")
+	//goland:noinspection GoUnhandledErrorResult
+	flags.Parse(args[1:]) //nolint:errcheck
+	return checkConfig(*file, os.Stdout, os.Stderr)
+}
+
+func checkConfig(path string, stdout io.Writer, stderr io.Writer) int {
+	settings, err := config.Load(path)
+	if err != nil {
+		fmt.Fprintf(stderr, "invalid configuration:\n%v\n", err)
+		return 1
+	}
+	encoder := yaml.NewEncoder(stdout)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(settings.Redacted()); err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	for _, line := range languageReport() {
+		fmt.Fprintln(stderr, "note:", line)
+	}
+	fmt.Fprintln(stderr, "configuration is valid")
+	return 0
+}
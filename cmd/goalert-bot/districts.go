@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"github.com/phntom/goalert/internal/district"
+	"io"
+	"net/http"
+	"os"
+	"time"
+)
+
+// districtsCommand refreshes the embedded district files from oref's city lists, and their shapes from a
+// districts.geojson when -geometry names where to find one.
+//
+//	goalert-bot districts update [-source url-or-dir] [-geometry url-or-dir] [-dir internal/district] [-report report.md]
+func districtsCommand(args []string) int {
+	if len(args) == 0 || args[0] != "update" {
+		fmt.Fprintln(os.Stderr, "usage: goalert-bot districts update [-source url-or-dir] [-geometry url-or-dir] [-dir path] [-report path]")
+		return 2
+	}
+	flags := flag.NewFlagSet("districts update", flag.ExitOnError)
+	source := flags.String("source", district.UpstreamURL, "URL or directory with oref's cities_*.json files")
+	geometry := flags.String("geometry", "", "URL or directory with a districts.geojson to embed")
+	dir := flags.String("dir", "internal/district", "directory of the districts.*.json files to update")
+	reportPath := flags.String("report", "", "write the report to this file instead of stdout")
+	//goland:noinspection GoUnhandledErrorResult
+	flags.Parse(args[1:]) //nolint:errcheck
+
+	report := io.Writer(os.Stdout)
+	if *reportPath != "" {
+		file, err := os.Create(*reportPath)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		//goland:noinspection GoUnhandledErrorResult
+		defer file.Close() //nolint:errcheck
+		report = file
+	}
+	return updateDistricts(*source, *geometry, *dir, report, os.Stderr)
+}
+
+func updateDistricts(source string, geometry string, dir string, report io.Writer, stderr io.Writer) int {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	client := &http.Client{Timeout: 30 * time.Second}
+	upstream, err := district.FetchUpstream(ctx, client, source)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	reports, err := district.UpdateFiles(dir, upstream)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	if err := district.WriteReport(report, reports); err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	if geometry == "" {
+		return 0
+	}
+	shapes, err := district.UpdateGeometry(ctx, client, geometry, dir)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	if err := district.WriteGeometryReport(report, shapes); err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"github.com/mattermost/mattermost/server/public/shared/mlog"
+	"github.com/phntom/goalert/internal/config"
+	"github.com/phntom/goalert/internal/district"
+	"slices"
+	"strings"
+)
+
+// languageReport describes what each language lacks and what stands in for it, one line per finding. Gaps are
+// expected for languages without upstream district names, so none of them stops the bot.
+func languageReport() []string {
+	var report []string
+	missing := config.MissingTranslations()
+	coverage := district.GetCoverage()
+	for _, lang := range config.Languages {
+		fallbacks := lang.Fallbacks()
+		if lang != "en" && !slices.Contains(fallbacks, "en") {
+			fallbacks = append(fallbacks, "en")
+		}
+		if keys := missing[lang]; len(keys) > 0 {
+			report = append(report, fmt.Sprintf("%s: locale.%s.yaml lacks %s, shown in %s",
+				lang, lang, strings.Join(keys, ", "), joinLanguages(fallbacks)))
+		}
+		c := coverage[lang]
+		if len(c.Fallback) > 0 {
+			report = append(report, fmt.Sprintf("%s: %d districts named in %s", lang, len(c.Fallback), joinLanguages(lang.Fallbacks())))
+		}
+		if len(c.Transliterated) > 0 {
+			report = append(report, fmt.Sprintf("%s: %d districts transliterated from Hebrew: %s", lang, len(c.Transliterated), joinIDs(c.Transliterated)))
+		}
+	}
+	return report
+}
+
+func logLanguageReport() {
+	for _, line := range languageReport() {
+		mlog.Warn("Incomplete language", mlog.String("report", line))
+	}
+}
+
+func joinLanguages(languages []config.Language) string {
+	names := make([]string, 0, len(languages))
+	for _, lang := range languages {
+		names = append(names, string(lang))
+	}
+	return strings.Join(names, ", ")
+}
+
+// joinIDs lists the first few ids, the count is in the report anyway.
+func joinIDs(ids []district.ID) string {
+	const shown = 10
+	names := make([]string, 0, shown+1)
+	for _, id := range ids[:min(len(ids), shown)] {
+		names = append(names, string(id))
+	}
+	if len(ids) > shown {
+		names = append(names, fmt.Sprintf("and %d more", len(ids)-shown))
+	}
+	return strings.Join(names, ", ")
+}
@@ -1,39 +1,139 @@
 package main
 
 import (
+	"github.com/mattermost/mattermost/server/public/shared/mlog"
 	"github.com/phntom/goalert/internal/bot"
+	"github.com/phntom/goalert/internal/config"
+	"github.com/phntom/goalert/internal/leader"
+	"github.com/phntom/goalert/internal/recording"
+	"github.com/phntom/goalert/internal/sinks"
 	"github.com/phntom/goalert/internal/sources"
+	"github.com/phntom/goalert/internal/storage"
+	"net/http"
 	"os"
+	"strconv"
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "replay" {
+		os.Exit(replay(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "config" {
+		os.Exit(configCommand(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "districts" {
+		os.Exit(districtsCommand(os.Args[2:]))
+	}
+	settings, err := config.Load(os.Getenv("CONFIG_FILE"))
+	if err != nil {
+		mlog.Error("invalid configuration", mlog.Err(err))
+		os.Exit(3)
+	}
 	b := bot.Bot{}
+	b.SetConfig(settings)
+	// a broken external source leaves the embedded districts in place
+	//goland:noinspection GoUnhandledErrorResult
+	b.ReloadDistricts() //nolint:errcheck
+	logLanguageReport()
 	b.Register()
+	http.HandleFunc("/healthz", b.ServeHealthz)
+	http.HandleFunc("/readyz", b.ServeReadyz)
 	b.Connect()
 	b.FindBotChannel()
+	switch election := settings.LeaderElection; election.Mode {
+	case "kubernetes":
+		store, err := leader.NewKubernetesStore(election.Namespace, election.LeaseName)
+		if err != nil {
+			mlog.Error("failed configuring leader election", mlog.Err(err))
+			os.Exit(5)
+		}
+		b.ElectLeader(store, replicaName())
+	case "file":
+		b.ElectLeader(&leader.FileStore{Path: election.LockPath}, replicaName())
+	}
+	http.HandleFunc("/command", b.ServeCommand)
+	go b.Listen()
+	if webhook := settings.Sinks.Webhook; len(webhook.URLs) > 0 {
+		b.AddSink(sinks.NewWebhook(webhook.URLs, webhook.Secret, &b.Monitoring))
+	}
+	capFeed := sinks.NewCAPFeed(settings.Sinks.CAP.Sender)
+	b.AddSink(capFeed)
+	http.Handle("/cap/", capFeed)
+	stream := sinks.NewStream()
+	b.AddSink(stream)
+	http.Handle("/stream", stream)
+	if path := settings.Sinks.History.Path; path != "" {
+		db, err := storage.Open(path)
+		if err != nil {
+			mlog.Error("failed opening history database", mlog.Err(err), mlog.Any("path", path))
+			os.Exit(5)
+		}
+		b.State = db
+		b.RestoreState()
+		historySink := &sinks.History{DB: db, Retention: settings.Sinks.History.Retention}
+		b.AddSink(historySink)
+		if historySink.Retention > 0 {
+			go historySink.RunRetention()
+		}
+		history := storage.NewHistoryHandler(db)
+		http.Handle("/history", history)
+		http.Handle("/history/", history)
+	}
+	b.SyncUserSubscriptions()
 	go b.Cleanup()
+	if path := os.Getenv("CONFIG_FILE"); path != "" {
+		go b.WatchConfig(path)
+	}
+	go b.WatchDistricts()
+
+	var recorder *recording.Recorder
+	if record := settings.Sinks.Record; record.Path != "" {
+		recorder, err = recording.NewRecorder(record.Path, int64(record.MaxMB)<<20, record.Backups)
+		if err != nil {
+			mlog.Error("failed opening payload recording", mlog.Err(err), mlog.Any("path", record.Path))
+			os.Exit(5)
+		}
+	}
 
 	ynet := sources.SourceYnet{
-		URL: sources.YnetURL,
-		Bot: &b,
+		Bot:      &b,
+		Recorder: recorder,
 	}
 	ynet.Register()
-	if os.Getenv("DISABLE_YNET") != "1" {
+	if settings.Sources.Ynet.Enabled {
+		b.Health.Register("ynet")
 		go ynet.Run()
 	}
 	oref := sources.SourceOref{
-		Bot: &b,
+		Bot:      &b,
+		Recorder: recorder,
 	}
 	oref.Register()
-	if os.Getenv("DISABLE_OREF") != "1" {
+	if settings.Sources.Oref.Enabled {
+		b.Health.Register("oref")
 		go oref.Run()
 	}
 	telegram := sources.SourceTelegram{
-		Bot: &b,
+		Bot:      &b,
+		Recorder: recorder,
 	}
 	telegram.Register()
-	if os.Getenv("DISABLE_TELEGRAM") != "1" {
+	if settings.Sources.Telegram.Enabled {
+		b.Health.Register("telegram")
+		b.AddReadinessCheck("telegram", telegram.Authorized)
 		go telegram.Run()
 	}
 	b.AwaitMessage()
 }
+
+// replicaName identifies this replica in leader election, the pod name in Kubernetes.
+func replicaName() string {
+	if name := os.Getenv("POD_NAME"); name != "" {
+		return name
+	}
+	name, err := os.Hostname()
+	if err != nil {
+		return strconv.Itoa(os.Getpid())
+	}
+	return name
+}
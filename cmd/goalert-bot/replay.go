@@ -0,0 +1,47 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/phntom/goalert/internal/bot"
+	"github.com/phntom/goalert/internal/recording"
+	"github.com/phntom/goalert/internal/sinks"
+	"github.com/phntom/goalert/internal/sources"
+	"os"
+	"time"
+)
+
+// replay drives a recording through the sources and the bot without connecting to Mattermost,
+// printing every post and patch to stdout.
+//
+//	goalert-bot replay [-speed 10] recording.jsonl
+func replay(args []string) int {
+	flags := flag.NewFlagSet("replay", flag.ExitOnError)
+	speed := flags.Float64("speed", 1, "playback speed relative to the recording, 0 replays without pauses")
+	settle := flags.Duration("settle", 2*time.Second, "time to wait for pending patches after the last entry")
+	//goland:noinspection GoUnhandledErrorResult
+	flags.Parse(args) //nolint:errcheck
+	if flags.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "usage: goalert-bot replay [-speed n] [-settle duration] <file>")
+		return 2
+	}
+	f, err := os.Open(flags.Arg(0))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	//goland:noinspection GoUnhandledErrorResult
+	defer f.Close()
+
+	b := bot.Bot{}
+	b.Prepare()
+	b.AddSink(&sinks.Printer{W: os.Stdout})
+	go b.Cleanup()
+	go b.AwaitMessage()
+	if err := sources.Replay(&b, recording.NewReader(f), *speed); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	time.Sleep(*settle)
+	return 0
+}
@@ -7,39 +7,79 @@ import (
 	"github.com/mattermost/mattermost/server/public/shared/mlog"
 	"github.com/phntom/goalert/internal/config"
 	"github.com/phntom/goalert/internal/district"
+	"github.com/phntom/goalert/internal/health"
+	"github.com/phntom/goalert/internal/leader"
 	"github.com/phntom/goalert/internal/monitoring"
 	"os"
 	"os/signal"
-	"slices"
 	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
 )
 
 type Bot struct {
-	IsOnline        bool
-	Client          *model.Client4
-	webSocketClient *model.WebSocketClient
-	serverVersion   string
-	username        string
-	userId          string
-	Channels        []*model.Channel
-	ConfigChannel   *model.Channel
-	alertFeed       chan *Message
-	dedup           map[district.ID]*Message
-	dedupMutex      sync.Mutex
-	Monitoring      monitoring.Monitoring
+	IsOnline           bool
+	Client             *model.Client4
+	webSocketClient    *model.WebSocketClient
+	serverVersion      string
+	username           string
+	userId             string
+	Channels           []*model.Channel
+	ConfigChannel      *model.Channel
+	channelsMutex      sync.RWMutex
+	alertFeed          chan *Message
+	dedup              map[district.ID]*Message
+	dedupMutex         sync.Mutex
+	Monitoring         monitoring.Monitoring
+	Sinks              []AlertSink
+	subscriptions      map[string]*Subscription
+	subscriptionsMutex sync.RWMutex
+	users              map[string]*UserSubscription
+	usersMutex         sync.Mutex
+	usersPublishMutex  sync.Mutex
+	State              StateStore
+	lastSnapshot       []byte
+	Health             *health.Tracker
+	OpsChannel         *model.Channel
+	readinessChecks    map[string]func() error
+	readinessMutex     sync.Mutex
+	awaiting           atomic.Bool
+	submitWaitingSince atomic.Int64
+	lastCleanup        atomic.Int64
+	Leader             *leader.Elector
+	settings           atomic.Pointer[config.Config]
+	// unrecognized holds when each city name no district matched was last reported to the ops channel
+	unrecognized      map[string]time.Time
+	unrecognizedMutex sync.Mutex
 }
 
 const postTimeout = 10 * time.Second
 
+// defaultConfig stands in for the configuration when the bot runs without one, e.g. in replays and tests.
+var defaultConfig = config.Default()
+
+// Settings returns the bot's current configuration, callers should not hold on to it as it may be reloaded.
+func (b *Bot) Settings() *config.Config {
+	if settings := b.settings.Load(); settings != nil {
+		return settings
+	}
+	return defaultConfig
+}
+
+// SetConfig replaces the bot's configuration.
+func (b *Bot) SetConfig(settings *config.Config) {
+	b.settings.Store(settings)
+}
+
 func (b *Bot) Register() {
-	b.alertFeed = make(chan *Message)
-	b.dedup = make(map[district.ID]*Message)
+	b.Prepare()
 	c := make(chan os.Signal, 1)
-	signal.Notify(c, os.Interrupt)
+	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
 	go func() {
 		for range c {
 			mlog.Info("Exiting")
+			b.releaseLeadership()
 			b.Disconnect()
 			os.Exit(0)
 		}
@@ -47,10 +87,22 @@ func (b *Bot) Register() {
 	b.Monitoring.Setup()
 }
 
+// Prepare sets up the alert feed and the state AwaitMessage works on. Unlike Register it neither handles signals nor
+// serves metrics, so a replay can run on the same host as the bot.
+func (b *Bot) Prepare() {
+	b.alertFeed = make(chan *Message)
+	b.dedup = make(map[district.ID]*Message)
+	b.subscriptions = make(map[string]*Subscription)
+	b.users = make(map[string]*UserSubscription)
+	b.Health = health.NewTracker()
+	b.Health.OnChange = b.sourceHealthChanged
+}
+
 func (b *Bot) Connect() {
-	b.Client = model.NewAPIv4Client(os.Getenv("CHAT_DOMAIN"))
+	b.Client = model.NewAPIv4Client(b.Settings().Mattermost.Domain)
 	b.MakeSureServerIsRunning()
 	b.LoginAsTheBotUser()
+	b.AddSink(&MattermostSink{Bot: b})
 	b.IsOnline = true
 	mlog.Info("Connected")
 }
@@ -74,7 +126,7 @@ func (b *Bot) MakeSureServerIsRunning() {
 }
 
 func (b *Bot) LoginAsTheBotUser() {
-	b.Client.SetToken(os.Getenv("AUTH_TOKEN"))
+	b.Client.SetToken(b.Settings().Mattermost.AuthToken)
 	user, _, err := b.Client.GetMe(context.Background(), "")
 	if err != nil {
 		mlog.Error("There was a problem logging into the Mattermost server", mlog.Err(err))
@@ -86,65 +138,43 @@ func (b *Bot) LoginAsTheBotUser() {
 }
 
 func (b *Bot) FindBotChannel() {
-	teams, _, err := b.Client.GetTeamsForUser(context.Background(), b.userId, "")
-	if err != nil {
-		mlog.Error("There was a problem fetching active teams for bot", mlog.Err(err))
+	if err := b.RefreshChannels(); err != nil {
+		mlog.Error("There was a problem fetching the channels of the bot", mlog.Err(err))
 		os.Exit(1)
 	}
-	for _, team := range teams {
-		if team == nil {
-			continue
-		}
-		channels, _, err := b.Client.GetChannelsForTeamForUser(context.Background(), team.Id, b.userId, false, "")
-		if err != nil {
-			mlog.Error("There was a problem fetching channels for team", mlog.Any("teamId", team.Id), mlog.Err(err))
-			os.Exit(1)
-		}
-		for _, channel := range channels {
-			if channel == nil {
-				continue
-			}
-			if channel.IsGroupOrDirect() {
-				continue
-			}
-			if channel.Name == "off-topic" || channel.Name == "town-square" {
-				continue
-			}
-			if team.Name == "phantom" && channel.Name == "config" {
-				b.ConfigChannel = channel
-				continue
-			}
-			channel.AddProp("teamName", team.Name)
-			b.Channels = append(b.Channels, channel)
-		}
-	}
-	if b.Channels == nil || len(b.Channels) == 0 {
+	channels := b.AlertChannels()
+	if len(channels) == 0 {
 		mlog.Fatal("Bot is not a member of any channel, invite him and try again")
 		os.Exit(2)
 	}
 	var channelNames []string
-	for _, channel := range b.Channels {
+	for _, channel := range channels {
 		channelNames = append(channelNames, fmt.Sprintf("%s/%s", channel.Props["teamName"], channel.Name))
 	}
 	mlog.Info("Joined channels", mlog.Any("channels", channelNames))
 }
 
 func (b *Bot) SubmitMessage(m *Message) {
+	// lets /healthz notice when AwaitMessage stopped taking messages
+	b.submitWaitingSince.CompareAndSwap(0, time.Now().UnixNano())
 	b.alertFeed <- m
+	b.submitWaitingSince.Store(0)
 	go b.UpdateMonitor(m)
 }
 
 func (b *Bot) AwaitMessage() {
+	b.awaiting.Store(true)
 	for message := range b.alertFeed {
-		// If there are more than 20 cities, split the message
-		if len(message.Cities) > 20 {
+		split := b.Settings().Thresholds.SplitCities
+		// If there are more than split cities, split the message
+		if len(message.Cities) > split {
 			originalCities := message.Cities // Keep a copy of the original cities
 			// Also copy RocketIDs associated with the original set of cities if necessary.
 			// For simplicity, we assume RocketIDs apply to the whole message context for now.
 			// If RocketIDs are per-city, this logic would need adjustment.
 
-			for i := 0; i < len(originalCities); i += 20 {
-				end := i + 20
+			for i := 0; i < len(originalCities); i += split {
+				end := i + split
 				if end > len(originalCities) {
 					end = len(originalCities)
 				}
@@ -152,12 +182,16 @@ func (b *Bot) AwaitMessage() {
 
 				// Create a new message for the chunk
 				newMessage := &Message{
+					ID:             model.NewId(),
 					Instructions:   message.Instructions,
 					Category:       message.Category,
 					Cities:         make([]district.ID, len(chunk)),
 					SafetySeconds:  message.SafetySeconds,
 					RocketIDs:      make(map[string]bool), // New map for each chunk
 					PubDate:        message.PubDate,
+					Source:         message.Source,
+					Received:       message.Received,
+					Issued:         message.Issued,
 					Expire:         message.Expire, // Copy expiration time
 					Rendered:       make(map[config.Language]*model.Post),
 					PostMutex:      sync.Mutex{},
@@ -166,6 +200,17 @@ func (b *Bot) AwaitMessage() {
 					Changed:        true,
 				}
 				copy(newMessage.Cities, chunk)
+				if i == 0 {
+					newMessage.Unrecognized = message.Unrecognized
+				}
+				for _, cityID := range chunk {
+					if name, ok := message.Approximate[cityID]; ok {
+						if newMessage.Approximate == nil {
+							newMessage.Approximate = make(map[district.ID]string)
+						}
+						newMessage.Approximate[cityID] = name
+					}
+				}
 				// If RocketIDs are relevant per city, filter them here.
 				// For now, copying all RocketIDs if they are not city-specific.
 				// This assumes that if a rocket ID was relevant for the original large message,
@@ -181,52 +226,19 @@ func (b *Bot) AwaitMessage() {
 				// 	newMessage.RocketIDs[k] = v
 				// }
 
-
-				newMessage.Prerender() // Prerender for the new set of cities
+				newMessage.Prerender()      // Prerender for the new set of cities
 				b.UpdateMonitor(newMessage) // Update monitor for this new (chunked) message
-
-				for _, channel := range b.Channels {
-					post := newMessage.PostForChannel(channel)
-					result, err := executeSubmitPost(b, post, newMessage, channel)
-					if err != nil {
-						mlog.Error("Failed to submit post for chunked message", mlog.Err(err), mlog.Any("channel", channel.Id), mlog.Any("chunkCities", newMessage.Cities))
-						continue // Continue to the next channel for this chunk
-					}
-
-					// Goroutine for reactions and patching, specific to this newMessage and result
-					go func(msgToProcess *Message, postResult *model.Post, ch *model.Channel) {
-						if msgToProcess.Category == "uav" || msgToProcess.Category == "infiltration" {
-							emoji := msgToProcess.Category + "-alert"
-							executeAddReaction(b, postResult, emoji)
-						}
-						// The original code had a check:
-						// if message.Category != "" && message.Category != "rockets" { /* no operation */ }
-						// This seems to be a placeholder or no-op, so it's omitted.
-
-						time.Sleep(200 * time.Millisecond) // Small delay before patching
-						// Prepare the post for patching using the channel the message was actually sent to.
-						patchContent := msgToProcess.PostForChannel(ch)
-						executePatchPost(b, patchContent, postResult.Id)
-					}(newMessage, result, channel) // Pass current newMessage, result, and channel
-				}
+				b.createAlert(newMessage)
 			}
 			// After processing all chunks, continue to the next message in alertFeed
 			// This prevents the original large message from being processed by the subsequent logic.
 			continue
 		}
 
-		// Original message processing logic starts here for messages with <= 20 cities
-		if len(message.Cities) == 0 {
+		// Original message processing logic starts here for messages with <= split cities
+		if len(message.Cities) == 0 && len(message.Unrecognized) == 0 {
 			mlog.Warn("no cities", mlog.Any("message", message))
-			for _, channel := range b.Channels {
-				post := message.PostForChannel(channel)
-				_, err := executeSubmitPost(b, post, message, channel)
-				if err != nil {
-					// Log error and continue to next channel, rather than stopping AwaitMessage
-					mlog.Error("Failed to submit post for message with no cities", mlog.Err(err), mlog.Any("channel", channel.Id))
-					continue
-				}
-			}
+			b.createAlertOnly(message)
 			continue
 		}
 
@@ -240,45 +252,11 @@ func (b *Bot) AwaitMessage() {
 		}
 
 		if len(citiesNotFound) > 0 {
-			for _, channel := range b.Channels {
-				post := message.PostForChannel(channel)
-				result, err := executeSubmitPost(b, post, message, channel)
-				if err != nil {
-					// Log error and continue to next channel
-					mlog.Error("Failed to submit post for message with cities not found", mlog.Err(err), mlog.Any("channel", channel.Id))
-					continue
-				}
-
-				// Goroutine for reactions and patching for original message flow
-				go func(msgToProcess *Message, postResult *model.Post, ch *model.Channel) {
-					if msgToProcess.Category == "uav" || msgToProcess.Category == "infiltration" {
-						emoji := msgToProcess.Category + "-alert"
-						executeAddReaction(b, postResult, emoji)
-					}
-					// if message.Category != "" && message.Category != "rockets" { /* no-op */ }
-
-					time.Sleep(200 * time.Millisecond)
-					// Prepare the post for patching using the channel the message was actually sent to.
-					patchContent := msgToProcess.PostForChannel(ch)
-					executePatchPost(b, patchContent, postResult.Id)
-				}(message, result, channel) // Pass current message, result, and channel
-			}
+			b.createAlert(message)
 		}
 	}
 }
 
-func (m *Message) PatchPosts(b *Bot) {
-	m.PostMutex.Lock()
-	postIDsCpy := slices.Clone(m.PostIDs)
-	channelsPostsCpy := slices.Clone(m.ChannelsPosted)
-	m.PostMutex.Unlock()
-	for i, postID := range postIDsCpy {
-		channel := channelsPostsCpy[i]
-		post := m.PostForChannel(channel)
-		executePatchPost(b, post, postID)
-	}
-}
-
 func (b *Bot) Cleanup() {
 	for {
 		time.Sleep(1 * time.Second)
@@ -288,15 +266,21 @@ func (b *Bot) Cleanup() {
 				message.Changed = false
 				message.PatchPosts(b)
 			}
-			if message.IsExpired() {
+			if message.IsExpired() && message.correlationExpired() {
 				delete(b.dedup, id)
 			}
 		}
 		b.dedupMutex.Unlock()
+		b.snapshotDedup()
+		b.lastCleanup.Store(time.Now().UnixNano())
 	}
 }
 
 func (b *Bot) UpdateMonitor(m *Message) {
+	if b.Monitoring.CitiesHistogram == nil {
+		// prepared without metrics
+		return
+	}
 	b.Monitoring.CitiesHistogram.Observe(float64(len(m.Cities)))
 	b.Monitoring.DayOfWeekHistogram.Observe(float64(time.Now().Weekday()))
 	b.Monitoring.TimeOfDayHistogram.Observe(float64(time.Now().Hour()))
@@ -309,13 +293,17 @@ func (b *Bot) UpdateMonitor(m *Message) {
 }
 
 func (b *Bot) DirectMessage(post *model.Post, language config.Language) {
-	if b.Channels == nil {
+	channels := b.AlertChannels()
+	if len(channels) == 0 {
 		mlog.Error("No channels available for direct messaging")
 		return
 	}
+	if !b.IsLeader() {
+		return
+	}
 
-	for _, channel := range b.Channels {
-		if ChannelToLanguage(channel) == language {
+	for _, channel := range channels {
+		if b.ChannelLanguages(channel)[0] == language {
 			post.ChannelId = channel.Id
 			_, err := executeSubmitPost(b, post, nil, channel)
 			if err != nil {
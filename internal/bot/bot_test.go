@@ -92,6 +92,7 @@ func setupTestBot(t *testing.T) *Bot {
 		Type:        model.ChannelTypeOpen,
 	}
 	b.Channels = []*model.Channel{dummyChannel}
+	b.AddSink(&MattermostSink{Bot: b})
 	return b
 }
 
@@ -180,7 +181,7 @@ func compareDistrictIDSlices(t *testing.T, context string, got, expected []distr
 // compareRocketIDs adjusted for string keys
 func compareRocketIDs(t *testing.T, context string, got, expected map[string]bool) {
 	t.Helper()
-	if !reflect.DeepEqual(got, expected) { 
+	if !reflect.DeepEqual(got, expected) {
 		t.Errorf("%s: RocketIDs mismatch. Got %v, Expected %v", context, got, expected)
 	}
 }
@@ -195,7 +196,6 @@ func TestAwaitMessage_SplittingLogic(t *testing.T) {
 		return createTestMessage(t, numCities, category, instr, safetySec)
 	}
 
-
 	t.Run("NoSplit_LessThan20Cities", func(t *testing.T) {
 		b := setupTestBot(t)
 		msg := createLocalTestMessage(5, "rockets", "instr_lessthan20", 60)
@@ -229,7 +229,7 @@ func TestAwaitMessage_SplittingLogic(t *testing.T) {
 		b := setupTestBot(t)
 		runAwaitMessageTest(t, b, createLocalTestMessage(40, "rockets", "instr_40cities", 120))
 	})
-	
+
 	// PropertyPreservation_Split test is difficult to meaningfully implement without mock capture
 	// of the split messages. We're implicitly testing property copying by ensuring AwaitMessage
 	// doesn't panic when creating newMessage, but cannot verify values in split messages.
@@ -264,8 +264,8 @@ func attachmentsToText(attachmentsProp interface{}) string {
 			if valStr, okFS := field.Value.(string); okFS {
 				fullText.WriteString(valStr)
 			} else if valIntf, okFS := field.Value.(interface{}); okFS { // Handle other types if necessary
-                 fullText.WriteString(fmt.Sprintf("%v", valIntf))
-            }
+				fullText.WriteString(fmt.Sprintf("%v", valIntf))
+			}
 		}
 	}
 	return fullText.String()
@@ -294,13 +294,13 @@ func Test_processMessage(t *testing.T) {
 		userId: "test_mockbot_user_id",
 		// Initialize Monitoring with non-registering collectors
 		Monitoring: monitoring.Monitoring{
-			SuccessfulPosts:     prometheus.NewCounter(prometheus.CounterOpts{Name: "test_proc_successful_posts"}),
-			SuccessfulPatches:   prometheus.NewCounter(prometheus.CounterOpts{Name: "test_proc_successful_patches"}),
-			FailedPatches:       prometheus.NewCounter(prometheus.CounterOpts{Name: "test_proc_failed_patches"}),
-			CitiesHistogram:     prometheus.NewHistogram(prometheus.HistogramOpts{Name: "test_proc_cities_histogram"}),
-			RegionsHistogram:    prometheus.NewHistogram(prometheus.HistogramOpts{Name: "test_proc_regions_histogram"}),
-			TimeOfDayHistogram:  prometheus.NewHistogram(prometheus.HistogramOpts{Name: "test_proc_time_of_day_histogram"}),
-			DayOfWeekHistogram:  prometheus.NewHistogram(prometheus.HistogramOpts{Name: "test_proc_day_of_week_histogram"}),
+			SuccessfulPosts:    prometheus.NewCounter(prometheus.CounterOpts{Name: "test_proc_successful_posts"}),
+			SuccessfulPatches:  prometheus.NewCounter(prometheus.CounterOpts{Name: "test_proc_successful_patches"}),
+			FailedPatches:      prometheus.NewCounter(prometheus.CounterOpts{Name: "test_proc_failed_patches"}),
+			CitiesHistogram:    prometheus.NewHistogram(prometheus.HistogramOpts{Name: "test_proc_cities_histogram"}),
+			RegionsHistogram:   prometheus.NewHistogram(prometheus.HistogramOpts{Name: "test_proc_regions_histogram"}),
+			TimeOfDayHistogram: prometheus.NewHistogram(prometheus.HistogramOpts{Name: "test_proc_time_of_day_histogram"}),
+			DayOfWeekHistogram: prometheus.NewHistogram(prometheus.HistogramOpts{Name: "test_proc_day_of_week_histogram"}),
 			// Note: SuccessfulSourceFetches and FailedSourceFetches are CounterVecs,
 			// and HttpResponseTimeHistogram is a HistogramVec.
 			// These are not directly used by MockBot's methods but are part of the struct.
@@ -0,0 +1,303 @@
+package bot
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/shared/mlog"
+	"github.com/phntom/goalert/internal/config"
+	"slices"
+	"strings"
+)
+
+// Channel objects are never modified once published in Channels, updates replace them, so alerts
+// holding on to the channels they were posted in stay consistent while the list changes.
+
+// AlertChannels returns the channels alerts are posted in.
+func (b *Bot) AlertChannels() []*model.Channel {
+	b.channelsMutex.RLock()
+	defer b.channelsMutex.RUnlock()
+	return slices.Clone(b.Channels)
+}
+
+func (b *Bot) opsChannel() *model.Channel {
+	b.channelsMutex.RLock()
+	defer b.channelsMutex.RUnlock()
+	return b.OpsChannel
+}
+
+// RefreshChannels rebuilds the channel list from every channel the bot is a member of.
+func (b *Bot) RefreshChannels() error {
+	teams, _, err := b.Client.GetTeamsForUser(context.Background(), b.userId, "")
+	if err != nil {
+		return fmt.Errorf("fetching active teams for bot: %w", err)
+	}
+	var alertChannels []*model.Channel
+	var configChannel, opsChannel *model.Channel
+	subscriptions := make(map[string]*Subscription)
+	for _, team := range teams {
+		if team == nil {
+			continue
+		}
+		channels, _, err := b.Client.GetChannelsForTeamForUser(context.Background(), team.Id, b.userId, false, "")
+		if err != nil {
+			return fmt.Errorf("fetching channels for team %s: %w", team.Id, err)
+		}
+		for _, channel := range channels {
+			if channel == nil {
+				continue
+			}
+			switch b.classifyChannel(team.Name, channel) {
+			case configChannelKind:
+				configChannel = channel
+			case opsChannelKind:
+				opsChannel = channel
+			case alertChannelKind:
+				channel.AddProp("teamName", team.Name)
+				alertChannels = append(alertChannels, channel)
+				if subscription := ParseSubscription(channel.Header + "\n" + channel.Purpose); subscription != nil {
+					subscriptions[channel.Id] = subscription
+				}
+			}
+		}
+	}
+	b.channelsMutex.Lock()
+	b.Channels = alertChannels
+	b.ConfigChannel = configChannel
+	b.OpsChannel = opsChannel
+	b.channelsMutex.Unlock()
+	// direct message subscriptions live with their users, they are kept across refreshes
+	b.usersMutex.Lock()
+	for _, u := range b.users {
+		if subscription := u.subscription(); u.ChannelID != "" && subscription != nil {
+			subscriptions[u.ChannelID] = subscription
+		}
+	}
+	b.subscriptionsMutex.Lock()
+	b.subscriptions = subscriptions
+	b.subscriptionsMutex.Unlock()
+	b.usersMutex.Unlock()
+	return nil
+}
+
+// Channel props naming the channel's languages, for integrations managing channels through the API.
+const (
+	languageProp          = "goalert_language"
+	secondaryLanguageProp = "goalert_secondary_language"
+)
+
+// ChannelLanguages returns the languages posts to channel are rendered in, the main one first. The goalert line in
+// the header comes first, then the channel props, then the mattermost.channels setting and finally the script of
+// the display name.
+func (b *Bot) ChannelLanguages(channel *model.Channel) []config.Language {
+	var lang config.Language
+	var secondary []config.Language
+	if s := b.SubscriptionFor(channel); s != nil {
+		lang, secondary = s.Language, s.Secondary
+	}
+	if lang == "" {
+		lang = languageFromProp(channel, languageProp)
+	}
+	if len(secondary) == 0 {
+		if prop := languageFromProp(channel, secondaryLanguageProp); prop != "" {
+			secondary = []config.Language{prop}
+		}
+	}
+	if lang == "" || len(secondary) == 0 {
+		teamName, _ := channel.Props["teamName"].(string)
+		for _, c := range b.Settings().Mattermost.Channels {
+			if c.Name != channel.Name && c.Name != teamName+"/"+channel.Name {
+				continue
+			}
+			if lang == "" {
+				lang = c.Language
+			}
+			if len(secondary) == 0 && c.Secondary != "" {
+				secondary = []config.Language{c.Secondary}
+			}
+			break
+		}
+	}
+	if lang == "" {
+		lang = ChannelToLanguage(channel)
+	}
+	languages := []config.Language{lang}
+	for _, l := range secondary {
+		if !slices.Contains(languages, l) {
+			languages = append(languages, l)
+		}
+	}
+	return languages
+}
+
+func languageFromProp(channel *model.Channel, key string) config.Language {
+	value, _ := channel.Props[key].(string)
+	if lang := config.Language(strings.ToLower(value)); slices.Contains(config.Languages, lang) {
+		return lang
+	}
+	return ""
+}
+
+type channelKind int
+
+const (
+	ignoredChannelKind channelKind = iota
+	alertChannelKind
+	configChannelKind
+	opsChannelKind
+)
+
+func (b *Bot) classifyChannel(teamName string, channel *model.Channel) channelKind {
+	settings := b.Settings().Mattermost
+	switch {
+	case channel.IsGroupOrDirect(), channel.DeleteAt != 0:
+		return ignoredChannelKind
+	case channel.Name == "off-topic" || channel.Name == "town-square":
+		return ignoredChannelKind
+	case teamName == settings.ConfigTeam && channel.Name == settings.ConfigChannel:
+		return configChannelKind
+	case channel.Name == settings.OpsChannel:
+		return opsChannelKind
+	}
+	return alertChannelKind
+}
+
+// placeChannel adds channel or replaces the channel with the same id, in the role its name gives it.
+func (b *Bot) placeChannel(teamName string, channel *model.Channel) {
+	kind := b.classifyChannel(teamName, channel)
+	b.channelsMutex.Lock()
+	b.Channels = slices.DeleteFunc(slices.Clone(b.Channels), func(c *model.Channel) bool { return c.Id == channel.Id })
+	if b.OpsChannel != nil && b.OpsChannel.Id == channel.Id {
+		b.OpsChannel = nil
+	}
+	switch kind {
+	case alertChannelKind:
+		channel.AddProp("teamName", teamName)
+		b.Channels = append(b.Channels, channel)
+	case opsChannelKind:
+		b.OpsChannel = channel
+	case configChannelKind:
+		// the telegram session storage keeps the channel it started with, moving it takes a restart
+		if b.ConfigChannel == nil {
+			b.ConfigChannel = channel
+		}
+	}
+	b.channelsMutex.Unlock()
+	if kind == alertChannelKind {
+		b.setSubscription(channel.Id, ParseSubscription(channel.Header+"\n"+channel.Purpose))
+	} else {
+		b.setSubscription(channel.Id, nil)
+	}
+	mlog.Info("Channel updated", mlog.Any("team", teamName), mlog.Any("channel", channel.Name), mlog.Any("kind", kind))
+}
+
+// forgetChannel stops posting to the channel with channelID.
+func (b *Bot) forgetChannel(channelID string) {
+	b.channelsMutex.Lock()
+	before := len(b.Channels)
+	b.Channels = slices.DeleteFunc(slices.Clone(b.Channels), func(c *model.Channel) bool { return c.Id == channelID })
+	removed := len(b.Channels) != before
+	if b.OpsChannel != nil && b.OpsChannel.Id == channelID {
+		b.OpsChannel = nil
+		removed = true
+	}
+	b.channelsMutex.Unlock()
+	b.setSubscription(channelID, nil)
+	if removed {
+		mlog.Info("Left channel", mlog.Any("channelId", channelID))
+	}
+}
+
+// fetchChannel places the channel with channelID after looking it and its team up.
+func (b *Bot) fetchChannel(channelID string) {
+	ctx, cancel := context.WithTimeout(context.Background(), postTimeout)
+	defer cancel()
+	channel, _, err := b.Client.GetChannel(ctx, channelID, "")
+	if err != nil {
+		mlog.Error("failed fetching channel", mlog.Any("channelId", channelID), mlog.Err(err))
+		return
+	}
+	b.placeChannel(b.teamName(ctx, channel.TeamId), channel)
+}
+
+// teamName resolves a team id from the channels already known, falling back to the API.
+func (b *Bot) teamName(ctx context.Context, teamID string) string {
+	b.channelsMutex.RLock()
+	for _, channel := range b.Channels {
+		if channel.TeamId == teamID {
+			if name, ok := channel.Props["teamName"].(string); ok {
+				b.channelsMutex.RUnlock()
+				return name
+			}
+		}
+	}
+	b.channelsMutex.RUnlock()
+	team, _, err := b.Client.GetTeam(ctx, teamID, "")
+	if err != nil {
+		mlog.Error("failed fetching team", mlog.Any("teamId", teamID), mlog.Err(err))
+		return ""
+	}
+	return team.Name
+}
+
+// handleUserAdded picks up a channel the bot was invited to.
+func (b *Bot) handleUserAdded(event *model.WebSocketEvent) {
+	if event.GetData()["user_id"] != b.userId {
+		return
+	}
+	b.fetchChannel(event.GetBroadcast().ChannelId)
+}
+
+// handleUserRemoved drops a channel the bot was removed from, the event either names the bot in its data or is
+// addressed to the bot and names the channel.
+func (b *Bot) handleUserRemoved(event *model.WebSocketEvent) {
+	data := event.GetData()
+	broadcast := event.GetBroadcast()
+	switch {
+	case data["user_id"] == b.userId && broadcast.ChannelId != "":
+		b.forgetChannel(broadcast.ChannelId)
+	case broadcast.UserId == b.userId:
+		if channelID, ok := data["channel_id"].(string); ok {
+			b.forgetChannel(channelID)
+		}
+	}
+}
+
+// handleChannelUpdated follows renames and header changes, which may change a channel's language or subscription.
+func (b *Bot) handleChannelUpdated(event *model.WebSocketEvent) {
+	channelJSON, ok := event.GetData()["channel"].(string)
+	if !ok {
+		return
+	}
+	var channel model.Channel
+	if err := json.Unmarshal([]byte(channelJSON), &channel); err != nil {
+		mlog.Error("failed decoding channel_updated event", mlog.Err(err))
+		return
+	}
+	if !b.isMember(channel.Id) {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), postTimeout)
+	defer cancel()
+	b.placeChannel(b.teamName(ctx, channel.TeamId), &channel)
+}
+
+// handleChannelDeleted drops an archived channel.
+func (b *Bot) handleChannelDeleted(event *model.WebSocketEvent) {
+	if channelID, ok := event.GetData()["channel_id"].(string); ok {
+		b.forgetChannel(channelID)
+	}
+}
+
+func (b *Bot) isMember(channelID string) bool {
+	b.channelsMutex.RLock()
+	defer b.channelsMutex.RUnlock()
+	for _, channel := range b.Channels {
+		if channel.Id == channelID {
+			return true
+		}
+	}
+	return b.OpsChannel != nil && b.OpsChannel.Id == channelID ||
+		b.ConfigChannel != nil && b.ConfigChannel.Id == channelID
+}
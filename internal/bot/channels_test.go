@@ -0,0 +1,115 @@
+package bot
+
+import (
+	"encoding/json"
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func channelsBot() *Bot {
+	b := &Bot{userId: "bot"}
+	b.Register()
+	b.placeChannel("phantom", &model.Channel{Id: "north", TeamId: "t", Name: "north", DisplayName: "צפון"})
+	b.placeChannel("phantom", &model.Channel{Id: "ops", TeamId: "t", Name: "goalert-ops"})
+	return b
+}
+
+func channelIDs(channels []*model.Channel) []string {
+	var ids []string
+	for _, channel := range channels {
+		ids = append(ids, channel.Id)
+	}
+	return ids
+}
+
+func TestBot_placeChannel(t *testing.T) {
+	b := channelsBot()
+	assert.Equal(t, []string{"north"}, channelIDs(b.AlertChannels()))
+	assert.Equal(t, "ops", b.opsChannel().Id)
+
+	held := b.AlertChannels()
+	renamed := &model.Channel{Id: "north", TeamId: "t", Name: "north", DisplayName: "North", Header: "goalert: areas=27"}
+	b.placeChannel("phantom", renamed)
+	channels := b.AlertChannels()
+	require.Len(t, channels, 1)
+	assert.Equal(t, "en", string(ChannelToLanguage(channels[0])))
+	assert.Equal(t, "phantom", channels[0].Props["teamName"])
+	assert.True(t, b.SubscriptionFor(channels[0]).AreaIDs[27])
+	assert.Equal(t, "צפון", held[0].DisplayName, "channels handed out earlier are not modified")
+
+	b.placeChannel("phantom", &model.Channel{Id: "north", TeamId: "t", Name: "goalert-ops"})
+	assert.Empty(t, b.AlertChannels())
+	assert.Equal(t, "north", b.opsChannel().Id)
+	assert.Nil(t, b.SubscriptionFor(renamed))
+}
+
+func TestBot_HandleWebSocketEvent_Membership(t *testing.T) {
+	b := channelsBot()
+
+	header := &model.Channel{Id: "north", TeamId: "t", Name: "north", DisplayName: "צפון", Header: "goalert: categories=uav"}
+	data, err := json.Marshal(header)
+	require.NoError(t, err)
+	updated := model.NewWebSocketEvent(model.WebsocketEventChannelUpdated, "", "north", "", nil, "")
+	updated.Add("channel", string(data))
+	b.HandleWebSocketEvent(updated)
+	assert.True(t, b.SubscriptionFor(header).Categories["uav"])
+
+	stranger := &model.Channel{Id: "south", TeamId: "t", Name: "south"}
+	data, err = json.Marshal(stranger)
+	require.NoError(t, err)
+	updated = model.NewWebSocketEvent(model.WebsocketEventChannelUpdated, "", "south", "", nil, "")
+	updated.Add("channel", string(data))
+	b.HandleWebSocketEvent(updated)
+	assert.Equal(t, []string{"north"}, channelIDs(b.AlertChannels()), "updates to channels the bot is not in are ignored")
+
+	otherUser := model.NewWebSocketEvent(model.WebsocketEventUserRemoved, "", "north", "", nil, "")
+	otherUser.Add("user_id", "someone")
+	b.HandleWebSocketEvent(otherUser)
+	assert.Equal(t, []string{"north"}, channelIDs(b.AlertChannels()))
+
+	removed := model.NewWebSocketEvent(model.WebsocketEventUserRemoved, "", "", "bot", nil, "")
+	removed.Add("channel_id", "north")
+	b.HandleWebSocketEvent(removed)
+	assert.Empty(t, b.AlertChannels())
+	assert.Nil(t, b.SubscriptionFor(header))
+
+	deleted := model.NewWebSocketEvent(model.WebsocketEventChannelDeleted, "t", "", "", nil, "")
+	deleted.Add("channel_id", "ops")
+	b.HandleWebSocketEvent(deleted)
+	assert.Nil(t, b.opsChannel())
+}
+
+func TestBot_RefreshChannels_KeepsDirectSubscriptions(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body any
+		switch r.URL.Path {
+		case "/api/v4/users/test_bot_user_id/teams":
+			body = []*model.Team{{Id: "t", Name: "phantom"}}
+		case "/api/v4/users/test_bot_user_id/teams/t/channels":
+			body = []*model.Channel{{Id: "north", TeamId: "t", Name: "north", DisplayName: "North", Type: model.ChannelTypeOpen}}
+		default:
+			http.NotFound(w, r)
+			return
+		}
+		//goland:noinspection GoUnhandledErrorResult
+		json.NewEncoder(w).Encode(body) //nolint:errcheck
+	}))
+	defer server.Close()
+
+	b := setupTestBot(t)
+	b.Client = model.NewAPIv4Client(server.URL)
+	const dm = "direct_channel_id"
+	b.HandleCommand("user_id", dm, "subscribe עין חרוד")
+	b.HandleCommand("user_id", dm, "lang en")
+
+	require.NoError(t, b.RefreshChannels())
+	assert.Equal(t, []string{"north"}, channelIDs(b.AlertChannels()))
+	subscription := b.SubscriptionFor(&model.Channel{Id: dm})
+	require.NotNil(t, subscription, "direct message subscriptions survive a refresh")
+	assert.True(t, subscription.Districts["999"])
+	assert.Equal(t, "en", string(subscription.Language))
+}
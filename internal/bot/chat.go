@@ -2,10 +2,172 @@ package bot
 
 import (
 	"context"
+	"errors"
 	"github.com/mattermost/mattermost/server/public/model"
 	"github.com/mattermost/mattermost/server/public/shared/mlog"
+	"github.com/phntom/goalert/internal/district"
+	"slices"
+	"strings"
+	"time"
 )
 
+// MattermostSink posts alerts to every channel the bot is a member of, narrowed by the channel's Subscription,
+// and to the direct channels of users subscribed to the alerted cities.
+type MattermostSink struct {
+	Bot *Bot
+}
+
+// settleDelay is how long new posts show the alert text, which notifications are made of, before it is blanked and
+// only the attachment remains.
+const settleDelay = 200 * time.Millisecond
+
+func (s *MattermostSink) Name() string {
+	return "mattermost"
+}
+
+// Create posts the alert to every channel and, once the posts settle, blanks their message so only the attachment
+// remains and attaches the map of the alerted districts. Alerts without any city stay as they were posted.
+func (s *MattermostSink) Create(m *Message) error {
+	var errs []error
+	var posted []*model.Post
+	var postedChannels []*model.Channel
+	channels := s.Bot.AlertChannels()
+	if len(m.Cities) > 0 {
+		channels = append(channels, s.Bot.DirectChannels()...)
+	}
+	for _, channel := range channels {
+		post, ok := m.PostForSubscriber(channel, s.Bot.SubscriptionFor(channel), s.Bot.ChannelLanguages(channel))
+		if !ok {
+			continue
+		}
+		result, err := executeSubmitPost(s.Bot, post, m, channel)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		posted = append(posted, result)
+		postedChannels = append(postedChannels, channel)
+	}
+	if len(posted) > 0 && (len(m.Cities) > 0 || len(m.Unrecognized) > 0) {
+		go func() {
+			time.Sleep(settleDelay)
+			for i, result := range posted {
+				channel := postedChannels[i]
+				post, ok := m.PostForSubscriber(channel, s.Bot.SubscriptionFor(channel), s.Bot.ChannelLanguages(channel))
+				if !ok {
+					continue
+				}
+				executePatchPost(s.Bot, post, result.Id)
+			}
+			s.attachMap(m)
+		}()
+	}
+	return errors.Join(errs...)
+}
+
+func (s *MattermostSink) Update(m *Message) error {
+	m.PostMutex.Lock()
+	postIDsCpy := slices.Clone(m.PostIDs)
+	channelsPostsCpy := slices.Clone(m.ChannelsPosted)
+	m.PostMutex.Unlock()
+	for i, postID := range postIDsCpy {
+		channel := channelsPostsCpy[i]
+		post, ok := m.PostForSubscriber(channel, s.Bot.SubscriptionFor(channel), s.Bot.ChannelLanguages(channel))
+		if !ok {
+			continue
+		}
+		executePatchPost(s.Bot, post, postID)
+	}
+	if len(postIDsCpy) > 0 {
+		// the map is drawn and uploaded without holding up the patches, or the caller's locks
+		go s.attachMap(m)
+	}
+	return nil
+}
+
+// attachMap patches the map of m's cities into its posts. The map is only drawn again when the cities changed
+// since it was last attached, and uploaded to each channel the alert was posted in since files belong to a channel.
+func (s *MattermostSink) attachMap(m *Message) {
+	m.mapMutex.Lock()
+	defer m.mapMutex.Unlock()
+	cities := citiesKey(m.Cities)
+	if cities == m.mapCities {
+		return
+	}
+	m.mapCities = cities
+	image := district.RenderMap(m.Cities)
+	if image == nil {
+		return
+	}
+	m.PostMutex.Lock()
+	postIDsCpy := slices.Clone(m.PostIDs)
+	channelsPostsCpy := slices.Clone(m.ChannelsPosted)
+	m.PostMutex.Unlock()
+	for i, postID := range postIDsCpy {
+		channel := channelsPostsCpy[i]
+		post, ok := m.PostForSubscriber(channel, s.Bot.SubscriptionFor(channel), s.Bot.ChannelLanguages(channel))
+		if !ok {
+			continue
+		}
+		fileID := s.uploadMap(image, channel)
+		if fileID == "" {
+			continue
+		}
+		post.FileIds = model.StringArray{fileID}
+		executePatchPost(s.Bot, post, postID)
+	}
+}
+
+func citiesKey(cities []district.ID) string {
+	var b strings.Builder
+	for _, city := range cities {
+		b.WriteString(string(city))
+		b.WriteByte(',')
+	}
+	return b.String()
+}
+
+// uploadMap uploads the map to channel and returns its file id, empty when the upload failed and the post stays
+// without it.
+func (s *MattermostSink) uploadMap(image []byte, channel *model.Channel) string {
+	ctx, cancel := context.WithTimeout(context.Background(), postTimeout)
+	defer cancel()
+	uploaded, response, err := s.Bot.Client.UploadFile(ctx, image, channel.Id, "map.png")
+	if err != nil || len(uploaded.FileInfos) == 0 {
+		mlog.Error("failed uploading map",
+			mlog.Err(err),
+			mlog.Any("channelID", channel.Id),
+			mlog.Any("response", response),
+		)
+		return ""
+	}
+	return uploaded.FileInfos[0].Id
+}
+
+func (s *MattermostSink) React(m *Message, emoji string) error {
+	m.PostMutex.Lock()
+	postIDsCpy := slices.Clone(m.PostIDs)
+	m.PostMutex.Unlock()
+	var errs []error
+	for _, postID := range postIDsCpy {
+		errs = append(errs, executeAddReaction(s.Bot, postID, emoji))
+	}
+	return errors.Join(errs...)
+}
+
+func (s *MattermostSink) Retract(m *Message) error {
+	m.PostMutex.Lock()
+	postIDsCpy := m.PostIDs
+	m.PostIDs = nil
+	m.ChannelsPosted = nil
+	m.PostMutex.Unlock()
+	var errs []error
+	for _, postID := range postIDsCpy {
+		errs = append(errs, executeDeletePost(s.Bot, postID))
+	}
+	return errors.Join(errs...)
+}
+
 func executeSubmitPost(b *Bot, post *model.Post, message *Message, channel *model.Channel) (*model.Post, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), postTimeout)
 	defer cancel()
@@ -34,6 +196,9 @@ func executePatchPost(b *Bot, post *model.Post, postID string) {
 		Message: model.NewString(""),
 		Props:   &post.Props,
 	}
+	if len(post.FileIds) > 0 {
+		patch.FileIds = &post.FileIds
+	}
 	ctx, cancel := context.WithTimeout(context.Background(), postTimeout)
 	_, response, err := b.Client.PatchPost(ctx, postID, &patch)
 	cancel()
@@ -50,19 +215,34 @@ func executePatchPost(b *Bot, post *model.Post, postID string) {
 	}
 }
 
-func executeAddReaction(b *Bot, post *model.Post, emoji string) {
+func executeAddReaction(b *Bot, postID string, emoji string) error {
 	ctx, cancel := context.WithTimeout(context.Background(), postTimeout)
 	defer cancel()
 	_, _, err := b.Client.SaveReaction(ctx, &model.Reaction{
 		UserId:    b.userId,
-		PostId:    post.Id,
+		PostId:    postID,
 		EmojiName: emoji,
 	})
 	if err != nil {
 		mlog.Error("failed adding reaction",
 			mlog.Err(err),
 			mlog.Any("emoji", emoji),
-			mlog.Any("postID", post),
+			mlog.Any("postID", postID),
+		)
+	}
+	return err
+}
+
+func executeDeletePost(b *Bot, postID string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), postTimeout)
+	defer cancel()
+	response, err := b.Client.DeletePost(ctx, postID)
+	if err != nil {
+		mlog.Error("failed deleting post",
+			mlog.Err(err),
+			mlog.Any("postID", postID),
+			mlog.Any("response", response),
 		)
 	}
+	return err
 }
@@ -0,0 +1,177 @@
+package bot
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/phntom/goalert/internal/district"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeMattermost accepts file uploads, posts and patches, recording what it received.
+type fakeMattermost struct {
+	mu      sync.Mutex
+	uploads []string
+	posts   []*model.Post
+	patches map[string]*model.PostPatch
+	files   map[string]model.StringArray
+}
+
+// uploadedTo returns the channels files were uploaded to, in order.
+func (f *fakeMattermost) uploadedTo() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return slices.Clone(f.uploads)
+}
+
+// fileIDs returns the files patched into the post with postID, patches without files keep them.
+func (f *fakeMattermost) fileIDs(postID string) model.StringArray {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.files[postID]
+}
+
+// patch returns the last patch of the post with postID, nil if it was not patched.
+func (f *fakeMattermost) patch(postID string) *model.PostPatch {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.patches[postID]
+}
+
+func (f *fakeMattermost) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch r.URL.Path {
+	case "/api/v4/files":
+		channelID := r.FormValue("channel_id")
+		f.uploads = append(f.uploads, channelID)
+		w.WriteHeader(http.StatusCreated)
+		//goland:noinspection GoUnhandledErrorResult
+		json.NewEncoder(w).Encode(&model.FileUploadResponse{ //nolint:errcheck
+			FileInfos: []*model.FileInfo{{Id: "map-" + channelID + "-" + strconv.Itoa(len(f.uploads))}},
+		})
+	case "/api/v4/posts":
+		var post model.Post
+		if err := json.NewDecoder(r.Body).Decode(&post); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		post.Id = model.NewId()
+		f.posts = append(f.posts, &post)
+		w.WriteHeader(http.StatusCreated)
+		//goland:noinspection GoUnhandledErrorResult
+		json.NewEncoder(w).Encode(&post) //nolint:errcheck
+	default:
+		postID, ok := strings.CutSuffix(strings.TrimPrefix(r.URL.Path, "/api/v4/posts/"), "/patch")
+		if !ok || r.Method != http.MethodPut {
+			http.NotFound(w, r)
+			return
+		}
+		var patch model.PostPatch
+		if err := json.NewDecoder(r.Body).Decode(&patch); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if f.patches == nil {
+			f.patches = make(map[string]*model.PostPatch)
+		}
+		f.patches[postID] = &patch
+		if patch.FileIds != nil {
+			if f.files == nil {
+				f.files = make(map[string]model.StringArray)
+			}
+			f.files[postID] = *patch.FileIds
+		}
+		//goland:noinspection GoUnhandledErrorResult
+		json.NewEncoder(w).Encode(&model.Post{Id: postID}) //nolint:errcheck
+	}
+}
+
+func TestMattermostSink_AttachesMap(t *testing.T) {
+	fake := &fakeMattermost{}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	ctx := context.Background()
+	_, err := district.Load(ctx, http.DefaultClient, "../district/testdata/geo")
+	require.NoError(t, err)
+	defer district.Load(ctx, http.DefaultClient, "") //nolint:errcheck
+
+	b := setupTestBot(t)
+	b.Client = model.NewAPIv4Client(server.URL)
+	b.Channels = []*model.Channel{
+		{Id: "north", Name: "north", DisplayName: "North", Type: model.ChannelTypeOpen},
+		{Id: "south", Name: "south", DisplayName: "South", Type: model.ChannelTypeOpen},
+	}
+	s := &MattermostSink{Bot: b}
+
+	mapped := NewMessage("instructions", "rockets", 60, "11:40")
+	mapped.AppendDistrict("738")
+	require.NoError(t, s.Create(&mapped))
+	require.Len(t, fake.posts, 2)
+	assert.Empty(t, fake.posts[0].FileIds, "alerts are posted without waiting for the map")
+	north, south := fake.posts[0].Id, fake.posts[1].Id
+	require.Eventually(t, func() bool { return fake.fileIDs(south) != nil }, time.Second, 10*time.Millisecond)
+	assert.Equal(t, []string{"north", "south"}, fake.uploadedTo(), "the map is uploaded once per channel")
+	assert.Equal(t, model.StringArray{"map-north-1"}, fake.fileIDs(north))
+	assert.Equal(t, model.StringArray{"map-south-2"}, fake.fileIDs(south))
+
+	// an update adding a district draws the map again
+	mapped.mapMutex.Lock()
+	mapped.AppendDistrict("1132")
+	mapped.mapMutex.Unlock()
+	require.NoError(t, s.Update(&mapped))
+	require.Eventually(t, func() bool { return slices.Equal(fake.fileIDs(south), model.StringArray{"map-south-4"}) }, time.Second, 10*time.Millisecond)
+	assert.Equal(t, model.StringArray{"map-north-3"}, fake.fileIDs(north))
+
+	// an update with the same districts keeps the map
+	require.NoError(t, s.Update(&mapped))
+	time.Sleep(2 * settleDelay)
+	assert.Len(t, fake.uploadedTo(), 4)
+
+	// districts without geometry are posted without a map
+	unmapped := NewMessage("instructions", "rockets", 60, "11:40")
+	unmapped.AppendDistrict("6102")
+	require.NoError(t, s.Create(&unmapped))
+	require.Len(t, fake.posts, 4)
+	require.Eventually(t, func() bool { return fake.patch(fake.posts[3].Id) != nil }, time.Second, 10*time.Millisecond)
+	time.Sleep(settleDelay)
+	assert.Nil(t, fake.fileIDs(fake.posts[3].Id))
+	assert.Len(t, fake.uploadedTo(), 4)
+}
+
+func TestMattermostSink_CreateBlanksMessage(t *testing.T) {
+	fake := &fakeMattermost{}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	b := setupTestBot(t)
+	b.Client = model.NewAPIv4Client(server.URL)
+	b.Channels = []*model.Channel{{Id: "north", Name: "north", DisplayName: "North", Type: model.ChannelTypeOpen}}
+	s := &MattermostSink{Bot: b}
+
+	m := NewMessage("instructions", "rockets", 60, "11:40")
+	m.AppendDistrict("999")
+	require.NoError(t, s.Create(&m))
+	require.Len(t, fake.posts, 1)
+	assert.NotEmpty(t, fake.posts[0].Message, "notifications are made of the alert text")
+	postID := fake.posts[0].Id
+	require.Eventually(t, func() bool { return fake.patch(postID) != nil }, time.Second, 10*time.Millisecond)
+	assert.Equal(t, "", *fake.patch(postID).Message)
+
+	// alerts without cities keep their text
+	empty := NewMessage("instructions", "rockets", 60, "11:40")
+	require.NoError(t, s.Create(&empty))
+	require.Len(t, fake.posts, 2)
+	time.Sleep(2 * settleDelay)
+	assert.Nil(t, fake.patch(fake.posts[1].Id))
+}
@@ -0,0 +1,88 @@
+package bot
+
+import (
+	"slices"
+	"time"
+)
+
+// CorrelationWindow is how far apart two sources may date the same salvo, the ynet and telegram
+// publication times are only accurate to the minute and telegram tends to lag behind.
+const CorrelationWindow = 2 * time.Minute
+
+// ParsePubDate resolves a source publication time, either "02/01/2006 15:04" or a bare "15:04",
+// to an instant in Israel time. A bare time is taken on the day of now, or the day before when that
+// would put it more than an hour in the future. It returns the zero time for anything else.
+func ParsePubDate(pubDate string, now time.Time) time.Time {
+	location, err := time.LoadLocation("Asia/Jerusalem")
+	if err != nil {
+		location = time.Local
+	}
+	if t, err := time.ParseInLocation("02/01/2006 15:04", pubDate, location); err == nil {
+		return t
+	}
+	clock, err := time.Parse("15:04", pubDate)
+	if err != nil {
+		return time.Time{}
+	}
+	now = now.In(location)
+	t := time.Date(now.Year(), now.Month(), now.Day(), clock.Hour(), clock.Minute(), 0, 0, location)
+	if t.After(now.Add(time.Hour)) {
+		t = t.AddDate(0, 0, -1)
+	}
+	return t
+}
+
+// issued is when the alert was published, or received when the source did not say.
+func (m *Message) issued() time.Time {
+	if m.Issued.IsZero() {
+		return m.Received
+	}
+	return m.Issued
+}
+
+// Correlates reports whether n is another source's report of the salvo m already covers:
+// a source that did not confirm m yet, a compatible category and publication times within the window.
+func (m *Message) Correlates(n *Message) bool {
+	if n.Source == "" || n.Source == m.Source || m.Sources[n.Source] {
+		return false
+	}
+	if m.Category != "" && n.Category != "" && m.Category != n.Category {
+		return false
+	}
+	if m.issued().IsZero() || n.issued().IsZero() {
+		return false
+	}
+	return m.issued().Sub(n.issued()).Abs() <= CorrelationWindow
+}
+
+// ConfirmedBy lists the sources that reported the alert, sorted.
+func (m *Message) ConfirmedBy() []string {
+	var sources []string
+	if m.Source != "" {
+		sources = append(sources, m.Source)
+	}
+	for source := range m.Sources {
+		if source != m.Source {
+			sources = append(sources, source)
+		}
+	}
+	slices.Sort(sources)
+	return sources
+}
+
+// confirm records the source of n as confirming m, true if it was not known yet.
+func (m *Message) confirm(n *Message) bool {
+	if n.Source == "" || n.Source == m.Source || m.Sources[n.Source] {
+		return false
+	}
+	if m.Sources == nil {
+		m.Sources = make(map[string]bool)
+	}
+	m.Sources[n.Source] = true
+	return true
+}
+
+// correlationExpired reports whether no other source is expected to report the salvo anymore.
+func (m *Message) correlationExpired() bool {
+	return time.Now().After(m.Received.Add(CorrelationWindow))
+}
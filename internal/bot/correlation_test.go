@@ -0,0 +1,92 @@
+package bot
+
+import (
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestParsePubDate(t *testing.T) {
+	jerusalem, err := time.LoadLocation("Asia/Jerusalem")
+	require.NoError(t, err)
+	now := time.Date(2024, 10, 10, 0, 20, 0, 0, jerusalem)
+	tests := []struct {
+		pubDate string
+		want    time.Time
+	}{
+		{"10/10/2024 00:19", time.Date(2024, 10, 10, 0, 19, 0, 0, jerusalem)},
+		{"00:19", time.Date(2024, 10, 10, 0, 19, 0, 0, jerusalem)},
+		{"01:05", time.Date(2024, 10, 10, 1, 5, 0, 0, jerusalem)},
+		// published just before midnight
+		{"23:59", time.Date(2024, 10, 9, 23, 59, 0, 0, jerusalem)},
+		{"", time.Time{}},
+		{"soon", time.Time{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.pubDate, func(t *testing.T) {
+			assert.True(t, tt.want.Equal(ParsePubDate(tt.pubDate, now.UTC())), "got %v", ParsePubDate(tt.pubDate, now))
+		})
+	}
+}
+
+func TestCorrelation(t *testing.T) {
+	b := setupTestBot(t)
+	issued := time.Now().Truncate(time.Minute)
+
+	oref := NewMessage("instructions", "rockets", 90, issued.Format("15:04"))
+	oref.Source = "oref"
+	oref.Issued = issued.Add(25 * time.Second)
+	oref.AppendDistrict("999")
+	prevMsgs, citiesNotFound := b.GetPrevMsgs(&oref)
+	require.Empty(t, prevMsgs)
+	require.Len(t, citiesNotFound, 1)
+
+	// ynet reports the same salvo with its own rocket id, which on its own would make it a new alert
+	ynet := NewMessage("instructions", "rockets", 90, issued.Format("15:04"))
+	ynet.Source = "ynet"
+	ynet.Issued = issued
+	ynet.RocketIDs["guid"] = true
+	ynet.AppendDistrict("999")
+	prevMsgs, citiesNotFound = b.GetPrevMsgs(&ynet)
+	assert.Empty(t, citiesNotFound)
+	require.Same(t, &oref, prevMsgs["999"])
+	assert.True(t, oref.PatchData(&ynet))
+	assert.Equal(t, []string{"oref", "ynet"}, oref.ConfirmedBy())
+
+	// telegram arrives late, after the alert already expired from the dedup
+	oref.Expire = time.Now().Add(-time.Second)
+	telegram := NewMessage("instructions", "rockets", 90, issued.Format("02/01/2006 15:04"))
+	telegram.Source = "telegram"
+	telegram.Issued = issued
+	telegram.AppendDistrict("999")
+	prevMsgs, citiesNotFound = b.GetPrevMsgs(&telegram)
+	assert.Empty(t, citiesNotFound)
+	require.Same(t, &oref, prevMsgs["999"])
+	assert.True(t, oref.PatchData(&telegram))
+	assert.False(t, oref.PatchData(&telegram))
+
+	post := oref.PostForChannel(&model.Channel{Id: "channel", DisplayName: "Alerts"})
+	attachments := post.Attachments()
+	require.Len(t, attachments, 1)
+	assert.Equal(t, "Confirmed by oref, telegram, ynet", attachments[0].Footer)
+
+	// a repeated report of a confirming source is a new salvo once the alert expired
+	again := NewMessage("instructions", "rockets", 90, issued.Format("15:04"))
+	again.Source = "ynet"
+	again.Issued = issued.Add(time.Minute)
+	again.AppendDistrict("999")
+	_, citiesNotFound = b.GetPrevMsgs(&again)
+	assert.Len(t, citiesNotFound, 1)
+
+	// other sources only correlate within the window
+	late := NewMessage("instructions", "rockets", 90, "")
+	late.Source = "oref"
+	late.Issued = again.Issued.Add(CorrelationWindow + time.Minute)
+	assert.False(t, again.Correlates(&late))
+	late.Issued = again.Issued.Add(CorrelationWindow)
+	assert.True(t, again.Correlates(&late))
+	late.Category = "uav"
+	assert.False(t, again.Correlates(&late))
+}
@@ -16,19 +16,24 @@ func NewRocketIDsPresent(message *Message, prevMsg *Message) bool {
 }
 
 func (b *Bot) GetPrevMsgs(message *Message) (map[district.ID]*Message, map[district.ID]bool) {
-	citiesNotFound := make(map[district.ID]bool, len(message.Cities))
-	for _, city := range message.Cities {
+	keys := message.dedupKeys()
+	citiesNotFound := make(map[district.ID]bool, len(keys))
+	for _, city := range keys {
 		citiesNotFound[city] = true
 	}
 
-	prevMsgs := make(map[district.ID]*Message, len(message.Cities))
+	prevMsgs := make(map[district.ID]*Message, len(keys))
 	b.dedupMutex.Lock()
 	defer b.dedupMutex.Unlock()
 
-	for _, city := range message.Cities {
+	for _, city := range keys {
 		prevMsg, ok := b.dedup[city]
-		if !ok || prevMsg.IsExpired() || NewRocketIDsPresent(message, prevMsg) ||
-			(prevMsg.Category != "" && message.Category != "" && prevMsg.Category != message.Category) {
+		if !ok {
+			continue
+		}
+		// another source reporting the same salvo patches it even with its own rocket ids and after it expired
+		if !prevMsg.Correlates(message) && (prevMsg.IsExpired() || NewRocketIDsPresent(message, prevMsg) ||
+			(prevMsg.Category != "" && message.Category != "" && prevMsg.Category != message.Category)) {
 			continue
 		}
 		delete(citiesNotFound, city)
@@ -38,6 +43,10 @@ func (b *Bot) GetPrevMsgs(message *Message) (map[district.ID]*Message, map[distr
 	for city := range citiesNotFound {
 		b.dedup[city] = message
 	}
+	if len(citiesNotFound) > 0 {
+		// createAlert delivers it as it is, Cleanup must not report it as updated
+		message.Changed = false
+	}
 
 	return prevMsgs, citiesNotFound
 }
@@ -1,3 +1,218 @@
 package bot
 
 // Message handlers
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/shared/mlog"
+	"github.com/phntom/goalert/internal/config"
+	"github.com/phntom/goalert/internal/district"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Listen follows the Mattermost websocket and dispatches events, reconnecting whenever it drops.
+// Channel changes missed while disconnected are caught up on by refreshing the channels after reconnecting.
+func (b *Bot) Listen() {
+	for reconnect := false; ; reconnect = true {
+		ws, err := model.NewWebSocketClient4(websocketURL(b.Settings().Mattermost.Domain), b.Client.AuthToken)
+		if err != nil {
+			mlog.Error("failed connecting to websocket", mlog.Err(err))
+			time.Sleep(5 * time.Second)
+			continue
+		}
+		b.webSocketClient = ws
+		ws.Listen()
+		mlog.Info("Listening to websocket events")
+		if reconnect {
+			if err := b.RefreshChannels(); err != nil {
+				mlog.Error("failed refreshing channels", mlog.Err(err))
+			}
+		}
+		for event := range ws.EventChannel {
+			b.HandleWebSocketEvent(event)
+		}
+		mlog.Warn("websocket closed, reconnecting", mlog.Any("error", ws.ListenError))
+		time.Sleep(time.Second)
+	}
+}
+
+func websocketURL(domain string) string {
+	if strings.HasPrefix(domain, "https://") {
+		return "wss://" + strings.TrimPrefix(domain, "https://")
+	}
+	return "ws://" + strings.TrimPrefix(domain, "http://")
+}
+
+func (b *Bot) HandleWebSocketEvent(event *model.WebSocketEvent) {
+	switch event.EventType() {
+	case model.WebsocketEventPosted:
+		b.handlePosted(event)
+	case model.WebsocketEventUserAdded:
+		b.handleUserAdded(event)
+	case model.WebsocketEventUserRemoved:
+		b.handleUserRemoved(event)
+	case model.WebsocketEventChannelUpdated:
+		b.handleChannelUpdated(event)
+	case model.WebsocketEventChannelDeleted:
+		b.handleChannelDeleted(event)
+	case model.WebsocketEventChannelRestored, model.WebsocketEventChannelConverted:
+		if channelID, ok := event.GetData()["channel_id"].(string); ok && b.isMember(channelID) {
+			b.fetchChannel(channelID)
+		}
+	}
+}
+
+// handlePosted treats direct messages to the bot as commands.
+func (b *Bot) handlePosted(event *model.WebSocketEvent) {
+	data := event.GetData()
+	if data["channel_type"] != string(model.ChannelTypeDirect) {
+		return
+	}
+	postJSON, ok := data["post"].(string)
+	if !ok {
+		return
+	}
+	var post model.Post
+	if err := json.Unmarshal([]byte(postJSON), &post); err != nil {
+		mlog.Error("failed decoding posted event", mlog.Err(err))
+		return
+	}
+	if post.UserId == b.userId || post.Message == "" {
+		return
+	}
+	reply := b.HandleCommand(post.UserId, post.ChannelId, post.Message)
+	if !b.IsLeader() {
+		// every replica follows the commands to keep subscriptions in sync, only the leader answers
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), postTimeout)
+	defer cancel()
+	_, _, err := b.Client.CreatePost(ctx, &model.Post{
+		ChannelId: post.ChannelId,
+		Message:   reply,
+	})
+	if err != nil {
+		mlog.Error("failed replying to command", mlog.Err(err), mlog.Any("userID", post.UserId))
+	}
+}
+
+// ServeCommand answers the /goalert slash command, Mattermost must be configured to call it with COMMAND_TOKEN.
+func (b *Bot) ServeCommand(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	token := b.Settings().Mattermost.CommandToken
+	if token == "" || r.Form.Get("token") != token {
+		http.Error(w, "invalid token", http.StatusForbidden)
+		return
+	}
+	reply := b.HandleCommand(r.Form.Get("user_id"), "", r.Form.Get("text"))
+	w.Header().Set("Content-Type", "application/json")
+	//goland:noinspection GoUnhandledErrorResult
+	json.NewEncoder(w).Encode(&model.CommandResponse{ //nolint:errcheck
+		ResponseType: model.CommandResponseTypeEphemeral,
+		Text:         reply,
+	})
+}
+
+// HandleCommand runs a subscription command for the user and returns the reply in the user's language.
+// channelID is the direct channel with the user when known, otherwise one is created on subscribe.
+func (b *Bot) HandleCommand(userID string, channelID string, text string) string {
+	fields := strings.Fields(text)
+	if len(fields) > 0 && strings.TrimPrefix(fields[0], "/") == "goalert" {
+		fields = fields[1:]
+	}
+	// the channel is created before the command runs under usersMutex, which alert delivery waits for as well
+	if channelID == "" && len(fields) > 1 && strings.ToLower(fields[0]) == "subscribe" &&
+		b.userSubscription(userID).ChannelID == "" && district.GetDistrictByCity(strings.Join(fields[1:], " ")) != "" {
+		var err error
+		channelID, err = b.directChannelID(userID)
+		if err != nil {
+			mlog.Error("failed creating direct channel", mlog.Err(err), mlog.Any("userID", userID))
+			return err.Error()
+		}
+	}
+	var reply string
+	var changed bool
+	b.updateUserSubscription(userID, func(u *UserSubscription) bool {
+		if channelID != "" {
+			u.ChannelID = channelID
+		}
+		reply, changed = runCommand(u, fields)
+		return changed
+	})
+	if changed {
+		b.publishUserSubscription(userID)
+	}
+	return reply
+}
+
+// runCommand applies the command in fields to u, it returns the reply and whether u changed.
+func runCommand(u *UserSubscription, fields []string) (string, bool) {
+	if len(fields) == 0 {
+		return helpText(u.Language), false
+	}
+	arg := strings.Join(fields[1:], " ")
+	replacer := strings.NewReplacer("{1}", arg)
+	switch strings.ToLower(fields[0]) {
+	case "subscribe":
+		id := district.GetDistrictByCity(arg)
+		if id == "" {
+			return replacer.Replace(config.GetText("command.city_not_found", u.Language)), false
+		}
+		if !slices.Contains(u.Cities, id) {
+			u.Cities = append(u.Cities, id)
+		}
+		return strings.NewReplacer("{1}", cityName(id, u.Language)).Replace(config.GetText("command.subscribed", u.Language)), true
+	case "unsubscribe":
+		if arg == "" {
+			u.Cities = nil
+			return config.GetText("command.unsubscribed_all", u.Language), true
+		}
+		id := district.GetDistrictByCity(arg)
+		if id == "" {
+			return replacer.Replace(config.GetText("command.city_not_found", u.Language)), false
+		}
+		if !slices.Contains(u.Cities, id) {
+			return config.GetText("command.not_subscribed", u.Language), false
+		}
+		u.Cities = slices.DeleteFunc(u.Cities, func(city district.ID) bool { return city == id })
+		return strings.NewReplacer("{1}", cityName(id, u.Language)).Replace(config.GetText("command.unsubscribed", u.Language)), true
+	case "list":
+		if len(u.Cities) == 0 {
+			return config.GetText("command.not_subscribed", u.Language), false
+		}
+		var names []string
+		for _, id := range u.Cities {
+			names = append(names, cityName(id, u.Language))
+		}
+		return strings.NewReplacer("{1}", strings.Join(names, ", ")).Replace(config.GetText("command.list", u.Language)), false
+	case "lang":
+		lang := config.Language(strings.ToLower(arg))
+		if !slices.Contains(config.Languages, lang) {
+			return replacer.Replace(config.GetText("command.language_unknown", u.Language)), false
+		}
+		u.Language = lang
+		return config.GetText("command.language_set", u.Language), true
+	}
+	return helpText(u.Language), false
+}
+
+// helpText lists the supported languages in the usage, so locale files need not change when one is added.
+func helpText(lang config.Language) string {
+	codes := make([]string, 0, len(config.Languages))
+	for _, code := range config.Languages {
+		codes = append(codes, string(code))
+	}
+	return strings.NewReplacer("{1}", strings.Join(codes, "|")).Replace(config.GetText("command.help", lang))
+}
+
+func cityName(id district.ID, lang config.Language) string {
+	return district.GetDistricts()[lang][id].SettlementName
+}
@@ -0,0 +1,145 @@
+package bot
+
+import (
+	"encoding/json"
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/phntom/goalert/internal/district"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+func TestHandleCommand_Subscriptions(t *testing.T) {
+	b := setupTestBot(t)
+	const user = "user_id"
+	const dm = "direct_channel_id"
+
+	assert.Contains(t, b.HandleCommand(user, dm, "help"), "/goalert subscribe")
+	assert.Contains(t, b.HandleCommand(user, dm, "help"), "`/goalert lang <en|he|ru|ar|fr|am>`")
+	assert.Equal(t, "יישוב לא מוכר: אטלנטיס", b.HandleCommand(user, dm, "/goalert subscribe אטלנטיס"))
+	assert.Equal(t, "נרשמת להתרעות עבור עין חרוד, ההתרעות יישלחו אליך בהודעה פרטית", b.HandleCommand(user, dm, "subscribe עין חרוד"))
+	assert.Equal(t, "Alerts will be sent to you in English", b.HandleCommand(user, dm, "lang EN"))
+	assert.Equal(t, "You are subscribed to: Ein Harod", b.HandleCommand(user, dm, "list"))
+
+	channels := b.DirectChannels()
+	if assert.Len(t, channels, 1) {
+		assert.Equal(t, dm, channels[0].Id)
+		subscription := b.SubscriptionFor(channels[0])
+		assert.True(t, subscription.Districts[district.ID("999")])
+		assert.Equal(t, "en", string(subscription.Language))
+	}
+
+	msg := NewMessage("instructions", "rockets", 60, "11:40")
+	msg.AppendDistrict("999")
+	msg.AppendDistrict("1231")
+	channel := &model.Channel{Id: dm}
+	post, ok := msg.PostForSubscriber(channel, b.SubscriptionFor(channel), b.ChannelLanguages(channel))
+	assert.True(t, ok)
+	assert.Contains(t, post.Message, "Ein Harod")
+	assert.NotContains(t, post.Message, "Shoshanat")
+
+	assert.Equal(t, "Unsubscribed from all cities", b.HandleCommand(user, dm, "unsubscribe"))
+	assert.Empty(t, b.DirectChannels())
+	assert.Nil(t, b.SubscriptionFor(&model.Channel{Id: dm}))
+}
+
+func TestHandleCommand_ConcurrentSubscriptions(t *testing.T) {
+	b := setupTestBot(t)
+	const user = "user_id"
+	cities := []string{"עין חרוד", "אור יהודה", "גבעת כ''ח", "שריגים - ליאון", "איירפורט סיטי"}
+
+	var wg sync.WaitGroup
+	for _, city := range cities {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			b.HandleCommand(user, "direct_channel_id", "subscribe "+city)
+		}()
+	}
+	wg.Wait()
+
+	assert.ElementsMatch(t, []district.ID{"999", "33", "303", "1266", "1410"}, b.userSubscription(user).Cities)
+}
+
+func TestWebsocketURL(t *testing.T) {
+	assert.Equal(t, "wss://kix.co.il", websocketURL("https://kix.co.il"))
+	assert.Equal(t, "ws://mattermost.chat.svc:8065", websocketURL("http://mattermost.chat.svc:8065"))
+}
+
+// fakePreferences keeps the preferences of the bot user like Mattermost does, answering 404 for an empty category.
+type fakePreferences struct {
+	mu     sync.Mutex
+	values map[string]model.Preference
+}
+
+func (f *fakePreferences) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	const route = "/api/v4/users/test_bot_user_id/preferences"
+	switch {
+	case r.Method == http.MethodGet && r.URL.Path == route+"/"+usersPreferenceCategory:
+		var preferences model.Preferences
+		for _, preference := range f.values {
+			preferences = append(preferences, preference)
+		}
+		if len(preferences) == 0 {
+			http.NotFound(w, r)
+			return
+		}
+		//goland:noinspection GoUnhandledErrorResult
+		json.NewEncoder(w).Encode(preferences) //nolint:errcheck
+	case r.Method == http.MethodPut && r.URL.Path == route, r.Method == http.MethodPost && r.URL.Path == route+"/delete":
+		var preferences model.Preferences
+		if err := json.NewDecoder(r.Body).Decode(&preferences); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		for _, preference := range preferences {
+			if r.Method == http.MethodPut {
+				f.values[preference.Name] = preference
+			} else {
+				delete(f.values, preference.Name)
+			}
+		}
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func TestHandleCommand_StandbyTakesOverSubscriptions(t *testing.T) {
+	server := httptest.NewServer(&fakePreferences{values: make(map[string]model.Preference)})
+	defer server.Close()
+	const user = "user_id"
+	dm := &model.Channel{Id: "direct_channel_id"}
+
+	leader := setupTestBot(t)
+	leader.Client = model.NewAPIv4Client(server.URL)
+	standby := setupTestBot(t)
+	standby.Client = model.NewAPIv4Client(server.URL)
+
+	leader.HandleCommand(user, dm.Id, "subscribe עין חרוד")
+	leader.HandleCommand(user, dm.Id, "lang en")
+	standby.reloadUserSubscriptions()
+	subscription := standby.SubscriptionFor(dm)
+	require.NotNil(t, subscription, "the standby delivers subscriptions made on the leader once it takes over")
+	assert.True(t, subscription.Districts["999"])
+	assert.Equal(t, "en", string(subscription.Language))
+	assert.Len(t, standby.DirectChannels(), 1)
+
+	leader.HandleCommand(user, dm.Id, "unsubscribe")
+	leader.HandleCommand(user, dm.Id, "lang he")
+	standby.reloadUserSubscriptions()
+	assert.Nil(t, standby.SubscriptionFor(dm))
+	assert.Empty(t, standby.DirectChannels())
+
+	// subscriptions restored from the history database are stored in Mattermost when it has none yet
+	restored := setupTestBot(t)
+	restored.Client = model.NewAPIv4Client(server.URL)
+	restored.users[user] = &UserSubscription{UserID: user, ChannelID: dm.Id, Cities: []district.ID{"999"}, Language: "he"}
+	restored.SyncUserSubscriptions()
+	standby.reloadUserSubscriptions()
+	assert.True(t, standby.SubscriptionFor(dm).Districts["999"])
+}
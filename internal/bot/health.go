@@ -0,0 +1,56 @@
+package bot
+
+import (
+	"fmt"
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/shared/mlog"
+	"github.com/phntom/goalert/internal/health"
+	"os"
+)
+
+var healthGauge = map[health.State]float64{
+	health.Down:     0,
+	health.Degraded: 1,
+	health.Healthy:  2,
+}
+
+var healthEmoji = map[health.State]string{
+	health.Down:     ":red_circle:",
+	health.Degraded: ":large_yellow_circle:",
+	health.Healthy:  ":large_green_circle:",
+}
+
+// sourceHealthChanged reports a source going down or recovering, and exits once no source works anymore.
+func (b *Bot) sourceHealthChanged(name string, from health.State, to health.State) {
+	if b.Monitoring.SourceHealth != nil {
+		b.Monitoring.SourceHealth.WithLabelValues(name).Set(healthGauge[to])
+	}
+	mlog.Warn("source health changed", mlog.Any("source", name), mlog.Any("from", from), mlog.Any("to", to))
+	if to != health.Down && from != health.Down {
+		// single failed or slow fetches are common, only going down and recovering is worth a notice
+		return
+	}
+	text := fmt.Sprintf("%s source %s is %s (was %s)", healthEmoji[to], name, to, from)
+	if to == health.Down && b.Health.AllDown() {
+		mlog.Error("all sources are down, exiting")
+		b.OpsMessage(text + ", all sources are down, exiting")
+		b.Disconnect()
+		os.Exit(4)
+	}
+	go b.OpsMessage(text)
+}
+
+// OpsMessage posts an operational notice to the ops channel, when the bot is a member of one and leads.
+func (b *Bot) OpsMessage(text string) {
+	opsChannel := b.opsChannel()
+	if opsChannel == nil || b.Client == nil || !b.IsLeader() {
+		return
+	}
+	post := &model.Post{
+		ChannelId: opsChannel.Id,
+		Message:   text,
+	}
+	if _, err := executeSubmitPost(b, post, nil, opsChannel); err != nil {
+		mlog.Error("failed posting to ops channel", mlog.Err(err))
+	}
+}
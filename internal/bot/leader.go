@@ -0,0 +1,72 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"github.com/mattermost/mattermost/server/public/shared/mlog"
+	"github.com/phntom/goalert/internal/leader"
+	"time"
+)
+
+// ElectLeader makes this replica compete for the lease in store, until it wins it stays on standby:
+// sources keep polling and dedup stays warm, but only local sinks receive alerts.
+func (b *Bot) ElectLeader(store leader.Store, identity string) {
+	b.Leader = &leader.Elector{
+		Store:    store,
+		Identity: identity,
+		OnChange: b.leadershipChanged,
+	}
+	// the Service only routes to the leader, /command changes subscriptions that only the leader delivers
+	b.AddReadinessCheck("leader", b.checkLeader)
+	go b.Leader.Run(context.Background())
+}
+
+func (b *Bot) checkLeader() error {
+	if !b.IsLeader() {
+		return fmt.Errorf("standing by, %s does not hold the lease", b.Leader.Identity)
+	}
+	return nil
+}
+
+// IsLeader reports whether this replica delivers alerts, always true without leader election.
+func (b *Bot) IsLeader() bool {
+	return b.Leader.IsLeader()
+}
+
+// releaseLeadership hands the lease over on shutdown so a standby does not wait for it to expire.
+func (b *Bot) releaseLeadership() {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	b.Leader.Release(ctx)
+}
+
+func (b *Bot) leadershipChanged(leading bool) {
+	if b.Monitoring.Leader != nil {
+		if leading {
+			b.Monitoring.Leader.Set(1)
+		} else {
+			b.Monitoring.Leader.Set(0)
+		}
+	}
+	if !leading {
+		mlog.Warn("lost leadership, standing by", mlog.Any("identity", b.Leader.Identity))
+		return
+	}
+	mlog.Info("became leader, delivering alerts", mlog.Any("identity", b.Leader.Identity))
+	go b.reloadUserSubscriptions()
+	go b.OpsMessage(fmt.Sprintf(":crown: replica %s took over delivering alerts", b.Leader.Identity))
+}
+
+// deliveringSinks are the sinks alerts go to, a standby only keeps its local sinks up to date.
+func (b *Bot) deliveringSinks() []AlertSink {
+	if b.IsLeader() {
+		return b.Sinks
+	}
+	var sinks []AlertSink
+	for _, sink := range b.Sinks {
+		if local, ok := sink.(LocalSink); ok && local.Local() {
+			sinks = append(sinks, sink)
+		}
+	}
+	return sinks
+}
@@ -0,0 +1,56 @@
+package bot
+
+import (
+	"fmt"
+	"github.com/mattermost/mattermost/server/public/shared/mlog"
+	"github.com/phntom/goalert/internal/district"
+	"time"
+)
+
+// unrecognizedReportInterval is how long the ops channel is not told again about the same unrecognized city name.
+const unrecognizedReportInterval = 24 * time.Hour
+
+// MatchCity finds the district of a city name published by source, counting how names get matched so aliases and
+// district data can be added where alerts keep relying on approximate matches.
+func (b *Bot) MatchCity(source string, city string) district.Match {
+	match := district.MatchCity(city)
+	method := string(match.Method)
+	if match.ID == "" {
+		method = "unrecognized"
+	}
+	if b.Monitoring.CityMatches != nil {
+		b.Monitoring.CityMatches.WithLabelValues(source, method).Inc()
+	}
+	if match.ID == "" {
+		b.reportUnrecognized(source, city)
+		return match
+	}
+	if !match.Confident() {
+		mlog.Warn("city matched approximately",
+			mlog.String("city", city),
+			mlog.Any("district", match.ID),
+			mlog.Float("confidence", match.Confidence),
+			mlog.String("source", source),
+		)
+		if b.Monitoring.LowConfidenceCityMatches != nil {
+			b.Monitoring.LowConfidenceCityMatches.WithLabelValues(source).Inc()
+		}
+	}
+	return match
+}
+
+// reportUnrecognized tells the ops channel about a city name alerts were posted with for lack of a district, so the
+// districts or aliases.yaml can be fixed.
+func (b *Bot) reportUnrecognized(source string, city string) {
+	b.unrecognizedMutex.Lock()
+	defer b.unrecognizedMutex.Unlock()
+	if b.unrecognized == nil {
+		b.unrecognized = make(map[string]time.Time)
+	}
+	if reported, ok := b.unrecognized[city]; ok && time.Since(reported) < unrecognizedReportInterval {
+		return
+	}
+	b.unrecognized[city] = time.Now()
+	go b.OpsMessage(fmt.Sprintf(":grey_question: %s sent an alert for the unrecognized location %q, it was posted by "+
+		"that name. Add it to the districts or to aliases.yaml.", source, city))
+}
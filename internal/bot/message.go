@@ -6,12 +6,14 @@ import (
 	"github.com/mattermost/mattermost/server/public/model"
 	"github.com/phntom/goalert/internal/config"
 	"github.com/phntom/goalert/internal/district"
+	"slices"
 	"strconv"
 	"sync"
 	"time"
 )
 
 type Message struct {
+	ID             string
 	Instructions   string
 	Category       string
 	SafetySeconds  uint
@@ -24,10 +26,22 @@ type Message struct {
 	ChannelsPosted []*model.Channel
 	Changed        bool
 	PubDate        string
+	Source         string
+	Received       time.Time
+	Issued         time.Time
+	Sources        map[string]bool
+	// Approximate holds the cities matched with low confidence, by the name the source gave them
+	Approximate map[district.ID]string
+	// Unrecognized lists the city names no district matched, they are posted as the source spelled them
+	Unrecognized []string
+	// the cities the map attached to the posts was drawn for, see MattermostSink.attachMap
+	mapMutex  sync.Mutex
+	mapCities string
 }
 
 func NewMessage(instructions string, category string, safetySeconds int, pubDate string) Message {
 	return Message{
+		ID:             model.NewId(),
 		Instructions:   instructions,
 		Category:       category,
 		SafetySeconds:  uint(safetySeconds),
@@ -40,6 +54,7 @@ func NewMessage(instructions string, category string, safetySeconds int, pubDate
 		ChannelsPosted: nil,
 		Changed:        true,
 		PubDate:        pubDate,
+		Received:       time.Now(),
 	}
 }
 
@@ -55,10 +70,24 @@ func (m *Message) GetHash() string {
 }
 
 func (m *Message) PostForChannel(c *model.Channel) *model.Post {
+	return m.PostForLanguage(c, ChannelToLanguage(c))
+}
+
+// PostForLanguages returns the post for c in the first language followed by the others, posts in a single language
+// come from the prerendered ones.
+func (m *Message) PostForLanguages(c *model.Channel, languages []config.Language) *model.Post {
+	if len(languages) == 1 {
+		return m.PostForLanguage(c, languages[0])
+	}
+	post := RenderLanguages(m, languages)
+	post.ChannelId = c.Id
+	return post
+}
+
+func (m *Message) PostForLanguage(c *model.Channel, lang config.Language) *model.Post {
 	if len(m.Rendered) == 0 {
 		m.Prerender()
 	}
-	lang := ChannelToLanguage(c)
 	post := m.Rendered[lang].Clone()
 	post.ChannelId = c.Id
 	return post
@@ -83,6 +112,9 @@ func (m *Message) PatchData(n *Message) bool {
 		m.Instructions = n.Instructions
 		m.Changed = true
 	}
+	if m.confirm(n) {
+		m.Changed = true
+	}
 	for rocketID := range n.RocketIDs {
 		if m.RocketIDs[rocketID] {
 			// no new information
@@ -100,6 +132,58 @@ func (m *Message) PatchData(n *Message) bool {
 	return false
 }
 
+// WithCities returns a render-only copy of the message limited to cities.
+func (m *Message) WithCities(cities []district.ID) *Message {
+	return &Message{
+		ID:            m.ID,
+		Instructions:  m.Instructions,
+		Category:      m.Category,
+		SafetySeconds: m.SafetySeconds,
+		Cities:        cities,
+		RocketIDs:     m.RocketIDs,
+		Rendered:      make(map[config.Language]*model.Post, len(config.Languages)),
+		Expire:        m.Expire,
+		PubDate:       m.PubDate,
+		Source:        m.Source,
+		Received:      m.Received,
+		Issued:        m.Issued,
+		Sources:       m.Sources,
+		Approximate:   m.Approximate,
+		Unrecognized:  m.Unrecognized,
+	}
+}
+
 func (m *Message) AppendDistrict(districtID district.ID) {
 	m.Cities = append(m.Cities, districtID)
 }
+
+// AppendMatch adds the district of a city name from the source, remembering the name when the match is uncertain.
+func (m *Message) AppendMatch(match district.Match) {
+	m.AppendDistrict(match.ID)
+	if match.ID == "" || match.Confident() {
+		return
+	}
+	if m.Approximate == nil {
+		m.Approximate = make(map[district.ID]string)
+	}
+	m.Approximate[match.ID] = match.Name
+}
+
+// AppendUnrecognized adds a city name no district matched.
+func (m *Message) AppendUnrecognized(name string) {
+	if !slices.Contains(m.Unrecognized, name) {
+		m.Unrecognized = append(m.Unrecognized, name)
+	}
+}
+
+// dedupKeys returns the keys the message is deduplicated by, its districts and the names of unrecognized cities.
+func (m *Message) dedupKeys() []district.ID {
+	keys := slices.Clone(m.Cities)
+	for _, name := range m.Unrecognized {
+		keys = append(keys, district.ID(unrecognizedPrefix+name))
+	}
+	return keys
+}
+
+// unrecognizedPrefix keeps unrecognized city names apart from district ids among the dedup keys.
+const unrecognizedPrefix = "unrecognized:"
@@ -0,0 +1,148 @@
+package bot
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/phntom/goalert/internal/health"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	// stuckAfter is how long a source may wait for AwaitMessage to take its message
+	stuckAfter   = 30 * time.Second
+	cleanupAfter = 30 * time.Second
+	probeTimeout = 3 * time.Second
+)
+
+type componentStatus struct {
+	OK     bool   `json:"ok"`
+	Detail string `json:"detail,omitempty"`
+}
+
+type probeResponse struct {
+	Status     string                     `json:"status"`
+	Components map[string]componentStatus `json:"components"`
+}
+
+// AddReadinessCheck adds a component to /readyz, check returns why it is not ready.
+func (b *Bot) AddReadinessCheck(name string, check func() error) {
+	b.readinessMutex.Lock()
+	defer b.readinessMutex.Unlock()
+	if b.readinessChecks == nil {
+		b.readinessChecks = make(map[string]func() error)
+	}
+	b.readinessChecks[name] = check
+}
+
+// ServeHealthz reports whether the alert loop is alive: AwaitMessage takes submitted messages and Cleanup keeps running.
+func (b *Bot) ServeHealthz(w http.ResponseWriter, r *http.Request) {
+	writeProbe(w, map[string]componentStatus{
+		"alerts":  statusOf(b.checkAlertLoop()),
+		"cleanup": statusOf(b.checkCleanup()),
+	})
+}
+
+// ServeReadyz reports whether the bot can deliver alerts: Mattermost answers, the bot is logged in,
+// at least one source works and every added readiness check passes.
+func (b *Bot) ServeReadyz(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), probeTimeout)
+	defer cancel()
+	checks := map[string]componentStatus{
+		"mattermost": statusOf(b.checkMattermost(ctx)),
+		"login":      statusOf(b.checkLogin(ctx)),
+		"sources":    b.checkSources(),
+	}
+	b.readinessMutex.Lock()
+	for name, check := range b.readinessChecks {
+		checks[name] = statusOf(check())
+	}
+	b.readinessMutex.Unlock()
+	writeProbe(w, checks)
+}
+
+func (b *Bot) checkAlertLoop() error {
+	if !b.awaiting.Load() {
+		return errors.New("not started")
+	}
+	if since := b.submitWaitingSince.Load(); since != 0 {
+		if waiting := time.Since(time.Unix(0, since)); waiting > stuckAfter {
+			return fmt.Errorf("a message is waiting for %s", waiting.Round(time.Second))
+		}
+	}
+	return nil
+}
+
+func (b *Bot) checkCleanup() error {
+	last := b.lastCleanup.Load()
+	if last == 0 {
+		return errors.New("not started")
+	}
+	if since := time.Since(time.Unix(0, last)); since > cleanupAfter {
+		return fmt.Errorf("last pass %s ago", since.Round(time.Second))
+	}
+	return nil
+}
+
+func (b *Bot) checkMattermost(ctx context.Context) error {
+	if b.Client == nil {
+		return errors.New("not connected")
+	}
+	_, _, err := b.Client.GetPing(ctx)
+	return err
+}
+
+func (b *Bot) checkLogin(ctx context.Context) error {
+	if b.Client == nil || !b.IsOnline {
+		return errors.New("not logged in")
+	}
+	_, _, err := b.Client.GetMe(ctx, "")
+	return err
+}
+
+// checkSources is ready while any source is not down, the detail lists the state of each.
+func (b *Bot) checkSources() componentStatus {
+	states := b.Health.States()
+	var details []string
+	working := false
+	for name, state := range states {
+		details = append(details, fmt.Sprintf("%s %s", name, state))
+		if state != health.Down {
+			working = true
+		}
+	}
+	slices.Sort(details)
+	if len(details) == 0 {
+		details = append(details, "no sources running")
+	}
+	return componentStatus{OK: working, Detail: strings.Join(details, ", ")}
+}
+
+func statusOf(err error) componentStatus {
+	if err != nil {
+		return componentStatus{OK: false, Detail: err.Error()}
+	}
+	return componentStatus{OK: true}
+}
+
+func writeProbe(w http.ResponseWriter, components map[string]componentStatus) {
+	response := probeResponse{
+		Status:     "ok",
+		Components: components,
+	}
+	status := http.StatusOK
+	for _, component := range components {
+		if !component.OK {
+			response.Status = "fail"
+			status = http.StatusServiceUnavailable
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	//goland:noinspection GoUnhandledErrorResult
+	json.NewEncoder(w).Encode(response) //nolint:errcheck
+}
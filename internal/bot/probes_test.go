@@ -0,0 +1,99 @@
+package bot
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/phntom/goalert/internal/health"
+	"github.com/phntom/goalert/internal/leader"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func probe(t *testing.T, handler http.HandlerFunc) (int, probeResponse) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	handler(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	var response probeResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+	return rec.Code, response
+}
+
+func TestServeHealthz(t *testing.T) {
+	b := &Bot{}
+	status, response := probe(t, b.ServeHealthz)
+	assert.Equal(t, http.StatusServiceUnavailable, status)
+	assert.Equal(t, componentStatus{OK: false, Detail: "not started"}, response.Components["alerts"])
+
+	b.awaiting.Store(true)
+	b.lastCleanup.Store(time.Now().UnixNano())
+	status, response = probe(t, b.ServeHealthz)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "ok", response.Status)
+
+	b.submitWaitingSince.Store(time.Now().Add(-time.Minute).UnixNano())
+	status, response = probe(t, b.ServeHealthz)
+	assert.Equal(t, http.StatusServiceUnavailable, status)
+	assert.Equal(t, "a message is waiting for 1m0s", response.Components["alerts"].Detail)
+	assert.True(t, response.Components["cleanup"].OK)
+}
+
+func TestServeReadyz(t *testing.T) {
+	loggedIn := true
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v4/system/ping":
+			_, _ = w.Write([]byte(`{"status":"OK"}`))
+		case "/api/v4/users/me":
+			if !loggedIn {
+				w.WriteHeader(http.StatusUnauthorized)
+				_, _ = w.Write([]byte(`{"id":"api.context.session_expired.app_error","status_code":401}`))
+				return
+			}
+			_, _ = w.Write([]byte(`{"id":"bot"}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	b := &Bot{
+		Client:   model.NewAPIv4Client(server.URL),
+		IsOnline: true,
+		Health:   health.NewTracker(),
+	}
+	b.Health.Register("oref")
+	b.Health.Register("telegram")
+	b.Health.SetDown("telegram")
+	authorized := errors.New("not authorized")
+	b.AddReadinessCheck("telegram", func() error { return authorized })
+
+	status, response := probe(t, b.ServeReadyz)
+	assert.Equal(t, http.StatusServiceUnavailable, status)
+	assert.True(t, response.Components["mattermost"].OK)
+	assert.True(t, response.Components["login"].OK)
+	assert.Equal(t, componentStatus{OK: true, Detail: "oref healthy, telegram down"}, response.Components["sources"])
+	assert.Equal(t, componentStatus{OK: false, Detail: "not authorized"}, response.Components["telegram"])
+
+	authorized = nil
+	status, response = probe(t, b.ServeReadyz)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "ok", response.Status)
+
+	b.Leader = &leader.Elector{Identity: "standby"}
+	b.AddReadinessCheck("leader", b.checkLeader)
+	status, response = probe(t, b.ServeReadyz)
+	assert.Equal(t, http.StatusServiceUnavailable, status)
+	assert.Equal(t, componentStatus{OK: false, Detail: "standing by, standby does not hold the lease"}, response.Components["leader"])
+	b.Leader = nil
+
+	loggedIn = false
+	status, response = probe(t, b.ServeReadyz)
+	assert.Equal(t, http.StatusServiceUnavailable, status)
+	assert.False(t, response.Components["login"].OK)
+	assert.True(t, response.Components["mattermost"].OK)
+}
@@ -0,0 +1,93 @@
+package bot
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"github.com/mattermost/mattermost/server/public/shared/mlog"
+	"github.com/phntom/goalert/internal/config"
+	"github.com/phntom/goalert/internal/district"
+	"net/http"
+	"os"
+	"time"
+)
+
+// configCheckInterval is how often WatchConfig looks for changes, a mounted ConfigMap takes longer to update anyway
+const configCheckInterval = 5 * time.Second
+
+// WatchConfig reloads the configuration file at path whenever its content changes.
+// Content is compared rather than modification times, Kubernetes swaps ConfigMap files through symlinks.
+func (b *Bot) WatchConfig(path string) {
+	last, err := os.ReadFile(path)
+	if err != nil {
+		mlog.Error("failed reading configuration", mlog.Err(err), mlog.Any("path", path))
+	}
+	for {
+		time.Sleep(configCheckInterval)
+		data, err := os.ReadFile(path)
+		if err != nil {
+			if !errors.Is(err, os.ErrNotExist) {
+				mlog.Error("failed reading configuration", mlog.Err(err), mlog.Any("path", path))
+			}
+			continue
+		}
+		if bytes.Equal(data, last) {
+			continue
+		}
+		last = data
+		//goland:noinspection GoUnhandledErrorResult
+		b.ReloadConfig(path) //nolint:errcheck
+	}
+}
+
+// ReloadConfig replaces the configuration with the file at path, an invalid file keeps the current one.
+func (b *Bot) ReloadConfig(path string) error {
+	running := b.Settings()
+	settings, pending, err := config.Reload(running, path)
+	if err != nil {
+		mlog.Error("invalid configuration, keeping the current one", mlog.Err(err), mlog.Any("path", path))
+		go b.OpsMessage(":warning: configuration reload failed, keeping the current one:\n```\n" + err.Error() + "\n```")
+		return err
+	}
+	if len(pending) > 0 {
+		mlog.Warn("configuration changes take effect after a restart", mlog.Any("settings", pending))
+	}
+	b.SetConfig(settings)
+	mlog.Info("Configuration reloaded", mlog.Any("path", path))
+	if running.Mattermost.OpsChannel != settings.Mattermost.OpsChannel && b.Client != nil {
+		if err := b.RefreshChannels(); err != nil {
+			mlog.Error("failed refreshing channels", mlog.Err(err))
+		}
+	}
+	return nil
+}
+
+// districtsClient fetches external district files, a slow server delays the next reload rather than alerts.
+var districtsClient = &http.Client{Timeout: 30 * time.Second}
+
+// WatchDistricts reads the external district files again every districts.reload_interval, following changes of the
+// source and the interval in the configuration.
+func (b *Bot) WatchDistricts() {
+	for {
+		time.Sleep(b.Settings().Districts.ReloadInterval)
+		//goland:noinspection GoUnhandledErrorResult
+		b.ReloadDistricts() //nolint:errcheck
+	}
+}
+
+// ReloadDistricts loads the districts from the configured source, on failure the districts in use are kept.
+func (b *Bot) ReloadDistricts() error {
+	source := b.Settings().Districts.Source
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	changed, err := district.Load(ctx, districtsClient, source)
+	if err != nil {
+		mlog.Error("failed loading districts, keeping the current ones", mlog.Err(err), mlog.Any("source", source))
+		go b.OpsMessage(":warning: districts reload failed, keeping the current ones:\n```\n" + err.Error() + "\n```")
+		return err
+	}
+	if changed {
+		mlog.Info("Districts reloaded", mlog.Any("source", source))
+	}
+	return nil
+}
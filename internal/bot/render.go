@@ -11,8 +11,119 @@ import (
 )
 
 func Render(msg *Message, lang config.Language) *model.Post {
+	return RenderLanguages(msg, []config.Language{lang})
+}
+
+// RenderLanguages renders msg into a single post with a section per language, in the given order. Each language
+// gets its own attachment with the cities named in it, and the text of all of them makes up the message so
+// mentions and hashtags in every language notify. In posts mixing languages right-to-left text is isolated line by
+// line, so numbers and punctuation stay in place next to left-to-right text.
+func RenderLanguages(msg *Message, languages []config.Language) *model.Post {
 	ack := msg.SafetySeconds >= 60
-	cities, hashtags, mentions, legacy := district.CitiesToHashtagsMentionsLegacy(msg.Cities, lang)
+	bidi := len(languages) > 1
+	urgent := "urgent"
+	if msg.Category == "lockdown" || msg.Category == "biohazard" {
+		urgent = "important"
+	} else if msg.Instructions == "uav_event_over" {
+		urgent = ""
+	}
+	var texts []string
+	var attachments []*model.SlackAttachment
+	for _, lang := range languages {
+		isolate := func(text string) string {
+			if !bidi || !lang.RTL() {
+				return text
+			}
+			return isolateRTL(text)
+		}
+		cities, hashtags, mentions, legacy := district.CitiesToHashtagsMentionsLegacy(msg.Cities, lang)
+		title := RenderTitle(msg, lang)
+		instructions := RenderInstructions(msg, lang)
+		text := instructions
+		if approximate := RenderApproximate(msg, lang); approximate != "" {
+			text += "\n" + approximate
+		}
+		fields := CitiesToFields(cities)
+		if len(msg.Unrecognized) > 0 {
+			unrecognized := config.GetText("message.unrecognized", lang)
+			fields = append(fields, &model.SlackAttachmentField{
+				Title: unrecognized,
+				Value: strings.Join(msg.Unrecognized, "\n"),
+				Short: true,
+			})
+			for _, name := range msg.Unrecognized {
+				legacy = append(legacy, fmt.Sprintf("%s (%s)", name, unrecognized))
+			}
+		}
+		for _, field := range fields {
+			field.Title = isolate(field.Title)
+			if value, ok := field.Value.(string); ok {
+				field.Value = isolate(value)
+			}
+		}
+		if msg.Instructions != "uav_event_over" {
+			// the mentions line is left alone, isolation characters would stick to the keywords and hashtags
+			texts = append(texts, fmt.Sprintf("%s\n%s\n%s %s",
+				isolate(strings.Join(legacy, ", ")),
+				isolate(instructions),
+				strings.Join(mentions, " "),
+				strings.Join(hashtags, " "),
+			))
+		}
+		legacyStr := fmt.Sprintf("%s %s %s",
+			strings.Join(mentions, " "),
+			strings.Join(legacy, ", "),
+			instructions,
+		)
+		footer := ""
+		if sources := msg.ConfirmedBy(); len(sources) > 0 {
+			footer = strings.NewReplacer("{1}", strings.Join(sources, ", ")).Replace(config.GetText("message.confirmedBy", lang))
+		}
+		attachments = append(attachments, &model.SlackAttachment{
+			Title:    isolate(title),
+			Text:     isolate(text),
+			Fallback: legacyStr,
+			Color:    "#CF1434",
+			Fields:   fields,
+			Footer:   isolate(footer),
+		})
+	}
+	replyToId := ""
+	//goland:noinspection GoDeprecation
+	return &model.Post{
+		Message: strings.Join(texts, "\n\n"),
+		RootId:  replyToId,
+		Props: map[string]any{
+			"attachments": attachments,
+		},
+		Metadata: &model.PostMetadata{
+			Priority: &model.PostPriority{
+				Priority:     model.NewString(urgent),
+				RequestedAck: model.NewBool(ack),
+			},
+		},
+	}
+}
+
+// Unicode directional formatting characters, see https://www.unicode.org/reports/tr9/
+const (
+	rightToLeftIsolate    = "\u2067"
+	popDirectionalIsolate = "\u2069"
+)
+
+// isolateRTL wraps every non-empty line of text in a right-to-left isolate, isolates end at line breaks.
+func isolateRTL(text string) string {
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		if line != "" {
+			lines[i] = rightToLeftIsolate + line + popDirectionalIsolate
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// RenderTitle returns the localized category of the alert, with the number of salvos when there were several.
+func RenderTitle(msg *Message, lang config.Language) string {
 	title := ""
 	if msg.Category != "" {
 		title = config.GetText(fmt.Sprintf("message.%s", msg.Category), lang)
@@ -20,6 +131,11 @@ func Render(msg *Message, lang config.Language) *model.Post {
 			title = fmt.Sprintf("%s (%d)", title, len(msg.RocketIDs))
 		}
 	}
+	return title
+}
+
+// RenderInstructions returns the localized instructions including the time left to reach shelter.
+func RenderInstructions(msg *Message, lang config.Language) string {
 	secondsReplacer := strings.NewReplacer(
 		"{1}", "",
 		"{2}", "",
@@ -33,49 +149,26 @@ func Render(msg *Message, lang config.Language) *model.Post {
 			"{3}", config.GetText(secondsTag+"Suffix", lang),
 		)
 	}
-	instructions := secondsReplacer.Replace(config.GetText(fmt.Sprintf("message.%s", msg.Instructions), lang))
-	fields := CitiesToFields(cities)
-	text := fmt.Sprintf("%s\n%s\n%s %s",
-		strings.Join(legacy, ", "),
-		instructions,
-		strings.Join(mentions, " "),
-		strings.Join(hashtags, " "),
-	)
-	legacyStr := fmt.Sprintf("%s %s %s",
-		strings.Join(mentions, " "),
-		strings.Join(legacy, ", "),
-		instructions,
-	)
-	replyToId := ""
-	urgent := "urgent"
-	if msg.Category == "lockdown" || msg.Category == "biohazard" {
-		urgent = "important"
-	} else if msg.Instructions == "uav_event_over" {
-		urgent = ""
-		text = ""
+	return secondsReplacer.Replace(config.GetText(fmt.Sprintf("message.%s", msg.Instructions), lang))
+}
+
+// RenderApproximate notes the cities matched with low confidence next to the names the source gave them, empty when
+// every city was matched confidently.
+func RenderApproximate(msg *Message, lang config.Language) string {
+	if len(msg.Approximate) == 0 {
+		return ""
 	}
-	//goland:noinspection GoDeprecation
-	return &model.Post{
-		Message: text,
-		RootId:  replyToId,
-		Props: map[string]any{
-			"attachments": []*model.SlackAttachment{
-				{
-					Title:    title,
-					Text:     instructions,
-					Fallback: legacyStr,
-					Color:    "#CF1434",
-					Fields:   fields,
-				},
-			},
-		},
-		Metadata: &model.PostMetadata{
-			Priority: &model.PostPriority{
-				Priority:     model.NewString(urgent),
-				RequestedAck: model.NewBool(ack),
-			},
-		},
+	districts := district.GetDistricts()
+	var matches []string
+	for _, city := range msg.Cities {
+		if name, ok := msg.Approximate[city]; ok {
+			matches = append(matches, fmt.Sprintf("%q → %s", name, districts[lang][city].SettlementName))
+		}
+	}
+	if len(matches) == 0 {
+		return ""
 	}
+	return strings.NewReplacer("{1}", strings.Join(matches, ", ")).Replace(config.GetText("message.approximate", lang))
 }
 
 func CitiesToFields(cities map[string][]string) []*model.SlackAttachmentField {
@@ -94,17 +187,13 @@ func CitiesToFields(cities map[string][]string) []*model.SlackAttachmentField {
 	return fields
 }
 
+// ChannelToLanguage guesses the channel's language from the script of its display name, English when none of the
+// languages' scripts show up.
 func ChannelToLanguage(channel *model.Channel) config.Language {
-	characterSets := map[config.Language]*unicode.RangeTable{
-		"he": unicode.Hebrew,
-		"ar": unicode.Arabic,
-		"ru": unicode.Cyrillic,
-	}
-
 	for _, r := range channel.DisplayName {
-		for lang, set := range characterSets {
-			if unicode.In(r, set) {
-				return lang
+		for _, info := range config.LanguageInfos() {
+			if script := unicode.Scripts[info.Script]; script != nil && info.Script != "Latin" && unicode.In(r, script) {
+				return info.Code
 			}
 		}
 	}
@@ -5,12 +5,13 @@ import (
 	"github.com/mattermost/mattermost/server/public/model"
 	"github.com/phntom/goalert/internal/config"
 	"github.com/phntom/goalert/internal/district"
+	"github.com/stretchr/testify/assert"
 	"testing"
 )
 
 func TestRender(t *testing.T) {
 	type args struct {
-		msg  Message
+		msg  *Message
 		lang config.Language
 	}
 	tests := []struct {
@@ -21,7 +22,7 @@ func TestRender(t *testing.T) {
 		{
 			name: "rocket alert hebrew simple",
 			args: args{
-				msg: Message{
+				msg: &Message{
 					Instructions:  "instructions",
 					Category:      "rockets",
 					SafetySeconds: 90,
@@ -44,7 +45,7 @@ func TestRender(t *testing.T) {
 		{
 			name: "rocket alert english simple",
 			args: args{
-				msg: Message{
+				msg: &Message{
 					Instructions:  "instructions",
 					Category:      "rockets",
 					SafetySeconds: 90,
@@ -67,7 +68,7 @@ func TestRender(t *testing.T) {
 		{
 			name: "rocket alert russian simple",
 			args: args{
-				msg: Message{
+				msg: &Message{
 					Instructions:  "instructions",
 					Category:      "rockets",
 					SafetySeconds: 90,
@@ -90,7 +91,7 @@ func TestRender(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got := Render(&tt.args.msg, tt.args.lang)
+			got := Render(tt.args.msg, tt.args.lang)
 			if len(tt.want.Attachments()) == 0 {
 				//goland:noinspection GoDeprecation
 				got.Props = nil
@@ -102,3 +103,75 @@ func TestRender(t *testing.T) {
 		})
 	}
 }
+
+func TestRenderLanguages(t *testing.T) {
+	msg := NewMessage("instructions", "rockets", 90, "11:40")
+	msg.AppendDistrict("999")
+
+	post := RenderLanguages(&msg, []config.Language{"he", "en"})
+	assert.Equal(t, "\u2067עין חרוד\u2069\n\u2067תוך 90 שניות היכנסו למרחב המוגן\u2069\nצבעאדוםעיןחרוד #עין_חרוד\n\n"+
+		"Ein Harod\nYou have 90 seconds to seek shelter\nOrefAlarmEinHarod #Ein_Harod", post.Message)
+	attachments := post.Attachments()
+	if assert.Len(t, attachments, 2) {
+		assert.Equal(t, "\u2067תוך 90 שניות היכנסו למרחב המוגן\u2069", attachments[0].Text)
+		assert.Equal(t, "\u2067עין חרוד\u2069", attachments[0].Fields[0].Title)
+		assert.Equal(t, "You have 90 seconds to seek shelter", attachments[1].Text)
+		assert.Equal(t, "Ein Harod", attachments[1].Fields[0].Title)
+	}
+	assert.Equal(t, "urgent", *post.Metadata.Priority.Priority)
+
+	single := RenderLanguages(&msg, []config.Language{"he"})
+	assert.Equal(t, Render(&msg, "he").Message, single.Message)
+	assert.NotContains(t, single.Message, "\u2067", "single language posts are not isolated")
+
+	over := NewMessage("uav_event_over", "uav", 0, "11:40")
+	over.AppendDistrict("999")
+	assert.Empty(t, RenderLanguages(&over, []config.Language{"he", "ar"}).Message)
+}
+
+func TestRenderApproximate(t *testing.T) {
+	msg := NewMessage("instructions", "rockets", 90, "11:40")
+	msg.AppendMatch(district.Match{ID: "999", Name: "עין חרוד", Method: district.MatchExact, Confidence: 1})
+	msg.AppendMatch(district.Match{ID: "738", Name: "מטולא", Method: district.MatchFuzzy, Confidence: 0.8})
+	msg.AppendMatch(district.Match{Name: "nowhere"})
+	assert.Equal(t, map[district.ID]string{"738": "מטולא"}, msg.Approximate)
+
+	assert.Equal(t, `Approximately matched, please verify: "מטולא" → Metulla`, RenderApproximate(&msg, "en"))
+	attachments := Render(&msg, "en").Attachments()
+	if assert.Len(t, attachments, 1) {
+		assert.Equal(t, "You have 90 seconds to seek shelter\n"+RenderApproximate(&msg, "en"), attachments[0].Text)
+	}
+	assert.Empty(t, RenderApproximate(msg.WithCities([]district.ID{"999"}), "en"), "only the cities shown are noted")
+}
+
+func TestRender_Unrecognized(t *testing.T) {
+	msg := NewMessage("instructions", "rockets", 0, "11:40")
+	msg.AppendUnrecognized("ישוב דמיוני")
+	msg.AppendUnrecognized("ישוב דמיוני")
+	msg.AppendDistrict("999")
+
+	post := Render(&msg, "he")
+	assert.Contains(t, post.Message, "עין חרוד, ישוב דמיוני (מיקום לא מזוהה)")
+	fields := post.Attachments()[0].Fields
+	if assert.Len(t, fields, 2) {
+		assert.Equal(t, "מיקום לא מזוהה", fields[1].Title)
+		assert.Equal(t, "ישוב דמיוני", fields[1].Value)
+	}
+}
+
+func TestMessage_PostForLanguages(t *testing.T) {
+	msg := NewMessage("instructions", "rockets", 60, "11:40")
+	msg.AppendDistrict("999")
+	post := msg.PostForLanguages(&model.Channel{Id: "channel"}, []config.Language{"ar", "he"})
+	assert.Equal(t, "channel", post.ChannelId)
+	assert.Len(t, post.Attachments(), 2)
+	single := msg.PostForLanguages(&model.Channel{Id: "channel"}, []config.Language{"en"})
+	assert.Equal(t, msg.Rendered["en"].Message, single.Message)
+}
+
+func TestChannelToLanguage(t *testing.T) {
+	assert.Equal(t, config.Language("he"), ChannelToLanguage(&model.Channel{DisplayName: "התרעות צפון"}))
+	assert.Equal(t, config.Language("ru"), ChannelToLanguage(&model.Channel{DisplayName: "Тревоги"}))
+	assert.Equal(t, config.Language("am"), ChannelToLanguage(&model.Channel{DisplayName: "ማንቂያዎች"}))
+	assert.Equal(t, config.Language("en"), ChannelToLanguage(&model.Channel{DisplayName: "Alertes"}))
+}
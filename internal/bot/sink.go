@@ -0,0 +1,73 @@
+package bot
+
+import (
+	"github.com/mattermost/mattermost/server/public/shared/mlog"
+)
+
+// AlertSink is an output the bot delivers alerts to, e.g. Mattermost channels, webhooks or feeds.
+// Create is called once per new alert, Update whenever PatchData added information to it,
+// React when the alert deserves an emoji marker and Retract when it should be withdrawn.
+// Implementations must not block for long, AwaitMessage calls them in line.
+type AlertSink interface {
+	Name() string
+	Create(m *Message) error
+	Update(m *Message) error
+	React(m *Message, emoji string) error
+	Retract(m *Message) error
+}
+
+// LocalSink is implemented by sinks that only keep alerts within this replica, such as feeds served over HTTP.
+// They keep receiving alerts on a standby replica, so they are complete when it takes over.
+type LocalSink interface {
+	AlertSink
+	Local() bool
+}
+
+// AddSink registers an additional output for every alert passing through AwaitMessage.
+func (b *Bot) AddSink(sink AlertSink) {
+	b.Sinks = append(b.Sinks, sink)
+}
+
+// createAlert delivers a new alert to all sinks and reacts to the categories marked with an emoji. Sinks only hear
+// about it again through PatchPosts, once PatchData added something.
+func (b *Bot) createAlert(m *Message) {
+	for _, sink := range b.deliveringSinks() {
+		err := sink.Create(m)
+		if err != nil {
+			mlog.Error("failed delivering alert", mlog.Any("sink", sink.Name()), mlog.Err(err))
+		}
+		if m.Category == "uav" || m.Category == "infiltration" {
+			go func(sink AlertSink) {
+				if err := sink.React(m, m.Category+"-alert"); err != nil {
+					mlog.Error("failed reacting to alert", mlog.Any("sink", sink.Name()), mlog.Err(err))
+				}
+			}(sink)
+		}
+	}
+}
+
+// createAlertOnly delivers a new alert to all sinks without the follow-up reaction.
+func (b *Bot) createAlertOnly(m *Message) {
+	for _, sink := range b.deliveringSinks() {
+		if err := sink.Create(m); err != nil {
+			mlog.Error("failed delivering alert", mlog.Any("sink", sink.Name()), mlog.Err(err))
+		}
+	}
+}
+
+// RetractAlert withdraws a previously delivered alert from all sinks.
+func (b *Bot) RetractAlert(m *Message) {
+	for _, sink := range b.deliveringSinks() {
+		if err := sink.Retract(m); err != nil {
+			mlog.Error("failed retracting alert", mlog.Any("sink", sink.Name()), mlog.Err(err))
+		}
+	}
+}
+
+func (m *Message) PatchPosts(b *Bot) {
+	for _, sink := range b.deliveringSinks() {
+		if err := sink.Update(m); err != nil {
+			mlog.Error("failed updating alert", mlog.Any("sink", sink.Name()), mlog.Err(err))
+		}
+	}
+}
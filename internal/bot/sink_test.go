@@ -0,0 +1,145 @@
+package bot
+
+import (
+	"github.com/phntom/goalert/internal/leader"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recordingSink captures every call made by the bot so fan-out can be asserted.
+type recordingSink struct {
+	mu      sync.Mutex
+	created []*Message
+	updated []*Message
+	reacted []string
+}
+
+func (s *recordingSink) Name() string {
+	return "recording"
+}
+
+func (s *recordingSink) Create(m *Message) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.created = append(s.created, m)
+	return nil
+}
+
+func (s *recordingSink) Update(m *Message) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.updated = append(s.updated, m)
+	return nil
+}
+
+func (s *recordingSink) React(m *Message, emoji string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.reacted = append(s.reacted, emoji)
+	return nil
+}
+
+func (s *recordingSink) Retract(m *Message) error {
+	return nil
+}
+
+func (s *recordingSink) counts() (int, int, int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.created), len(s.updated), len(s.reacted)
+}
+
+func TestAwaitMessage_FanOutToSinks(t *testing.T) {
+	tests := []struct {
+		name        string
+		cities      int
+		category    string
+		wantCreated int
+		wantReacted int
+	}{
+		{"single post", 5, "rockets", 1, 0},
+		{"split post", 45, "rockets", 3, 0},
+		{"uav reaction", 3, "uav", 1, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := setupTestBot(t)
+			b.Sinks = nil
+			first := &recordingSink{}
+			second := &recordingSink{}
+			b.AddSink(first)
+			b.AddSink(second)
+
+			runAwaitMessageTest(t, b, createTestMessage(t, tt.cities, tt.category, "instructions", 60))
+			time.Sleep(100 * time.Millisecond)
+
+			for _, sink := range []*recordingSink{first, second} {
+				created, updated, reacted := sink.counts()
+				if created != tt.wantCreated {
+					t.Errorf("created = %d, want %d", created, tt.wantCreated)
+				}
+				if updated != 0 {
+					t.Errorf("updated = %d, new alerts have nothing to update", updated)
+				}
+				if reacted != tt.wantReacted {
+					t.Errorf("reacted = %d, want %d", reacted, tt.wantReacted)
+				}
+			}
+		})
+	}
+}
+
+func TestAwaitMessage_UpdatesOnlyWithNewData(t *testing.T) {
+	b := setupTestBot(t)
+	b.Sinks = nil
+	sink := &recordingSink{}
+	b.AddSink(sink)
+	go b.AwaitMessage()
+	defer close(b.alertFeed)
+
+	first := createTestMessage(t, 3, "rockets", "instructions", 60)
+	b.SubmitMessage(first)
+	repeated := createTestMessage(t, 3, "rockets", "instructions", 60)
+	b.SubmitMessage(repeated)
+	time.Sleep(50 * time.Millisecond)
+	if created, updated, _ := sink.counts(); created != 1 || updated != 0 {
+		t.Errorf("created, updated = %d, %d, want 1, 0", created, updated)
+	}
+
+	changed := createTestMessage(t, 3, "rockets", "new instructions", 60)
+	b.SubmitMessage(changed)
+	time.Sleep(50 * time.Millisecond)
+	if created, updated, _ := sink.counts(); created != 1 || updated != 1 {
+		t.Errorf("created, updated = %d, %d, want 1, 1", created, updated)
+	}
+}
+
+// localSink is a recordingSink kept up to date on standby replicas.
+type localSink struct {
+	recordingSink
+}
+
+func (s *localSink) Local() bool {
+	return true
+}
+
+func TestAwaitMessage_StandbyDeliversLocally(t *testing.T) {
+	b := setupTestBot(t)
+	b.Sinks = nil
+	b.Leader = &leader.Elector{Identity: "standby"}
+	remote := &recordingSink{}
+	local := &localSink{}
+	b.AddSink(remote)
+	b.AddSink(local)
+
+	runAwaitMessageTest(t, b, createTestMessage(t, 5, "rockets", "instructions", 60))
+	time.Sleep(100 * time.Millisecond)
+
+	if created, _, _ := remote.counts(); created != 0 {
+		t.Errorf("standby delivered %d alerts to a remote sink", created)
+	}
+	if created, _, _ := local.counts(); created != 1 {
+		t.Errorf("local created = %d, want 1", created)
+	}
+}
@@ -0,0 +1,209 @@
+package bot
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/shared/mlog"
+	"github.com/phntom/goalert/internal/config"
+	"github.com/phntom/goalert/internal/district"
+	"maps"
+	"slices"
+	"time"
+)
+
+const (
+	dedupStateKey = "bot.dedup"
+	usersStateKey = "bot.users"
+)
+
+// StateStore persists state that has to survive a restart, values are JSON encoded.
+type StateStore interface {
+	SaveState(key string, value any) error
+	LoadState(key string, value any) (bool, error)
+}
+
+// SaveState persists value under key when a StateStore is configured.
+func (b *Bot) SaveState(key string, value any) {
+	if b.State == nil {
+		return
+	}
+	if err := b.State.SaveState(key, value); err != nil {
+		mlog.Error("failed saving state", mlog.Any("key", key), mlog.Err(err))
+	}
+}
+
+// LoadState fills value from the StateStore, it returns false when nothing was stored.
+func (b *Bot) LoadState(key string, value any) bool {
+	if b.State == nil {
+		return false
+	}
+	found, err := b.State.LoadState(key, value)
+	if err != nil {
+		mlog.Error("failed loading state", mlog.Any("key", key), mlog.Err(err))
+		return false
+	}
+	return found
+}
+
+type messageSnapshot struct {
+	ID            string                 `json:"id"`
+	Instructions  string                 `json:"instructions"`
+	Category      string                 `json:"category"`
+	SafetySeconds uint                   `json:"safety_seconds"`
+	Cities        []district.ID          `json:"cities"`
+	RocketIDs     map[string]bool        `json:"rocket_ids"`
+	Expire        time.Time              `json:"expire"`
+	PostIDs       []string               `json:"post_ids"`
+	ChannelIDs    []string               `json:"channel_ids"`
+	PubDate       string                 `json:"pubdate"`
+	Source        string                 `json:"source"`
+	Received      time.Time              `json:"received"`
+	Issued        time.Time              `json:"issued"`
+	Sources       map[string]bool        `json:"sources,omitempty"`
+	Approximate   map[district.ID]string `json:"approximate,omitempty"`
+	Unrecognized  []string               `json:"unrecognized,omitempty"`
+}
+
+type dedupSnapshot struct {
+	Messages  []messageSnapshot      `json:"messages"`
+	Districts map[district.ID]string `json:"districts"`
+}
+
+// snapshotDedup saves the live alerts and their posts whenever they changed since the last snapshot.
+func (b *Bot) snapshotDedup() {
+	if b.State == nil {
+		return
+	}
+	snapshot := dedupSnapshot{Districts: make(map[district.ID]string)}
+	seen := make(map[*Message]bool)
+	b.dedupMutex.Lock()
+	for id, m := range b.dedup {
+		snapshot.Districts[id] = m.ID
+		if seen[m] {
+			continue
+		}
+		seen[m] = true
+		m.PostMutex.Lock()
+		s := messageSnapshot{
+			ID:            m.ID,
+			Instructions:  m.Instructions,
+			Category:      m.Category,
+			SafetySeconds: m.SafetySeconds,
+			Cities:        slices.Clone(m.Cities),
+			RocketIDs:     maps.Clone(m.RocketIDs),
+			Expire:        m.Expire,
+			PostIDs:       slices.Clone(m.PostIDs),
+			PubDate:       m.PubDate,
+			Source:        m.Source,
+			Received:      m.Received,
+			Issued:        m.Issued,
+			Sources:       maps.Clone(m.Sources),
+			Approximate:   maps.Clone(m.Approximate),
+			Unrecognized:  slices.Clone(m.Unrecognized),
+		}
+		for _, channel := range m.ChannelsPosted {
+			s.ChannelIDs = append(s.ChannelIDs, channel.Id)
+		}
+		m.PostMutex.Unlock()
+		snapshot.Messages = append(snapshot.Messages, s)
+	}
+	b.dedupMutex.Unlock()
+	slices.SortFunc(snapshot.Messages, func(a, b messageSnapshot) int {
+		return a.Received.Compare(b.Received)
+	})
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		mlog.Error("failed encoding dedup snapshot", mlog.Err(err))
+		return
+	}
+	if bytes.Equal(data, b.lastSnapshot) {
+		return
+	}
+	b.lastSnapshot = data
+	b.SaveState(dedupStateKey, json.RawMessage(data))
+}
+
+// RestoreState reloads live alerts and user subscriptions saved before a restart,
+// it has to run after FindBotChannel so posts can be matched to their channels again.
+func (b *Bot) RestoreState() {
+	var users []*UserSubscription
+	if b.LoadState(usersStateKey, &users) {
+		b.usersMutex.Lock()
+		for _, u := range users {
+			b.users[u.UserID] = u
+		}
+		b.usersMutex.Unlock()
+		for _, u := range users {
+			if u.ChannelID != "" {
+				b.setSubscription(u.ChannelID, u.subscription())
+			}
+		}
+	}
+
+	var snapshot dedupSnapshot
+	if !b.LoadState(dedupStateKey, &snapshot) {
+		return
+	}
+	alertChannels := b.AlertChannels()
+	channels := make(map[string]*model.Channel, len(alertChannels))
+	for _, channel := range alertChannels {
+		channels[channel.Id] = channel
+	}
+	for _, channel := range b.DirectChannels() {
+		channels[channel.Id] = channel
+	}
+	messages := make(map[string]*Message, len(snapshot.Messages))
+	for _, s := range snapshot.Messages {
+		if time.Now().After(s.Expire) || len(s.PostIDs) != len(s.ChannelIDs) {
+			continue
+		}
+		m := &Message{
+			ID:            s.ID,
+			Instructions:  s.Instructions,
+			Category:      s.Category,
+			SafetySeconds: s.SafetySeconds,
+			Cities:        s.Cities,
+			RocketIDs:     s.RocketIDs,
+			Rendered:      make(map[config.Language]*model.Post, len(config.Languages)),
+			Expire:        s.Expire,
+			PubDate:       s.PubDate,
+			Source:        s.Source,
+			Received:      s.Received,
+			Issued:        s.Issued,
+			Sources:       s.Sources,
+			Approximate:   s.Approximate,
+			Unrecognized:  s.Unrecognized,
+		}
+		if m.RocketIDs == nil {
+			m.RocketIDs = make(map[string]bool)
+		}
+		for i, channelID := range s.ChannelIDs {
+			channel, ok := channels[channelID]
+			if !ok {
+				// the bot left the channel while it was down
+				continue
+			}
+			m.PostIDs = append(m.PostIDs, s.PostIDs[i])
+			m.ChannelsPosted = append(m.ChannelsPosted, channel)
+		}
+		messages[m.ID] = m
+	}
+	b.dedupMutex.Lock()
+	for id, messageID := range snapshot.Districts {
+		if m, ok := messages[messageID]; ok {
+			b.dedup[id] = m
+		}
+	}
+	b.dedupMutex.Unlock()
+	mlog.Info("Restored live alerts", mlog.Any("alerts", len(messages)))
+}
+
+// saveUsers stores the user subscriptions, callers hold usersMutex so snapshots are saved in the order they were taken.
+func (b *Bot) saveUsers() {
+	users := make([]*UserSubscription, 0, len(b.users))
+	for _, id := range slices.Sorted(maps.Keys(b.users)) {
+		users = append(users, b.users[id])
+	}
+	b.SaveState(usersStateKey, users)
+}
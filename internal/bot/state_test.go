@@ -0,0 +1,79 @@
+package bot
+
+import (
+	"encoding/json"
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/phntom/goalert/internal/district"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+// memoryState is a StateStore keeping JSON in a map, the same encoding the real store uses.
+type memoryState map[string][]byte
+
+func (s memoryState) SaveState(key string, value any) error {
+	data, err := json.Marshal(value)
+	s[key] = data
+	return err
+}
+
+func (s memoryState) LoadState(key string, value any) (bool, error) {
+	data, ok := s[key]
+	if !ok {
+		return false, nil
+	}
+	return true, json.Unmarshal(data, value)
+}
+
+func TestRestoreState(t *testing.T) {
+	state := memoryState{}
+	b := setupTestBot(t)
+	b.State = state
+	channel := b.Channels[0]
+
+	msg := NewMessage("instructions", "rockets", 60, "11:40")
+	msg.Expire = time.Now().Add(time.Minute)
+	msg.AppendDistrict("999")
+	msg.AppendDistrict("1231")
+	msg.RocketIDs["rocket1"] = true
+	msg.PostIDs = []string{"post1"}
+	msg.ChannelsPosted = []*model.Channel{channel}
+	expired := NewMessage("instructions", "rockets", 60, "11:39")
+	expired.Expire = time.Now().Add(-time.Second)
+	expired.AppendDistrict("241")
+	b.dedup["999"] = &msg
+	b.dedup["1231"] = &msg
+	b.dedup["241"] = &expired
+	b.HandleCommand("user", "dm", "subscribe עין חרוד")
+
+	b.snapshotDedup()
+	saved := string(state[dedupStateKey])
+	b.snapshotDedup()
+	assert.Equal(t, saved, string(state[dedupStateKey]))
+
+	restored := setupTestBot(t)
+	restored.State = state
+	restored.Channels = []*model.Channel{channel}
+	restored.RestoreState()
+
+	require.Contains(t, restored.dedup, district.ID("999"))
+	assert.Same(t, restored.dedup["999"], restored.dedup["1231"])
+	assert.NotContains(t, restored.dedup, district.ID("241"))
+	m := restored.dedup["999"]
+	assert.Equal(t, msg.ID, m.ID)
+	assert.Equal(t, []string{"post1"}, m.PostIDs)
+	assert.Equal(t, []*model.Channel{channel}, m.ChannelsPosted)
+	assert.True(t, m.RocketIDs["rocket1"])
+
+	// a repeat of the same salvo after the restart patches the restored alert instead of posting again
+	repeat := NewMessage("instructions", "rockets", 60, "11:40")
+	repeat.AppendDistrict("999")
+	prevMsgs, citiesNotFound := restored.GetPrevMsgs(&repeat)
+	assert.Empty(t, citiesNotFound)
+	assert.Same(t, m, prevMsgs["999"])
+
+	assert.Equal(t, "dm", restored.DirectChannels()[0].Id)
+	assert.True(t, restored.SubscriptionFor(&model.Channel{Id: "dm"}).Districts["999"])
+}
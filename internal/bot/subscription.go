@@ -0,0 +1,164 @@
+package bot
+
+import (
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/phntom/goalert/internal/config"
+	"github.com/phntom/goalert/internal/district"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// subscriptionPrefix starts the line in a channel header or purpose that narrows down what it receives, e.g.
+//
+//	goalert: areas=שרון,HaAmakim; districts=999; categories=rockets,uav
+//
+// and may declare the channel's languages in the order they appear in posts, e.g. goalert: lang=he,ar
+const subscriptionPrefix = "goalert:"
+
+// Subscription restricts the alerts delivered to a channel. Empty sets do not restrict.
+// Language overrides the language detected from the channel name when set, Secondary adds more below it.
+type Subscription struct {
+	Districts  map[district.ID]bool
+	Areas      map[string]bool
+	AreaIDs    map[int]bool
+	Categories map[string]bool
+	Language   config.Language
+	Secondary  []config.Language
+}
+
+// ParseSubscription reads the goalert block out of a channel header or purpose, nil means no restriction.
+func ParseSubscription(text string) *Subscription {
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(strings.ToLower(line), subscriptionPrefix) {
+			continue
+		}
+		s := &Subscription{
+			Districts:  make(map[district.ID]bool),
+			Areas:      make(map[string]bool),
+			AreaIDs:    make(map[int]bool),
+			Categories: make(map[string]bool),
+		}
+		for _, part := range strings.Split(line[len(subscriptionPrefix):], ";") {
+			key, values, found := strings.Cut(part, "=")
+			if !found {
+				continue
+			}
+			for _, value := range strings.Split(values, ",") {
+				value = strings.TrimSpace(value)
+				if value == "" {
+					continue
+				}
+				switch strings.ToLower(strings.TrimSpace(key)) {
+				case "districts", "cities":
+					s.Districts[district.ID(value)] = true
+				case "areas":
+					if areaID, err := strconv.Atoi(value); err == nil {
+						s.AreaIDs[areaID] = true
+					} else {
+						s.Areas[strings.ToLower(value)] = true
+					}
+				case "categories":
+					s.Categories[strings.ToLower(value)] = true
+				case "lang", "language", "secondary":
+					lang := config.Language(strings.ToLower(value))
+					if !slices.Contains(config.Languages, lang) {
+						continue
+					}
+					if s.Language == "" && strings.TrimSpace(key) != "secondary" {
+						s.Language = lang
+					} else {
+						s.Secondary = append(s.Secondary, lang)
+					}
+				}
+			}
+		}
+		if s.IsEmpty() && s.Language == "" && len(s.Secondary) == 0 {
+			return nil
+		}
+		return s
+	}
+	return nil
+}
+
+func (s *Subscription) IsEmpty() bool {
+	return s == nil || len(s.Categories) == 0 && !s.hasLocations()
+}
+
+func (s *Subscription) hasLocations() bool {
+	return len(s.Districts) > 0 || len(s.Areas) > 0 || len(s.AreaIDs) > 0
+}
+
+// MatchesCategory tells whether alerts of category are wanted, uncategorized messages always are.
+func (s *Subscription) MatchesCategory(category string) bool {
+	return s == nil || len(s.Categories) == 0 || category == "" || s.Categories[category]
+}
+
+// MatchesDistrict checks the district against the subscribed IDs and area names in every language.
+func (s *Subscription) MatchesDistrict(id district.ID) bool {
+	if s == nil || !s.hasLocations() || s.Districts[id] {
+		return true
+	}
+	districts := district.GetDistricts()
+	for _, lang := range config.Languages {
+		d, ok := districts[lang][id]
+		if !ok {
+			continue
+		}
+		if s.AreaIDs[d.AreaID] || s.Areas[strings.ToLower(d.AreaName)] {
+			return true
+		}
+	}
+	return false
+}
+
+// FilterCities returns the subset of cities the subscription covers.
+func (s *Subscription) FilterCities(cities []district.ID) []district.ID {
+	if s == nil || !s.hasLocations() {
+		return cities
+	}
+	var result []district.ID
+	for _, city := range cities {
+		if s.MatchesDistrict(city) {
+			result = append(result, city)
+		}
+	}
+	return result
+}
+
+// SubscriptionFor returns the restriction configured for the channel, nil if it receives everything.
+func (b *Bot) SubscriptionFor(channel *model.Channel) *Subscription {
+	b.subscriptionsMutex.RLock()
+	defer b.subscriptionsMutex.RUnlock()
+	return b.subscriptions[channel.Id]
+}
+
+func (b *Bot) setSubscription(channelID string, subscription *Subscription) {
+	b.subscriptionsMutex.Lock()
+	defer b.subscriptionsMutex.Unlock()
+	if subscription == nil {
+		delete(b.subscriptions, channelID)
+	} else {
+		b.subscriptions[channelID] = subscription
+	}
+}
+
+// PostForSubscriber renders the post for a channel in its languages with the city list narrowed to its subscription,
+// the bool is false when none of the message is relevant to the channel.
+func (m *Message) PostForSubscriber(c *model.Channel, s *Subscription, languages []config.Language) (*model.Post, bool) {
+	if !s.MatchesCategory(m.Category) {
+		return nil, false
+	}
+	cities := s.FilterCities(m.Cities)
+	if len(cities) == len(m.Cities) {
+		return m.PostForLanguages(c, languages), true
+	}
+	// unrecognized cities could be anywhere, the channel gets them rather than risk missing an alert for its area
+	if len(cities) == 0 && len(m.Unrecognized) == 0 {
+		return nil, false
+	}
+	post := RenderLanguages(m.WithCities(cities), languages)
+	post.ChannelId = c.Id
+	return post, true
+}
@@ -0,0 +1,120 @@
+package bot
+
+import (
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/phntom/goalert/internal/config"
+	"github.com/phntom/goalert/internal/district"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestParseSubscription(t *testing.T) {
+	assert.Nil(t, ParseSubscription("Rocket alerts for the north"))
+	assert.Nil(t, ParseSubscription("goalert: nothing here"))
+
+	s := ParseSubscription("Alerts for the valleys\ngoalert: areas=HaAmakim, 27; districts=241; categories=rockets")
+	if assert.NotNil(t, s) {
+		assert.True(t, s.Areas["haamakim"])
+		assert.True(t, s.AreaIDs[27])
+		assert.True(t, s.Districts["241"])
+		assert.True(t, s.Categories["rockets"])
+	}
+}
+
+func TestSubscription_FilterCities(t *testing.T) {
+	cities := []district.ID{"999", "1231", "241", "1333"}
+	tests := []struct {
+		name   string
+		header string
+		want   []district.ID
+	}{
+		{"no subscription", "", cities},
+		{"area by english name", "goalert: areas=HaAmakim", []district.ID{"999", "1333"}},
+		{"area by hebrew name", "goalert: areas=שרון", []district.ID{"1231"}},
+		{"area id and district", "goalert: areas=27; districts=241", []district.ID{"1231", "241"}},
+		{"categories only", "goalert: categories=uav", cities},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, ParseSubscription(tt.header).FilterCities(cities))
+		})
+	}
+}
+
+func TestMessage_PostForSubscriber(t *testing.T) {
+	channel := &model.Channel{Id: "channel", DisplayName: "Test Channel"}
+	msg := NewMessage("instructions", "rockets", 60, "11:40")
+	msg.AppendDistrict("999")
+	msg.AppendDistrict("1231")
+	english := []config.Language{"en"}
+
+	post, ok := msg.PostForSubscriber(channel, ParseSubscription("goalert: areas=Sharon"), english)
+	assert.True(t, ok)
+	assert.Equal(t, "channel", post.ChannelId)
+	assert.Contains(t, post.Message, "Shoshanat Ha'Amakim")
+	assert.NotContains(t, post.Message, "Ein Harod")
+
+	_, ok = msg.PostForSubscriber(channel, ParseSubscription("goalert: areas=South Golan"), english)
+	assert.False(t, ok)
+
+	_, ok = msg.PostForSubscriber(channel, ParseSubscription("goalert: categories=uav"), english)
+	assert.False(t, ok)
+
+	post, ok = msg.PostForSubscriber(channel, nil, english)
+	assert.True(t, ok)
+	assert.Contains(t, post.Message, "Ein Harod")
+
+	msg.AppendUnrecognized("Nowhere")
+	post, ok = msg.PostForSubscriber(channel, ParseSubscription("goalert: areas=South Golan"), english)
+	assert.True(t, ok, "unrecognized cities reach every subscriber")
+	assert.Contains(t, post.Message, "Nowhere (Unrecognized location)")
+	assert.NotContains(t, post.Message, "Ein Harod")
+}
+
+func TestParseSubscription_Languages(t *testing.T) {
+	s := ParseSubscription("goalert: lang=RU; secondary=xx, en")
+	if assert.NotNil(t, s) {
+		assert.True(t, s.IsEmpty(), "languages alone do not restrict alerts")
+		assert.Equal(t, config.Language("ru"), s.Language)
+		assert.Equal(t, []config.Language{"en"}, s.Secondary)
+	}
+	s = ParseSubscription("goalert: lang=he,ar,en")
+	if assert.NotNil(t, s) {
+		assert.Equal(t, config.Language("he"), s.Language)
+		assert.Equal(t, []config.Language{"ar", "en"}, s.Secondary)
+	}
+	assert.Nil(t, ParseSubscription("goalert: lang=xx"))
+}
+
+func TestBot_ChannelLanguages(t *testing.T) {
+	b := &Bot{subscriptions: make(map[string]*Subscription)}
+	settings := config.Default()
+	settings.Mattermost.Channels = []config.ChannelConfig{
+		{Name: "phantom/russian", Language: "ru"},
+		{Name: "north", Language: "he", Secondary: "ar"},
+	}
+	b.SetConfig(settings)
+	teamProps := map[string]any{"teamName": "phantom"}
+
+	tests := []struct {
+		name    string
+		channel *model.Channel
+		header  string
+		want    []config.Language
+	}{
+		{"display name script", &model.Channel{Id: "1", DisplayName: "צפון"}, "", []config.Language{"he"}},
+		{"config by team and name", &model.Channel{Id: "2", Name: "russian", DisplayName: "Russian", Props: teamProps}, "", []config.Language{"ru"}},
+		{"config in another team", &model.Channel{Id: "3", Name: "russian", DisplayName: "Russian"}, "", []config.Language{"en"}},
+		{"config bilingual", &model.Channel{Id: "4", Name: "north", DisplayName: "North"}, "", []config.Language{"he", "ar"}},
+		{"header over config", &model.Channel{Id: "5", Name: "north", DisplayName: "North"}, "goalert: lang=en", []config.Language{"en", "ar"}},
+		{"props", &model.Channel{Id: "6", DisplayName: "Alerts", Props: map[string]any{"goalert_language": "ar", "goalert_secondary_language": "he"}}, "", []config.Language{"ar", "he"}},
+		{"secondary same as language", &model.Channel{Id: "7", DisplayName: "Alerts"}, "goalert: lang=he; secondary=he", []config.Language{"he"}},
+		{"ordered list", &model.Channel{Id: "8", Name: "north", DisplayName: "North"}, "goalert: lang=ar,ru,he", []config.Language{"ar", "ru", "he"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b.setSubscription(tt.channel.Id, ParseSubscription(tt.header))
+			assert.Equal(t, tt.want, b.ChannelLanguages(tt.channel))
+		})
+	}
+}
@@ -0,0 +1,210 @@
+package bot
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/shared/mlog"
+	"github.com/phntom/goalert/internal/config"
+	"github.com/phntom/goalert/internal/district"
+	"maps"
+	"net/http"
+	"slices"
+)
+
+// usersPreferenceCategory holds a preference of the bot user for every subscriber, named by the user id and holding
+// the subscription in JSON. Every replica reads the same preferences, so a standby taking over delivers the
+// subscriptions made on the leader before it.
+const usersPreferenceCategory = "goalert_subscriptions"
+
+// UserSubscription is a user asking for direct messages about alerts in specific cities.
+type UserSubscription struct {
+	UserID    string
+	ChannelID string
+	Cities    []district.ID
+	Language  config.Language
+}
+
+func (u *UserSubscription) subscription() *Subscription {
+	if len(u.Cities) == 0 {
+		return nil
+	}
+	s := &Subscription{
+		Districts: make(map[district.ID]bool, len(u.Cities)),
+		Language:  u.Language,
+	}
+	for _, city := range u.Cities {
+		s.Districts[city] = true
+	}
+	return s
+}
+
+// userSubscription returns a copy of the user's settings, creating defaults for unknown users.
+func (b *Bot) userSubscription(userID string) UserSubscription {
+	b.usersMutex.Lock()
+	defer b.usersMutex.Unlock()
+	u, ok := b.users[userID]
+	if !ok {
+		return UserSubscription{UserID: userID, Language: "he"}
+	}
+	result := *u
+	result.Cities = slices.Clone(u.Cities)
+	return result
+}
+
+// updateUserSubscription applies change to the user's settings while holding usersMutex, so concurrent commands of
+// the same user cannot overwrite each other. When change reports it changed them they are stored and the direct
+// message targets are updated accordingly.
+func (b *Bot) updateUserSubscription(userID string, change func(u *UserSubscription) bool) {
+	b.usersMutex.Lock()
+	defer b.usersMutex.Unlock()
+	u := UserSubscription{UserID: userID, Language: "he"}
+	if current, ok := b.users[userID]; ok {
+		u = *current
+		u.Cities = slices.Clone(current.Cities)
+	}
+	if !change(&u) {
+		return
+	}
+	if len(u.Cities) == 0 && u.Language == "he" {
+		delete(b.users, userID)
+	} else {
+		b.users[userID] = &u
+	}
+	b.saveUsers()
+	if u.ChannelID != "" {
+		b.setSubscription(u.ChannelID, u.subscription())
+	}
+}
+
+// publishUserSubscription stores the user's current settings in the bot's preferences, or deletes them when the
+// user has none left. Publishes run one at a time and read the settings when they run, so the last one wins.
+func (b *Bot) publishUserSubscription(userID string) {
+	b.usersPublishMutex.Lock()
+	defer b.usersPublishMutex.Unlock()
+	preference := model.Preference{UserId: b.userId, Category: usersPreferenceCategory, Name: userID}
+	b.usersMutex.Lock()
+	u, ok := b.users[userID]
+	if ok {
+		value, err := json.Marshal(u)
+		if err != nil {
+			b.usersMutex.Unlock()
+			mlog.Error("failed encoding user subscription", mlog.Err(err), mlog.Any("userID", userID))
+			return
+		}
+		preference.Value = string(value)
+	}
+	b.usersMutex.Unlock()
+	ctx, cancel := context.WithTimeout(context.Background(), postTimeout)
+	defer cancel()
+	var response *model.Response
+	var err error
+	if ok {
+		response, err = b.Client.UpdatePreferences(ctx, b.userId, model.Preferences{preference})
+	} else {
+		response, err = b.Client.DeletePreferences(ctx, b.userId, model.Preferences{preference})
+	}
+	if err != nil {
+		mlog.Error("failed storing user subscription",
+			mlog.Err(err),
+			mlog.Any("userID", userID),
+			mlog.Any("response", response),
+		)
+	}
+}
+
+// storedUserSubscriptions reads the subscriptions stored in the bot's preferences, ok is false when they could not
+// be read.
+func (b *Bot) storedUserSubscriptions() (users map[string]*UserSubscription, ok bool) {
+	ctx, cancel := context.WithTimeout(context.Background(), postTimeout)
+	defer cancel()
+	preferences, response, err := b.Client.GetPreferencesByCategory(ctx, b.userId, usersPreferenceCategory)
+	if err != nil && (response == nil || response.StatusCode != http.StatusNotFound) {
+		mlog.Error("failed loading user subscriptions", mlog.Err(err), mlog.Any("response", response))
+		return nil, false
+	}
+	users = make(map[string]*UserSubscription, len(preferences))
+	for _, preference := range preferences {
+		var u UserSubscription
+		if err := json.Unmarshal([]byte(preference.Value), &u); err != nil {
+			mlog.Error("failed decoding user subscription", mlog.Err(err), mlog.Any("userID", preference.Name))
+			continue
+		}
+		u.UserID = preference.Name
+		users[u.UserID] = &u
+	}
+	return users, true
+}
+
+// replaceUsers makes users the subscribed users, moving the direct message targets along.
+func (b *Bot) replaceUsers(users map[string]*UserSubscription) {
+	b.usersMutex.Lock()
+	defer b.usersMutex.Unlock()
+	for _, u := range b.users {
+		if u.ChannelID != "" {
+			b.setSubscription(u.ChannelID, nil)
+		}
+	}
+	b.users = users
+	for _, u := range users {
+		if u.ChannelID != "" {
+			b.setSubscription(u.ChannelID, u.subscription())
+		}
+	}
+	b.saveUsers()
+}
+
+// SyncUserSubscriptions runs on startup after RestoreState, the subscriptions stored in Mattermost replace the
+// restored ones. When Mattermost has none yet, as after upgrading from a version that kept them in the history
+// database only, the restored ones are stored in Mattermost instead.
+func (b *Bot) SyncUserSubscriptions() {
+	stored, ok := b.storedUserSubscriptions()
+	if !ok {
+		return
+	}
+	if len(stored) > 0 {
+		b.replaceUsers(stored)
+		return
+	}
+	b.usersMutex.Lock()
+	restored := slices.Sorted(maps.Keys(b.users))
+	b.usersMutex.Unlock()
+	for _, userID := range restored {
+		b.publishUserSubscription(userID)
+	}
+}
+
+// reloadUserSubscriptions picks up the subscriptions stored while another replica was the leader.
+func (b *Bot) reloadUserSubscriptions() {
+	if stored, ok := b.storedUserSubscriptions(); ok {
+		b.replaceUsers(stored)
+		mlog.Info("Loaded user subscriptions", mlog.Any("users", len(stored)))
+	}
+}
+
+// DirectChannels lists the direct message channels of users subscribed to at least one city.
+func (b *Bot) DirectChannels() []*model.Channel {
+	b.usersMutex.Lock()
+	defer b.usersMutex.Unlock()
+	var channels []*model.Channel
+	for _, u := range b.users {
+		if u.ChannelID == "" || len(u.Cities) == 0 {
+			continue
+		}
+		channels = append(channels, &model.Channel{
+			Id:   u.ChannelID,
+			Type: model.ChannelTypeDirect,
+		})
+	}
+	return channels
+}
+
+func (b *Bot) directChannelID(userID string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), postTimeout)
+	defer cancel()
+	channel, _, err := b.Client.CreateDirectChannel(ctx, b.userId, userID)
+	if err != nil {
+		return "", err
+	}
+	return channel.Id, nil
+}
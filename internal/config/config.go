@@ -8,7 +8,8 @@ import (
 	"github.com/nicksnyder/go-i18n/v2/i18n"
 	"golang.org/x/text/language"
 	"gopkg.in/yaml.v3"
-	"os"
+	"slices"
+	"strings"
 	"sync"
 )
 
@@ -17,6 +18,8 @@ import (
 var (
 	LanguageBundle *i18n.Bundle
 	once           sync.Once
+	// translated holds the message IDs each language's locale file has
+	translated map[Language][]string
 )
 
 //go:embed locale.*.yaml
@@ -24,28 +27,38 @@ var LocaleFS embed.FS
 
 type Language string
 
-var Languages = []Language{
-	"en", "he", "ru", "ar",
-}
-
 var msgNotFoundError *i18n.MessageNotFoundErr
 
 func Init() {
 	LanguageBundle = i18n.NewBundle(language.English)
 	LanguageBundle.RegisterUnmarshalFunc("yaml", yaml.Unmarshal)
+	translated = make(map[Language][]string, len(Languages))
 	for _, lang := range Languages {
-		_, err := LanguageBundle.LoadMessageFileFS(LocaleFS, fmt.Sprintf("locale.%s.yaml", lang))
+		file, err := LanguageBundle.LoadMessageFileFS(LocaleFS, fmt.Sprintf("locale.%s.yaml", lang))
 		if err != nil {
+			// reported by MissingTranslations, every text falls back
 			mlog.Error("failed loading translation", mlog.Err(err), mlog.Any("lang", lang))
-			os.Exit(2)
+			continue
+		}
+		for _, message := range file.Messages {
+			translated[lang] = append(translated[lang], message.ID)
 		}
-
 	}
 }
 
+// GetText returns the text with id in lang, or in the first of its fallbacks that has it, or in English.
 func GetText(id string, lang Language) string {
 	once.Do(Init)
-	result, err := i18n.NewLocalizer(LanguageBundle, string(lang)).Localize(&i18n.LocalizeConfig{MessageID: id})
+	for _, l := range append([]Language{lang}, lang.Fallbacks()...) {
+		result, err := localize(id, l)
+		if err == nil {
+			return result
+		}
+		if !errors.As(err, &msgNotFoundError) {
+			mlog.Error("GetText localizer error", mlog.Any("id", id), mlog.Any("lang", l), mlog.Err(err))
+		}
+	}
+	result, err := i18n.NewLocalizer(LanguageBundle, "en").Localize(&i18n.LocalizeConfig{MessageID: id})
 	if err != nil {
 		mlog.Error("GetText localizer error",
 			mlog.Any("id", id),
@@ -56,10 +69,34 @@ func GetText(id string, lang Language) string {
 	return result
 }
 
+// localize looks id up in lang alone, the bundle would otherwise answer in English for languages it has no file of.
+func localize(id string, lang Language) (string, error) {
+	result, tag, err := i18n.NewLocalizer(LanguageBundle, string(lang)).LocalizeWithTag(&i18n.LocalizeConfig{MessageID: id})
+	if err == nil && tag != language.Make(string(lang)) {
+		return "", &i18n.MessageNotFoundErr{Tag: language.Make(string(lang)), MessageID: id}
+	}
+	return result, err
+}
+
+// MissingTranslations lists per language the English message IDs its locale file lacks, leaving out the
+// subdivision.replace overrides which only exist where a language needs them.
+func MissingTranslations() map[Language][]string {
+	once.Do(Init)
+	missing := make(map[Language][]string)
+	for _, lang := range Languages {
+		for _, id := range translated["en"] {
+			if strings.HasPrefix(id, "subdivision.replace.") || slices.Contains(translated[lang], id) {
+				continue
+			}
+			missing[lang] = append(missing[lang], id)
+		}
+	}
+	return missing
+}
+
 func GetTextOptional(id string, lang Language, def string) string {
 	once.Do(Init)
-	localizer := i18n.NewLocalizer(LanguageBundle, string(lang))
-	result, err := localizer.Localize(&i18n.LocalizeConfig{MessageID: id})
+	result, err := localize(id, lang)
 	if err != nil {
 		if errors.As(err, &msgNotFoundError) {
 			return def
@@ -0,0 +1,79 @@
+package config
+
+import (
+	_ "embed"
+	"gopkg.in/yaml.v3"
+	"slices"
+)
+
+// LanguageInfo describes a language from languages.yaml.
+type LanguageInfo struct {
+	Code     Language   `yaml:"code"`
+	Name     string     `yaml:"name"`
+	Script   string     `yaml:"script"`
+	RTL      bool       `yaml:"rtl"`
+	Fallback []Language `yaml:"fallback"`
+	Upstream string     `yaml:"upstream"`
+}
+
+//go:embed languages.yaml
+var languagesYAML []byte
+
+var languageInfos = loadLanguages()
+
+// Languages lists the codes of every supported language, English first.
+var Languages = languageCodes()
+
+func loadLanguages() []LanguageInfo {
+	var infos []LanguageInfo
+	if err := yaml.Unmarshal(languagesYAML, &infos); err != nil {
+		// embedded, so a broken file never makes it past the tests
+		panic("parsing languages.yaml: " + err.Error())
+	}
+	return infos
+}
+
+func languageCodes() []Language {
+	codes := make([]Language, 0, len(languageInfos))
+	for _, info := range languageInfos {
+		codes = append(codes, info.Code)
+	}
+	return codes
+}
+
+// LanguageInfos returns the description of every supported language.
+func LanguageInfos() []LanguageInfo {
+	return slices.Clone(languageInfos)
+}
+
+// Info returns the description of l, the zero value for unsupported languages.
+func (l Language) Info() LanguageInfo {
+	for _, info := range languageInfos {
+		if info.Code == l {
+			return info
+		}
+	}
+	return LanguageInfo{}
+}
+
+// RTL tells whether the language is written right to left.
+func (l Language) RTL() bool {
+	return l.Info().RTL
+}
+
+// Fallbacks returns the languages standing in for l where it lacks a translation, nearest first. English is the
+// last resort of every language and is only listed when declared.
+func (l Language) Fallbacks() []Language {
+	var chain []Language
+	queue := l.Info().Fallback
+	for len(queue) > 0 {
+		next := queue[0]
+		queue = queue[1:]
+		if next == l || slices.Contains(chain, next) {
+			continue
+		}
+		chain = append(chain, next)
+		queue = append(queue, next.Info().Fallback...)
+	}
+	return chain
+}
@@ -0,0 +1,40 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLanguage_Info(t *testing.T) {
+	assert.Equal(t, Language("en"), Languages[0])
+	assert.Contains(t, Languages, Language("fr"))
+	assert.Contains(t, Languages, Language("am"))
+	assert.True(t, Language("he").RTL())
+	assert.False(t, Language("fr").RTL())
+	assert.Equal(t, "Ethiopic", Language("am").Info().Script)
+	assert.Equal(t, LanguageInfo{}, Language("xx").Info())
+}
+
+func TestLanguage_Fallbacks(t *testing.T) {
+	assert.Equal(t, []Language{"en"}, Language("fr").Fallbacks())
+	assert.Empty(t, Language("en").Fallbacks())
+
+	languageInfos = append(languageInfos,
+		LanguageInfo{Code: "xa", Fallback: []Language{"xb", "fr"}},
+		LanguageInfo{Code: "xb", Fallback: []Language{"xa", "he"}},
+	)
+	t.Cleanup(func() { languageInfos = loadLanguages() })
+	assert.Equal(t, []Language{"xb", "fr", "he", "en"}, Language("xa").Fallbacks())
+}
+
+func TestGetText_Languages(t *testing.T) {
+	assert.Equal(t, "Tirs de roquettes et de missiles", GetText("message.rockets", "fr"))
+	assert.Equal(t, "የሮኬት እና የሚሳኤል ተኩስ", GetText("message.rockets", "am"))
+	assert.Equal(t, "Rocket and missile fire", GetText("message.rockets", "xx"), "unknown languages get English")
+	assert.Equal(t, "default", GetTextOptional("subdivision.replace.1017", "fr", "default"), "overrides do not fall back")
+}
+
+func TestMissingTranslations(t *testing.T) {
+	assert.Empty(t, MissingTranslations(), "every locale file should have every English message")
+}
@@ -0,0 +1,504 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/mattermost/mattermost/server/public/shared/mlog"
+	"gopkg.in/yaml.v3"
+	"io"
+	"net/url"
+	"os"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Config is the bot's configuration, see config.yaml for a documented example.
+// Values are read from the YAML file first and then overridden by the environment variables noted
+// next to each field, so existing deployments configured by environment alone keep working.
+type Config struct {
+	Mattermost     MattermostConfig     `yaml:"mattermost"`
+	Sources        SourcesConfig        `yaml:"sources"`
+	Sinks          SinksConfig          `yaml:"sinks"`
+	LeaderElection LeaderElectionConfig `yaml:"leader_election"`
+	Thresholds     ThresholdsConfig     `yaml:"thresholds"`
+	Districts      DistrictsConfig      `yaml:"districts"`
+}
+
+type MattermostConfig struct {
+	Domain        string `yaml:"domain"`        // CHAT_DOMAIN
+	AuthToken     string `yaml:"auth_token"`    // AUTH_TOKEN
+	CommandToken  string `yaml:"command_token"` // COMMAND_TOKEN
+	ConfigTeam    string `yaml:"config_team"`
+	ConfigChannel string `yaml:"config_channel"`
+	OpsChannel    string `yaml:"ops_channel"`
+	// Channels declares the languages of alert channels whose display name does not give them away
+	Channels []ChannelConfig `yaml:"channels"`
+}
+
+// ChannelConfig sets the language of the alert channel named Name, or team/name when channel names repeat across
+// teams. Secondary, when set, is shown below Language in every post.
+type ChannelConfig struct {
+	Name      string   `yaml:"name"`
+	Language  Language `yaml:"language"`
+	Secondary Language `yaml:"secondary"`
+}
+
+type SourcesConfig struct {
+	Oref     PollerConfig   `yaml:"oref"`
+	Ynet     PollerConfig   `yaml:"ynet"`
+	Telegram TelegramConfig `yaml:"telegram"`
+}
+
+// PollerConfig is a source fetched over HTTP every PollInterval, Offset after the interval's boundary.
+type PollerConfig struct {
+	Enabled      bool          `yaml:"enabled"` // DISABLE_OREF, DISABLE_YNET
+	URL          string        `yaml:"url"`
+	Referrer     string        `yaml:"referrer"`
+	PollInterval time.Duration `yaml:"poll_interval"`
+	PollOffset   time.Duration `yaml:"poll_offset"`
+}
+
+type TelegramConfig struct {
+	Enabled      bool           `yaml:"enabled"`  // DISABLE_TELEGRAM
+	AppID        int            `yaml:"app_id"`   // APP_ID
+	AppHash      string         `yaml:"app_hash"` // APP_HASH
+	AlertChannel int64          `yaml:"alert_channel"`
+	Rules        []TelegramRule `yaml:"rules"`
+}
+
+// TelegramRule forwards messages of a Telegram channel containing any of Keywords, prefixed by Prefix,
+// either to the Mattermost channel named Target or to every alert channel in Language.
+type TelegramRule struct {
+	Name     string   `yaml:"name"`
+	Channel  int64    `yaml:"channel"`
+	Keywords []string `yaml:"keywords"`
+	Prefix   string   `yaml:"prefix"`
+	Target   string   `yaml:"target"`
+	Language Language `yaml:"language"`
+}
+
+type SinksConfig struct {
+	Webhook WebhookConfig `yaml:"webhook"`
+	CAP     CAPConfig     `yaml:"cap"`
+	History HistoryConfig `yaml:"history"`
+	Record  RecordConfig  `yaml:"record"`
+}
+
+type WebhookConfig struct {
+	URLs   []string `yaml:"urls"`   // WEBHOOK_URLS, comma separated
+	Secret string   `yaml:"secret"` // WEBHOOK_SECRET
+}
+
+type CAPConfig struct {
+	Sender string `yaml:"sender"` // CAP_SENDER
+}
+
+// HistoryConfig keeps the alerts received within Retention in the database at Path, 0 keeps them forever.
+type HistoryConfig struct {
+	Path      string        `yaml:"path"` // HISTORY_DB
+	Retention time.Duration `yaml:"retention"`
+}
+
+type RecordConfig struct {
+	Path    string `yaml:"path"`    // RECORD_PATH
+	MaxMB   int    `yaml:"max_mb"`  // RECORD_MAX_MB
+	Backups int    `yaml:"backups"` // RECORD_BACKUPS
+}
+
+type LeaderElectionConfig struct {
+	Mode      string `yaml:"mode"`       // LEADER_ELECTION, kubernetes or file
+	LeaseName string `yaml:"lease_name"` // LEASE_NAME
+	Namespace string `yaml:"namespace"`  // POD_NAMESPACE
+	LockPath  string `yaml:"lock_path"`  // LEADER_LOCK_PATH
+}
+
+type ThresholdsConfig struct {
+	// SplitCities is the largest number of cities in a single post
+	SplitCities int `yaml:"split_cities"`
+	// TelegramMaxAge drops channel messages published longer ago, EarlyWarningMaxAge early warnings
+	TelegramMaxAge     time.Duration `yaml:"telegram_max_age"`
+	EarlyWarningMaxAge time.Duration `yaml:"early_warning_max_age"`
+}
+
+// DistrictsConfig loads districts.<code>.json files from Source, a directory or a URL, over the embedded ones. Entries
+// replace the embedded districts with the same id and add new ones, a districts.geojson file adds their shapes. The
+// files are read again every ReloadInterval.
+type DistrictsConfig struct {
+	Source         string        `yaml:"source"` // DISTRICTS_SOURCE
+	ReloadInterval time.Duration `yaml:"reload_interval"`
+}
+
+// Default returns the configuration used for everything the file and environment leave out.
+func Default() *Config {
+	return &Config{
+		Mattermost: MattermostConfig{
+			ConfigTeam:    "phantom",
+			ConfigChannel: "config",
+			OpsChannel:    "goalert-ops",
+		},
+		Sources: SourcesConfig{
+			Oref: PollerConfig{
+				Enabled:      true,
+				URL:          "https://www.oref.org.il/WarningMessages/alert/alerts.json",
+				Referrer:     "https://www.oref.org.il//12481-he/Pakar.aspx",
+				PollInterval: time.Second,
+				PollOffset:   200 * time.Millisecond,
+			},
+			Ynet: PollerConfig{
+				Enabled:      true,
+				URL:          "https://alerts.ynet.co.il/alertsRss/YnetPicodeHaorefAlertFiles.js?callback=jsonCallback",
+				Referrer:     "https://www.ynet.co.il/",
+				PollInterval: 250 * time.Millisecond,
+			},
+			Telegram: TelegramConfig{
+				Enabled:      true,
+				AlertChannel: 1441886157, // pikudhaoref_all
+				Rules: []TelegramRule{
+					{
+						Name:     "idf",
+						Channel:  1155294424,
+						Keywords: []string{"התרע", "פיגוע", "יירט", "מדיניות", "הנחיות"},
+						Language: "he",
+					},
+					{
+						Name:     "news",
+						Channel:  2335255539,
+						Keywords: []string{"ירוט", "ירט", "אזעק", "תימן", "תימני", "יורט", "שיגור", "פיצוץ"},
+						Prefix:   "חדשות ישראל בטלגרם: ",
+						Target:   "telegram-2335255539",
+					},
+				},
+			},
+		},
+		Sinks: SinksConfig{
+			CAP:     CAPConfig{Sender: "goalert"},
+			History: HistoryConfig{Retention: 365 * 24 * time.Hour},
+			Record: RecordConfig{
+				MaxMB:   100,
+				Backups: 5,
+			},
+		},
+		Thresholds: ThresholdsConfig{
+			SplitCities:        20,
+			TelegramMaxAge:     90 * time.Second,
+			EarlyWarningMaxAge: 5 * time.Minute,
+		},
+		Districts: DistrictsConfig{
+			ReloadInterval: 10 * time.Minute,
+		},
+	}
+}
+
+// Load reads the configuration file at path, when not empty, over the defaults, applies the
+// environment overrides and validates the result.
+func Load(path string) (*Config, error) {
+	c := Default()
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		if err := c.decode(data); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	if err := c.applyEnv(os.Getenv); err != nil {
+		return nil, err
+	}
+	c.disableTelegramWithoutCredentials()
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// disableTelegramWithoutCredentials turns telegram off with a warning when it is enabled, as it is by default, but its
+// credentials are missing, deployments without APP_ID and APP_HASH run on the other sources.
+func (c *Config) disableTelegramWithoutCredentials() {
+	telegram := &c.Sources.Telegram
+	if !telegram.Enabled || telegram.AppID != 0 && telegram.AppHash != "" {
+		return
+	}
+	mlog.Warn("Telegram disabled, sources.telegram.app_id and app_hash or APP_ID and APP_HASH are not set")
+	telegram.Enabled = false
+}
+
+// decode fills c from YAML, keys that do not exist are an error so typos do not go unnoticed.
+func (c *Config) decode(data []byte) error {
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	err := decoder.Decode(c)
+	if errors.Is(err, io.EOF) {
+		return nil
+	}
+	return err
+}
+
+func (c *Config) applyEnv(getenv func(string) string) error {
+	var errs []error
+	str := func(name string, value *string) {
+		if v := getenv(name); v != "" {
+			*value = v
+		}
+	}
+	integer := func(name string, value *int) {
+		if v := getenv(name); v != "" {
+			parsed, err := strconv.Atoi(v)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: %q is not a number", name, v))
+				return
+			}
+			*value = parsed
+		}
+	}
+	disable := func(name string, enabled *bool) {
+		if getenv(name) == "1" {
+			*enabled = false
+		}
+	}
+	str("CHAT_DOMAIN", &c.Mattermost.Domain)
+	str("AUTH_TOKEN", &c.Mattermost.AuthToken)
+	str("COMMAND_TOKEN", &c.Mattermost.CommandToken)
+	disable("DISABLE_OREF", &c.Sources.Oref.Enabled)
+	disable("DISABLE_YNET", &c.Sources.Ynet.Enabled)
+	disable("DISABLE_TELEGRAM", &c.Sources.Telegram.Enabled)
+	integer("APP_ID", &c.Sources.Telegram.AppID)
+	str("APP_HASH", &c.Sources.Telegram.AppHash)
+	if urls := getenv("WEBHOOK_URLS"); urls != "" {
+		c.Sinks.Webhook.URLs = strings.Split(urls, ",")
+	}
+	str("WEBHOOK_SECRET", &c.Sinks.Webhook.Secret)
+	str("CAP_SENDER", &c.Sinks.CAP.Sender)
+	str("HISTORY_DB", &c.Sinks.History.Path)
+	str("RECORD_PATH", &c.Sinks.Record.Path)
+	integer("RECORD_MAX_MB", &c.Sinks.Record.MaxMB)
+	integer("RECORD_BACKUPS", &c.Sinks.Record.Backups)
+	str("LEADER_ELECTION", &c.LeaderElection.Mode)
+	str("LEASE_NAME", &c.LeaderElection.LeaseName)
+	str("POD_NAMESPACE", &c.LeaderElection.Namespace)
+	str("LEADER_LOCK_PATH", &c.LeaderElection.LockPath)
+	str("DISTRICTS_SOURCE", &c.Districts.Source)
+	return errors.Join(errs...)
+}
+
+// Validate reports every invalid setting, each error names the setting's path in the YAML file.
+func (c *Config) Validate() error {
+	var errs []error
+	fail := func(path string, format string, args ...any) {
+		errs = append(errs, fmt.Errorf("%s: %s", path, fmt.Sprintf(format, args...)))
+	}
+
+	if c.Mattermost.Domain == "" {
+		fail("mattermost.domain", "required, set it or CHAT_DOMAIN")
+	} else if err := checkURL(c.Mattermost.Domain); err != nil {
+		fail("mattermost.domain", "%v", err)
+	}
+	if c.Mattermost.AuthToken == "" {
+		fail("mattermost.auth_token", "required, set it or AUTH_TOKEN")
+	}
+	if c.Mattermost.ConfigChannel == "" {
+		fail("mattermost.config_channel", "required")
+	}
+	for i, channel := range c.Mattermost.Channels {
+		path := fmt.Sprintf("mattermost.channels[%d]", i)
+		if channel.Name == "" {
+			fail(path+".name", "required")
+		}
+		if !slices.Contains(Languages, channel.Language) {
+			fail(path+".language", "unknown language %q", channel.Language)
+		}
+		if channel.Secondary != "" && !slices.Contains(Languages, channel.Secondary) {
+			fail(path+".secondary", "unknown language %q", channel.Secondary)
+		} else if channel.Secondary == channel.Language {
+			fail(path+".secondary", "same as language")
+		}
+	}
+
+	pollers := []struct {
+		path   string
+		poller PollerConfig
+	}{
+		{"sources.oref", c.Sources.Oref},
+		{"sources.ynet", c.Sources.Ynet},
+	}
+	for _, p := range pollers {
+		path, poller := p.path, p.poller
+		if !poller.Enabled {
+			continue
+		}
+		if err := checkURL(poller.URL); err != nil {
+			fail(path+".url", "%v", err)
+		}
+		if poller.PollInterval <= 0 {
+			fail(path+".poll_interval", "must be positive")
+		}
+		if poller.PollOffset < 0 || poller.PollInterval > 0 && poller.PollOffset >= poller.PollInterval {
+			fail(path+".poll_offset", "must be between 0 and poll_interval")
+		}
+	}
+	if telegram := c.Sources.Telegram; telegram.Enabled {
+		if telegram.AppID == 0 {
+			fail("sources.telegram.app_id", "required when telegram is enabled, set it or APP_ID")
+		}
+		if telegram.AppHash == "" {
+			fail("sources.telegram.app_hash", "required when telegram is enabled, set it or APP_HASH")
+		}
+		if telegram.AlertChannel == 0 {
+			fail("sources.telegram.alert_channel", "required when telegram is enabled")
+		}
+		for i, rule := range telegram.Rules {
+			path := fmt.Sprintf("sources.telegram.rules[%d]", i)
+			if rule.Channel == 0 {
+				fail(path+".channel", "required")
+			}
+			if rule.Channel == telegram.AlertChannel {
+				fail(path+".channel", "is the alert channel")
+			}
+			if len(rule.Keywords) == 0 {
+				fail(path+".keywords", "at least one keyword is required")
+			}
+			if (rule.Target == "") == (rule.Language == "") {
+				fail(path, "set exactly one of target and language")
+			}
+			if rule.Language != "" && !slices.Contains(Languages, rule.Language) {
+				fail(path+".language", "unknown language %q", rule.Language)
+			}
+		}
+	}
+	if !c.Sources.Oref.Enabled && !c.Sources.Ynet.Enabled && !c.Sources.Telegram.Enabled {
+		fail("sources", "at least one source must be enabled")
+	}
+
+	for i, webhook := range c.Sinks.Webhook.URLs {
+		if err := checkURL(webhook); err != nil {
+			fail(fmt.Sprintf("sinks.webhook.urls[%d]", i), "%v", err)
+		}
+	}
+	if c.Sinks.CAP.Sender == "" {
+		fail("sinks.cap.sender", "required")
+	}
+	if c.Sinks.History.Retention < 0 {
+		fail("sinks.history.retention", "must not be negative")
+	}
+	if c.Sinks.Record.Path != "" {
+		if c.Sinks.Record.MaxMB <= 0 {
+			fail("sinks.record.max_mb", "must be positive")
+		}
+		if c.Sinks.Record.Backups < 0 {
+			fail("sinks.record.backups", "must not be negative")
+		}
+	}
+
+	switch c.LeaderElection.Mode {
+	case "":
+	case "kubernetes":
+		if c.LeaderElection.LeaseName == "" {
+			fail("leader_election.lease_name", "required for kubernetes leader election")
+		}
+	case "file":
+		if c.LeaderElection.LockPath == "" {
+			fail("leader_election.lock_path", "required for file leader election")
+		}
+	default:
+		fail("leader_election.mode", "unknown mode %q, use kubernetes or file", c.LeaderElection.Mode)
+	}
+
+	if c.Thresholds.SplitCities <= 0 {
+		fail("thresholds.split_cities", "must be positive")
+	}
+	if c.Thresholds.TelegramMaxAge <= 0 {
+		fail("thresholds.telegram_max_age", "must be positive")
+	}
+	if c.Thresholds.EarlyWarningMaxAge <= 0 {
+		fail("thresholds.early_warning_max_age", "must be positive")
+	}
+
+	if source := c.Districts.Source; strings.Contains(source, "://") {
+		if err := checkURL(source); err != nil {
+			fail("districts.source", "%v", err)
+		}
+	} else if source != "" {
+		if info, err := os.Stat(source); err != nil {
+			fail("districts.source", "%v", err)
+		} else if !info.IsDir() {
+			fail("districts.source", "%q is not a directory", source)
+		}
+	}
+	if c.Districts.ReloadInterval <= 0 {
+		fail("districts.reload_interval", "must be positive")
+	}
+	return errors.Join(errs...)
+}
+
+func checkURL(value string) error {
+	parsed, err := url.Parse(value)
+	if err != nil {
+		return err
+	}
+	if parsed.Scheme != "http" && parsed.Scheme != "https" || parsed.Host == "" {
+		return fmt.Errorf("%q is not an http(s) URL", value)
+	}
+	return nil
+}
+
+// Redacted is a copy of c safe to print, secrets are masked.
+func (c *Config) Redacted() *Config {
+	redacted := *c
+	mask := func(secret *string) {
+		if *secret != "" {
+			*secret = "********"
+		}
+	}
+	mask(&redacted.Mattermost.AuthToken)
+	mask(&redacted.Mattermost.CommandToken)
+	mask(&redacted.Sources.Telegram.AppHash)
+	mask(&redacted.Sinks.Webhook.Secret)
+	return &redacted
+}
+
+// Reload loads path like Load for a running bot. Settings that only take effect after a restart keep
+// their values from running, those that changed are returned as pending.
+func Reload(running *Config, path string) (*Config, []string, error) {
+	next, err := Load(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	var pending []string
+	current := restartOnly(running)
+	for i, setting := range restartOnly(next) {
+		was := reflect.ValueOf(current[i].value).Elem()
+		is := reflect.ValueOf(setting.value).Elem()
+		if !reflect.DeepEqual(was.Interface(), is.Interface()) {
+			pending = append(pending, setting.path)
+			is.Set(was)
+		}
+	}
+	return next, pending, nil
+}
+
+type setting struct {
+	path string
+	// value points into a Config
+	value any
+}
+
+// restartOnly lists the settings read once at startup, everything else is read whenever it is used.
+func restartOnly(c *Config) []setting {
+	return []setting{
+		{"mattermost.domain", &c.Mattermost.Domain},
+		{"mattermost.auth_token", &c.Mattermost.AuthToken},
+		{"mattermost.config_team", &c.Mattermost.ConfigTeam},
+		{"mattermost.config_channel", &c.Mattermost.ConfigChannel},
+		{"sources.oref.enabled", &c.Sources.Oref.Enabled},
+		{"sources.ynet.enabled", &c.Sources.Ynet.Enabled},
+		{"sources.telegram.enabled", &c.Sources.Telegram.Enabled},
+		{"sources.telegram.app_id", &c.Sources.Telegram.AppID},
+		{"sources.telegram.app_hash", &c.Sources.Telegram.AppHash},
+		{"sinks", &c.Sinks},
+		{"leader_election", &c.LeaderElection},
+	}
+}
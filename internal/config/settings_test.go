@@ -0,0 +1,134 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfig_ExampleMatchesDefault(t *testing.T) {
+	data, err := os.ReadFile("config.yaml")
+	require.NoError(t, err)
+	c := &Config{}
+	require.NoError(t, c.decode(data))
+	c.Sinks.Webhook.URLs = nil
+	assert.Equal(t, Default(), c)
+}
+
+func TestLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`
+mattermost:
+  domain: https://chat.example.com
+  auth_token: from-file
+sources:
+  ynet:
+    poll_interval: 500ms
+  telegram:
+    enabled: false
+`), 0o600))
+	t.Setenv("AUTH_TOKEN", "from-env")
+	t.Setenv("DISABLE_OREF", "1")
+	t.Setenv("WEBHOOK_URLS", "https://a.example.com/hook,https://b.example.com/hook")
+
+	c, err := Load(path)
+	require.NoError(t, err)
+	assert.Equal(t, "https://chat.example.com", c.Mattermost.Domain)
+	assert.Equal(t, "from-env", c.Mattermost.AuthToken)
+	assert.Equal(t, 500*time.Millisecond, c.Sources.Ynet.PollInterval)
+	assert.Equal(t, "https://www.ynet.co.il/", c.Sources.Ynet.Referrer)
+	assert.False(t, c.Sources.Oref.Enabled)
+	assert.False(t, c.Sources.Telegram.Enabled)
+	assert.Equal(t, []string{"https://a.example.com/hook", "https://b.example.com/hook"}, c.Sinks.Webhook.URLs)
+	assert.Equal(t, "********", c.Redacted().Mattermost.AuthToken)
+	assert.Equal(t, "from-env", c.Mattermost.AuthToken)
+}
+
+func TestLoad_Errors(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("mattermost:\n  domian: https://chat.example.com\n"), 0o600))
+	_, err := Load(path)
+	assert.ErrorContains(t, err, "field domian not found")
+
+	t.Setenv("RECORD_MAX_MB", "lots")
+	_, err = Load("")
+	assert.EqualError(t, err, `RECORD_MAX_MB: "lots" is not a number`)
+}
+
+func TestLoad_TelegramWithoutCredentials(t *testing.T) {
+	t.Setenv("CHAT_DOMAIN", "https://chat.example.com")
+	t.Setenv("AUTH_TOKEN", "token")
+	c, err := Load("")
+	require.NoError(t, err)
+	assert.False(t, c.Sources.Telegram.Enabled)
+	assert.True(t, c.Sources.Oref.Enabled)
+
+	t.Setenv("APP_ID", "1")
+	t.Setenv("APP_HASH", "hash")
+	c, err = Load("")
+	require.NoError(t, err)
+	assert.True(t, c.Sources.Telegram.Enabled)
+}
+
+func TestConfig_Validate(t *testing.T) {
+	c := Default()
+	c.Mattermost.Domain = "chat.example.com"
+	c.Mattermost.AuthToken = "token"
+	c.Mattermost.Channels = []ChannelConfig{{Name: "north", Language: "he", Secondary: "he"}, {Language: "de"}}
+	c.Sources.Oref.PollInterval = 0
+	c.Sources.Ynet.PollOffset = time.Second
+	c.Sources.Telegram.AppID = 1
+	c.Sources.Telegram.AppHash = "hash"
+	c.Sources.Telegram.Rules = append(c.Sources.Telegram.Rules, TelegramRule{Channel: 1, Keywords: []string{"x"}, Language: "xx"})
+	c.Sinks.History.Retention = -time.Hour
+	c.LeaderElection.Mode = "etcd"
+	c.Thresholds.SplitCities = 0
+	c.Districts.Source = "ftp://example.com/districts"
+
+	assert.EqualError(t, c.Validate(), `mattermost.domain: "chat.example.com" is not an http(s) URL
+mattermost.channels[0].secondary: same as language
+mattermost.channels[1].name: required
+mattermost.channels[1].language: unknown language "de"
+sources.oref.poll_interval: must be positive
+sources.ynet.poll_offset: must be between 0 and poll_interval
+sources.telegram.rules[2].language: unknown language "xx"
+sinks.history.retention: must not be negative
+leader_election.mode: unknown mode "etcd", use kubernetes or file
+thresholds.split_cities: must be positive
+districts.source: "ftp://example.com/districts" is not an http(s) URL`)
+}
+
+func TestReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`
+mattermost:
+  domain: https://other.example.com
+  auth_token: token
+  ops_channel: alerts-ops
+sources:
+  telegram:
+    enabled: false
+    app_id: 1
+    app_hash: hash
+thresholds:
+  split_cities: 30
+`), 0o600))
+	running := Default()
+	running.Mattermost.Domain = "https://chat.example.com"
+	running.Mattermost.AuthToken = "token"
+	running.Sources.Telegram.AppID = 1
+	running.Sources.Telegram.AppHash = "hash"
+
+	c, pending, err := Reload(running, path)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"mattermost.domain", "sources.telegram.enabled"}, pending)
+	assert.Equal(t, "https://chat.example.com", c.Mattermost.Domain)
+	assert.True(t, c.Sources.Telegram.Enabled)
+	assert.Equal(t, "alerts-ops", c.Mattermost.OpsChannel)
+	assert.Equal(t, 30, c.Thresholds.SplitCities)
+	assert.Equal(t, "https://chat.example.com", running.Mattermost.Domain)
+}
@@ -4,12 +4,17 @@ import (
 	"embed"
 	_ "embed"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/mattermost/mattermost/server/public/shared/mlog"
 	"github.com/phntom/goalert/internal/config"
-	"io"
+	"io/fs"
+	"maps"
+	"slices"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 )
 
 type ID string
@@ -30,18 +35,34 @@ type Districts map[config.Language]map[ID]District
 
 // Embed the JSON source files.
 
-// download new versions from https://www.oref.org.il/Shared/Ajax/GetDistricts.aspx?lang=he
+// refresh them with `goalert-bot districts update`, see update.go, or load newer ones at runtime, see load.go
 //
-//go:embed districts.*.json
+//go:embed districts.*.json districts.geojson
 var districtFS embed.FS
 
-// districts holds the unmarshaled JSON data. We use sync.Once to ensure the map is only initialized once.
+// dataset is one complete generation of district data, Load replaces it as a whole so readers never see a mix.
+type dataset struct {
+	districts Districts
+	lookup    map[string]ID
+	coverage  map[config.Language]*Coverage
+	matcher   matcher
+	// geometry holds the embedded shapes with those of a loaded districts.geojson in place of the same districts'
+	geometry map[ID]*Shape
+	// digest identifies the external files the dataset was built with, empty for the embedded ones alone
+	digest string
+}
+
+// current holds the dataset in use, sync.Once sets up the embedded one on first use.
 var (
-	districts      Districts
-	districtLookup map[string]ID
-	once           sync.Once
+	current atomic.Pointer[dataset]
+	once    sync.Once
 )
 
+func data() *dataset {
+	once.Do(initDistricts)
+	return current.Load()
+}
+
 // normalizeCityName performs several normalization steps on a city name.
 func normalizeCityName(name string) string {
 	// Replace יי with י and remove hyphens, parentheses, single quotes, double quotes
@@ -65,47 +86,39 @@ func normalizeCityName(name string) string {
 	return normalized
 }
 
-// initDistricts is called once to initialize the districts map.
+// Coverage tells where a language's district names came from, beyond its own districts file.
+type Coverage struct {
+	// Fallback lists districts named by one of the language's fallbacks
+	Fallback []ID
+	// Transliterated lists districts no translation covers, named by transliterating the Hebrew name
+	Transliterated []ID
+}
+
+// initDistricts is called once to set up the embedded districts.
 func initDistricts() {
+	current.Store(buildDataset(nil, nil, ""))
+}
+
+// buildDataset indexes the embedded districts with external entries replacing those with the same id, and likewise
+// for shapes. Hebrew is the reference every other language is completed against, from its fallback languages and
+// finally by transliteration.
+func buildDataset(external map[config.Language][]District, geometry map[ID]*Shape, digest string) *dataset {
 	numberOfLanguages := len(config.Languages)
-	districts = make(Districts, numberOfLanguages)
+	loaded := make(Districts, numberOfLanguages)
+	lookup := make(map[string]ID, 1600*numberOfLanguages)
 	for _, lang := range config.Languages {
-		var districtList []District
-
-		filename := fmt.Sprintf("districts.%s.json", lang)
-		file, err := districtFS.Open(filename)
-		if err != nil {
-			mlog.Error("Failed loading district", mlog.Err(err),
-				mlog.Any("lang", lang),
-				mlog.Any("filename", filename),
-			)
-			return
-		}
-		content, err := io.ReadAll(file)
-		if err != nil {
-			mlog.Error("Failed reading district file", mlog.Err(err),
-				mlog.Any("lang", lang),
-				mlog.Any("filename", filename),
-			)
-			return
+		districtList, err := loadDistrictFile(lang)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			mlog.Error("Failed loading district file", mlog.Err(err), mlog.Any("lang", lang))
 		}
-		err = json.Unmarshal(content, &districtList)
-		if err != nil {
-			mlog.Error("Failed unmarshaling district file", mlog.Err(err),
-				mlog.Any("lang", lang),
-				mlog.Any("filename", filename),
-			)
-			return
+		if replacements := external[lang]; len(replacements) > 0 {
+			districtList = slices.DeleteFunc(districtList, func(d District) bool {
+				return slices.ContainsFunc(replacements, func(r District) bool { return r.ID == d.ID })
+			})
+			districtList = append(districtList, replacements...)
 		}
 
 		d := make(map[ID]District, len(districtList))
-		if districtLookup == nil {
-			// Initialize with a capacity, e.g., sum of lengths of all districtLists if known, or a reasonable default.
-			// For now, let's estimate based on the first language processed, this might need adjustment for optimal performance.
-			estimatedCapacity := len(districtList) * numberOfLanguages
-			districtLookup = make(map[string]ID, estimatedCapacity)
-		}
-
 		for _, district := range districtList {
 			if _, exists := d[district.ID]; exists {
 				i := 2
@@ -117,27 +130,113 @@ func initDistricts() {
 					}
 					i++
 				}
-				mlog.Warn("Duplicate district ID", mlog.Any("district", district), mlog.String("newID", string(district.ID)))
+				mlog.Debug("Duplicate district ID", mlog.Any("district", district), mlog.String("newID", string(district.ID)))
 			}
 			d[district.ID] = district
 			// Use normalized city name for lookup
 			normalizedSettlementName := normalizeCityName(district.SettlementName)
 			if normalizedSettlementName != "" { // Avoid empty keys if normalization results in an empty string
-				districtLookup[normalizedSettlementName] = district.ID
+				lookup[normalizedSettlementName] = district.ID
 			}
 		}
+		loaded[lang] = d
+	}
+
+	shapes, err := loadGeometryFile()
+	if err != nil {
+		mlog.Error("Failed loading district geometry", mlog.Err(err))
+		shapes = make(map[ID]*Shape, len(geometry))
+	}
+	maps.Copy(shapes, geometry)
+
+	result := &dataset{
+		districts: make(Districts, numberOfLanguages),
+		lookup:    lookup,
+		coverage:  make(map[config.Language]*Coverage, numberOfLanguages),
+		matcher:   newMatcher(loaded["he"], lookup),
+		geometry:  shapes,
+		digest:    digest,
+	}
+	for _, lang := range config.Languages {
+		result.districts[lang], result.coverage[lang] = complete(lang, loaded)
+	}
+	return result
+}
 
-		districts[lang] = d
+func loadDistrictFile(lang config.Language) ([]District, error) {
+	content, err := fs.ReadFile(districtFS, fmt.Sprintf("districts.%s.json", lang))
+	if err != nil {
+		return nil, err
 	}
+	var districtList []District
+	if err := json.Unmarshal(content, &districtList); err != nil {
+		return nil, fmt.Errorf("unmarshaling districts.%s.json: %w", lang, err)
+	}
+	return districtList, nil
+}
+
+// complete adds the Hebrew districts missing from lang's own, keeping the area names consistent with its other
+// districts where it has some in the same area.
+func complete(lang config.Language, loaded Districts) (map[ID]District, *Coverage) {
+	own := loaded[lang]
+	result := make(map[ID]District, len(loaded["he"]))
+	areaNames := make(map[int]string)
+	for id, d := range own {
+		result[id] = d
+		areaNames[d.AreaID] = d.AreaName
+	}
+	c := &Coverage{}
+	ids := slices.Sorted(maps.Keys(loaded["he"]))
+	for _, id := range ids {
+		if _, ok := own[id]; ok {
+			continue
+		}
+		d, found := District{}, false
+		for _, fallback := range lang.Fallbacks() {
+			if d, found = loaded[fallback][id]; found {
+				c.Fallback = append(c.Fallback, id)
+				break
+			}
+		}
+		if !found {
+			d = loaded["he"][id]
+			d.SettlementName = Transliterate(d.SettlementName)
+			d.AreaName = Transliterate(d.AreaName)
+			c.Transliterated = append(c.Transliterated, id)
+		}
+		if name, ok := areaNames[d.AreaID]; ok {
+			d.AreaName = name
+		}
+		result[id] = d
+	}
+	return result, c
+}
+
+// GetCoverage returns per language the districts it has no name of its own for.
+func GetCoverage() map[config.Language]*Coverage {
+	return data().coverage
 }
 
 func GetDistricts() Districts {
-	once.Do(initDistricts)
-	return districts
+	return data().districts
 }
 
 func GetDistrictByCity(city string) ID {
-	once.Do(initDistricts) // Ensure districts are initialized
 	normalizedCity := normalizeCityName(city)
-	return districtLookup[normalizedCity]
+	return data().lookup[normalizedCity]
+}
+
+// GetDistrictsByArea returns the districts whose area matches the numeric area ID or area name in any language.
+func GetDistrictsByArea(area string) map[ID]bool {
+	districts := GetDistricts()
+	result := make(map[ID]bool)
+	areaID, err := strconv.Atoi(area)
+	for _, lang := range config.Languages {
+		for id, d := range districts[lang] {
+			if err == nil && d.AreaID == areaID || strings.EqualFold(d.AreaName, area) {
+				result[id] = true
+			}
+		}
+	}
+	return result
 }
@@ -10,6 +10,7 @@ func TestInitDistricts(t *testing.T) {
 	// Reset the once variable to allow reinitialization
 	once = sync.Once{}
 	initDistricts()
+	districts := current.Load().districts
 
 	if len(districts) == 0 {
 		t.Errorf("Expected districts to be initialized, but it was empty")
@@ -128,3 +129,40 @@ func TestGetDistrictByCity_Normalization(t *testing.T) {
 		})
 	}
 }
+
+func TestGetCoverage(t *testing.T) {
+	once = sync.Once{}
+	all := GetDistricts()
+	coverage := GetCoverage()
+	for _, lang := range config.Languages {
+		for id := range all["he"] {
+			if all[lang][id].SettlementName == "" {
+				t.Errorf("district %s has no name in %s", id, lang)
+			}
+		}
+	}
+	if len(coverage["he"].Fallback) != 0 || len(coverage["he"].Transliterated) != 0 {
+		t.Errorf("Hebrew is the reference and should be complete, got %+v", coverage["he"])
+	}
+	if got, want := all["fr"]["999"].SettlementName, all["en"]["999"].SettlementName; got != want {
+		t.Errorf("French falls back to English, got %q, want %q", got, want)
+	}
+	if got := all["en"]["6102"].SettlementName; got != Transliterate(all["he"]["6102"].SettlementName) {
+		t.Errorf("districts without a translation are transliterated, got %q", got)
+	}
+}
+
+func TestTransliterate(t *testing.T) {
+	tests := map[string]string{
+		"עין חרוד":      "Ein Harod",
+		"שושנת העמקים":  "Shoshanat Hamakim",
+		"תל אביב - יפו": "Tal Aviv - Yafo",
+		"ג'ת":           "Jat",
+		"כפר סבא":       "Kafar Sava",
+	}
+	for hebrew, want := range tests {
+		if got := Transliterate(hebrew); got != want {
+			t.Errorf("Transliterate(%q) = %q, want %q", hebrew, got, want)
+		}
+	}
+}
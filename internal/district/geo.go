@@ -0,0 +1,269 @@
+package district
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"math"
+	"slices"
+)
+
+// GeometryFile is the GeoJSON file of district shapes, embedded next to the district files and read by Load and
+// UpdateGeometry from theirs.
+const GeometryFile = "districts.geojson"
+
+// Point is a WGS 84 position in degrees.
+type Point struct {
+	Lat float64
+	Lon float64
+}
+
+// Ring is a closed line of a polygon, the first ring of a polygon is its outline and the others are holes.
+type Ring []Point
+
+// Shape is where a district lies. Polygons is empty for districts only known by a point.
+type Shape struct {
+	Centroid Point
+	Polygons [][]Ring
+	// min and max bound the polygons, Locate skips shapes whose box does not hold the point
+	min, max Point
+}
+
+// geoJSON covers the parts of a GeoJSON FeatureCollection districts are read from, each feature names its district
+// in an id property.
+type geoJSON struct {
+	Type     string `json:"type"`
+	Features []struct {
+		Properties struct {
+			ID       flexString `json:"id"`
+			Centroid []float64  `json:"centroid"`
+		} `json:"properties"`
+		Geometry *struct {
+			Type        string          `json:"type"`
+			Coordinates json.RawMessage `json:"coordinates"`
+		} `json:"geometry"`
+	} `json:"features"`
+}
+
+// parseGeometry reads a FeatureCollection of Polygon, MultiPolygon and Point features. A feature's centroid property,
+// [lon, lat] like GeoJSON positions, takes precedence over the centroid of its polygons.
+func parseGeometry(content []byte) (map[ID]*Shape, error) {
+	var collection geoJSON
+	if err := json.Unmarshal(content, &collection); err != nil {
+		return nil, err
+	}
+	if collection.Type != "FeatureCollection" {
+		return nil, fmt.Errorf("expected a FeatureCollection, got %q", collection.Type)
+	}
+	shapes := make(map[ID]*Shape, len(collection.Features))
+	for i, feature := range collection.Features {
+		id := ID(feature.Properties.ID)
+		if id == "" || feature.Geometry == nil {
+			return nil, fmt.Errorf("feature %d lacks an id or geometry", i)
+		}
+		shape := &Shape{}
+		var err error
+		switch geometry := feature.Geometry; geometry.Type {
+		case "Point":
+			var position []float64
+			if err = json.Unmarshal(geometry.Coordinates, &position); err == nil {
+				shape.Centroid, err = toPoint(position)
+			}
+		case "Polygon":
+			var polygon [][][]float64
+			if err = json.Unmarshal(geometry.Coordinates, &polygon); err == nil {
+				err = shape.addPolygon(polygon)
+			}
+		case "MultiPolygon":
+			var polygons [][][][]float64
+			if err = json.Unmarshal(geometry.Coordinates, &polygons); err == nil {
+				for _, polygon := range polygons {
+					if err = shape.addPolygon(polygon); err != nil {
+						break
+					}
+				}
+			}
+		default:
+			err = fmt.Errorf("unsupported geometry %q", geometry.Type)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("feature %d (%s): %w", i, id, err)
+		}
+		if len(shape.Polygons) > 0 {
+			shape.Centroid = centroid(shape.Polygons)
+		}
+		if centroid := feature.Properties.Centroid; centroid != nil {
+			if shape.Centroid, err = toPoint(centroid); err != nil {
+				return nil, fmt.Errorf("feature %d (%s) centroid: %w", i, id, err)
+			}
+		}
+		shapes[id] = shape
+	}
+	return shapes, nil
+}
+
+func loadGeometryFile() (map[ID]*Shape, error) {
+	content, err := fs.ReadFile(districtFS, GeometryFile)
+	if err != nil {
+		return nil, err
+	}
+	return parseGeometry(bytes.TrimPrefix(content, []byte("\ufeff")))
+}
+
+func toPoint(position []float64) (Point, error) {
+	if len(position) < 2 {
+		return Point{}, fmt.Errorf("position %v lacks a coordinate", position)
+	}
+	p := Point{Lat: position[1], Lon: position[0]}
+	if math.Abs(p.Lat) > 90 || math.Abs(p.Lon) > 180 {
+		return Point{}, fmt.Errorf("position %v is out of range", position)
+	}
+	return p, nil
+}
+
+func (s *Shape) addPolygon(coordinates [][][]float64) error {
+	if len(coordinates) == 0 {
+		return fmt.Errorf("empty polygon")
+	}
+	var polygon []Ring
+	for _, line := range coordinates {
+		if len(line) < 4 {
+			return fmt.Errorf("ring of %d positions, at least 4 are needed", len(line))
+		}
+		ring := make(Ring, 0, len(line))
+		for _, position := range line {
+			p, err := toPoint(position)
+			if err != nil {
+				return err
+			}
+			if len(s.Polygons) == 0 && len(polygon) == 0 && len(ring) == 0 {
+				s.min, s.max = p, p
+			}
+			s.min = Point{Lat: min(s.min.Lat, p.Lat), Lon: min(s.min.Lon, p.Lon)}
+			s.max = Point{Lat: max(s.max.Lat, p.Lat), Lon: max(s.max.Lon, p.Lon)}
+			ring = append(ring, p)
+		}
+		polygon = append(polygon, ring)
+	}
+	s.Polygons = append(s.Polygons, polygon)
+	return nil
+}
+
+// centroid is the area weighted center of the polygons' outlines, districts are small enough to treat degrees as
+// planar.
+func centroid(polygons [][]Ring) Point {
+	var area, lat, lon float64
+	for _, polygon := range polygons {
+		outline := polygon[0]
+		for i := 0; i < len(outline)-1; i++ {
+			a, b := outline[i], outline[i+1]
+			cross := a.Lon*b.Lat - b.Lon*a.Lat
+			area += cross
+			lon += (a.Lon + b.Lon) * cross
+			lat += (a.Lat + b.Lat) * cross
+		}
+	}
+	if area == 0 {
+		// degenerate outline, fall back to the average of its positions
+		var n float64
+		for _, polygon := range polygons {
+			for _, p := range polygon[0] {
+				lat, lon, n = lat+p.Lat, lon+p.Lon, n+1
+			}
+		}
+		return Point{Lat: lat / n, Lon: lon / n}
+	}
+	return Point{Lat: lat / (3 * area), Lon: lon / (3 * area)}
+}
+
+// contains tells whether p lies in the shape, inside an outline and outside its holes.
+func (s *Shape) contains(p Point) bool {
+	if p.Lat < s.min.Lat || p.Lat > s.max.Lat || p.Lon < s.min.Lon || p.Lon > s.max.Lon {
+		return false
+	}
+	for _, polygon := range s.Polygons {
+		if !polygon[0].contains(p) {
+			continue
+		}
+		inHole := slices.ContainsFunc(polygon[1:], func(hole Ring) bool { return hole.contains(p) })
+		if !inHole {
+			return true
+		}
+	}
+	return false
+}
+
+// contains casts a ray from p eastwards and counts the edges it crosses.
+func (r Ring) contains(p Point) bool {
+	inside := false
+	for i, j := 0, len(r)-1; i < len(r); j, i = i, i+1 {
+		a, b := r[i], r[j]
+		if (a.Lat > p.Lat) != (b.Lat > p.Lat) && p.Lon < (b.Lon-a.Lon)*(p.Lat-a.Lat)/(b.Lat-a.Lat)+a.Lon {
+			inside = !inside
+		}
+	}
+	return inside
+}
+
+// earthRadiusKm is the mean radius of the earth.
+const earthRadiusKm = 6371.0
+
+// Distance returns the great-circle distance between a and b in kilometers.
+func Distance(a Point, b Point) float64 {
+	toRadians := func(degrees float64) float64 { return degrees * math.Pi / 180 }
+	dLat := toRadians(b.Lat - a.Lat)
+	dLon := toRadians(b.Lon - a.Lon)
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(toRadians(a.Lat))*math.Cos(toRadians(b.Lat))*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadiusKm * math.Asin(math.Sqrt(h))
+}
+
+// GetShape returns where the district lies, false when no geometry was loaded for it.
+func GetShape(id ID) (*Shape, bool) {
+	shape, ok := data().geometry[id]
+	return shape, ok
+}
+
+// Locate returns the district whose polygons contain the position, empty when none does. Where polygons overlap the
+// district with the nearest centroid wins.
+func Locate(lat float64, lon float64) ID {
+	p := Point{Lat: lat, Lon: lon}
+	var found ID
+	nearest := math.Inf(1)
+	for id, shape := range data().geometry {
+		if !shape.contains(p) {
+			continue
+		}
+		if distance := Distance(p, shape.Centroid); distance < nearest || distance == nearest && id < found {
+			found, nearest = id, distance
+		}
+	}
+	return found
+}
+
+// Neighbors returns the districts whose centroid is within km of the centroid of id, nearest first and id itself
+// left out. Districts without geometry have no neighbors.
+func Neighbors(id ID, km float64) []ID {
+	geometry := data().geometry
+	origin, ok := geometry[id]
+	if !ok {
+		return nil
+	}
+	distances := make(map[ID]float64)
+	var result []ID
+	for other, shape := range geometry {
+		if other == id {
+			continue
+		}
+		if distance := Distance(origin.Centroid, shape.Centroid); distance <= km {
+			distances[other] = distance
+			result = append(result, other)
+		}
+	}
+	slices.SortFunc(result, func(a, b ID) int {
+		return cmp.Or(cmp.Compare(distances[a], distances[b]), cmp.Compare(a, b))
+	})
+	return result
+}
@@ -0,0 +1,95 @@
+package district
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/color"
+	"image/png"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoad_Geometry(t *testing.T) {
+	t.Cleanup(func() { current.Store(buildDataset(nil, nil, "")) })
+	assert.Equal(t, ID(""), Locate(33.28, 35.58), "no geometry is embedded")
+	assert.Nil(t, Neighbors("738", 100))
+
+	changed, err := Load(context.Background(), http.DefaultClient, filepath.Join("testdata", "geo"))
+	require.NoError(t, err)
+	assert.True(t, changed)
+	assert.Equal(t, "מטולה", GetDistricts()["he"]["738"].SettlementName, "the districts stay embedded")
+
+	assert.Equal(t, ID("738"), Locate(33.28, 35.58))
+	assert.Equal(t, ID("1132"), Locate(33.22, 35.56))
+	assert.Equal(t, ID("1132"), Locate(33.205, 35.605), "second polygon of a multipolygon")
+	assert.Equal(t, ID(""), Locate(33.205, 35.57), "inside a hole")
+	assert.Equal(t, ID(""), Locate(32.56, 35.39), "points have no area")
+
+	metula, ok := GetShape("738")
+	require.True(t, ok)
+	assert.InDelta(t, 33.28, metula.Centroid.Lat, 1e-9)
+	assert.InDelta(t, 35.58, metula.Centroid.Lon, 1e-9)
+	kfarGiladi, _ := GetShape("593")
+	assert.Equal(t, Point{Lat: 33.24, Lon: 35.57}, kfarGiladi.Centroid, "centroid property")
+
+	assert.Equal(t, []ID{"593", "1132"}, Neighbors("738", 10))
+	assert.Equal(t, []ID{"593", "1132", "999"}, Neighbors("738", 200))
+	assert.Empty(t, Neighbors("738", 1))
+}
+
+func TestLoadGeometryFile(t *testing.T) {
+	_, err := loadGeometryFile()
+	require.NoError(t, err, "the embedded %s must parse", GeometryFile)
+}
+
+func TestParseGeometry_Errors(t *testing.T) {
+	_, err := parseGeometry([]byte(`{"type": "Feature"}`))
+	assert.EqualError(t, err, `expected a FeatureCollection, got "Feature"`)
+	_, err = parseGeometry([]byte(`{"type": "FeatureCollection", "features": [{"properties": {"id": "1"}, "geometry": {"type": "LineString", "coordinates": []}}]}`))
+	assert.EqualError(t, err, `feature 0 (1): unsupported geometry "LineString"`)
+	_, err = parseGeometry([]byte(`{"type": "FeatureCollection", "features": [{"properties": {"id": "1"}, "geometry": {"type": "Point", "coordinates": [35, 95]}}]}`))
+	assert.EqualError(t, err, `feature 0 (1): position [35 95] is out of range`)
+
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, GeometryFile), []byte(`{"type": "FeatureCollection", "features": [{}]}`), 0o644))
+	_, err = Load(context.Background(), http.DefaultClient, dir)
+	assert.EqualError(t, err, "parsing districts.geojson: feature 0 lacks an id or geometry")
+}
+
+func TestDistance(t *testing.T) {
+	// Metula to Eilat
+	assert.InDelta(t, 418, Distance(Point{Lat: 33.28, Lon: 35.58}, Point{Lat: 29.56, Lon: 34.95}), 5)
+	assert.Zero(t, Distance(Point{Lat: 32, Lon: 35}, Point{Lat: 32, Lon: 35}))
+}
+
+func TestRenderMap(t *testing.T) {
+	t.Cleanup(func() { current.Store(buildDataset(nil, nil, "")) })
+	assert.Nil(t, RenderMap([]ID{"738"}), "no geometry, no map")
+
+	_, err := Load(context.Background(), http.DefaultClient, filepath.Join("testdata", "geo"))
+	require.NoError(t, err)
+	assert.Nil(t, RenderMap([]ID{"5000"}))
+
+	content := RenderMap([]ID{"738", "999"})
+	require.NotNil(t, content)
+	img, err := png.Decode(bytes.NewReader(content))
+	require.NoError(t, err)
+	assert.Equal(t, image.Rect(0, 0, mapSize, mapSize), img.Bounds())
+
+	view := newMapView([]*Shape{data().geometry["738"], data().geometry["999"]})
+	colorAt := func(p Point) color.Color {
+		x, y := view.project(p)
+		return img.At(int(x), int(y))
+	}
+	assert.Equal(t, color.Color(mapAlerted), colorAt(Point{Lat: 33.28, Lon: 35.58}), "alerted polygon")
+	assert.Equal(t, color.Color(mapAlerted), colorAt(Point{Lat: 32.56, Lon: 35.39}), "alerted point")
+	assert.Equal(t, color.Color(mapDistrict), colorAt(Point{Lat: 33.22, Lon: 35.56}), "other district")
+	assert.Equal(t, color.Color(mapBackground), colorAt(Point{Lat: 33.205, Lon: 35.57}), "hole")
+	assert.Equal(t, color.Color(mapBackground), colorAt(Point{Lat: 33.0, Lon: 35.2}))
+}
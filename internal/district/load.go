@@ -0,0 +1,78 @@
+package district
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/phntom/goalert/internal/config"
+	"io/fs"
+	"net/http"
+)
+
+// Load reads districts.<code>.json files from source, a directory or a URL, over the embedded districts and swaps
+// them in at once, together with the district shapes of a districts.geojson file that replace the embedded shapes of
+// the same districts. Languages without a file keep the embedded districts. It reports whether the districts changed,
+// files identical to the ones loaded last time are not indexed again. An empty source goes back to the embedded
+// districts, on error the districts in use are kept.
+func Load(ctx context.Context, client *http.Client, source string) (bool, error) {
+	if source == "" {
+		if data().digest == "" {
+			return false, nil
+		}
+		current.Store(buildDataset(nil, nil, ""))
+		return true, nil
+	}
+
+	external := make(map[config.Language][]District)
+	hash := sha256.New()
+	for _, lang := range config.Languages {
+		name := fmt.Sprintf("districts.%s.json", lang)
+		content, err := readSource(ctx, client, source, name)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return false, fmt.Errorf("reading %s: %w", name, err)
+		}
+		content = bytes.TrimPrefix(content, []byte("\ufeff"))
+		var districtList []District
+		if err := json.Unmarshal(content, &districtList); err != nil {
+			return false, fmt.Errorf("parsing %s: %w", name, err)
+		}
+		for i, d := range districtList {
+			if d.ID == "" || d.SettlementName == "" {
+				return false, fmt.Errorf("%s: entry %d lacks an id or label", name, i)
+			}
+		}
+		external[lang] = districtList
+		hash.Write([]byte(name))
+		hash.Write(content)
+	}
+
+	var geometry map[ID]*Shape
+	content, err := readSource(ctx, client, source, GeometryFile)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return false, fmt.Errorf("reading %s: %w", GeometryFile, err)
+	}
+	if err == nil {
+		if geometry, err = parseGeometry(bytes.TrimPrefix(content, []byte("\ufeff"))); err != nil {
+			return false, fmt.Errorf("parsing %s: %w", GeometryFile, err)
+		}
+		hash.Write([]byte(GeometryFile))
+		hash.Write(content)
+	}
+	if len(external) == 0 && geometry == nil {
+		return false, fmt.Errorf("%s has no districts.<code>.json or %s files", source, GeometryFile)
+	}
+
+	digest := hex.EncodeToString(hash.Sum(nil))
+	if digest == data().digest {
+		return false, nil
+	}
+	current.Store(buildDataset(external, geometry, digest))
+	return true, nil
+}
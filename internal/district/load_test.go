@@ -0,0 +1,71 @@
+package district
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoad(t *testing.T) {
+	t.Cleanup(func() { current.Store(buildDataset(nil, nil, "")) })
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "districts.en.json"), []byte(`[
+  {"areaid": 25, "areaname": "Center Negev", "id": "63", "label": "Idan HaNegev Industry", "label_he": "אזור תעשייה עידן הנגב", "migun_time": 30, "value": "C8A6"},
+  {"areaid": 34, "areaname": "HaAmakim", "id": "90000", "label": "Brand New Settlement", "label_he": "ישוב חדש לגמרי", "migun_time": 60, "value": "D4"}
+]`), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "districts.he.json"), []byte(`[
+  {"areaid": 34, "areaname": "העמקים", "id": "90000", "label": "ישוב חדש לגמרי", "label_he": "ישוב חדש לגמרי", "migun_time": 60, "value": "D4"}
+]`), 0o644))
+
+	embedded := GetDistricts()
+	changed, err := Load(context.Background(), http.DefaultClient, dir)
+	require.NoError(t, err)
+	assert.True(t, changed)
+	assert.Equal(t, "Idan HaNegev Industry", GetDistricts()["en"]["63"].SettlementName, "replaces the embedded entry")
+	assert.Equal(t, 30, GetDistricts()["en"]["63"].SafetyBufferSeconds)
+	assert.Equal(t, ID("90000"), GetDistrictByCity("Brand New Settlement"))
+	assert.Equal(t, ID("90000"), GetDistrictByCity("ישוב חדש לגמרי"))
+	assert.Equal(t, embedded["ru"]["63"], GetDistricts()["ru"]["63"], "languages without a file keep the embedded districts")
+	assert.Equal(t, "Idan HaNegev Industrial Zone", embedded["en"]["63"].SettlementName, "earlier readers keep their generation")
+
+	changed, err = Load(context.Background(), http.DefaultClient, dir)
+	require.NoError(t, err)
+	assert.False(t, changed, "unchanged files are not indexed again")
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "districts.en.json"), []byte(`[{"id": "1"`), 0o644))
+	_, err = Load(context.Background(), http.DefaultClient, dir)
+	assert.ErrorContains(t, err, "parsing districts.en.json")
+	assert.Equal(t, ID("90000"), GetDistrictByCity("Brand New Settlement"), "a broken file keeps the districts in use")
+
+	_, err = Load(context.Background(), http.DefaultClient, t.TempDir())
+	assert.ErrorContains(t, err, "has no districts.<code>.json or districts.geojson files")
+
+	changed, err = Load(context.Background(), http.DefaultClient, "")
+	require.NoError(t, err)
+	assert.True(t, changed)
+	assert.Equal(t, ID(""), GetDistrictByCity("Brand New Settlement"), "an empty source goes back to the embedded districts")
+}
+
+func TestLoad_URL(t *testing.T) {
+	t.Cleanup(func() { current.Store(buildDataset(nil, nil, "")) })
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/districts/districts.he.json" {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write([]byte("\ufeff" + `[{"areaid": 34, "areaname": "העמקים", "id": "90001", "label": "מצפה חדש", "label_he": "מצפה חדש", "migun_time": 60, "value": "D5"}]`))
+	}))
+	defer server.Close()
+
+	changed, err := Load(context.Background(), server.Client(), server.URL+"/districts/")
+	require.NoError(t, err)
+	assert.True(t, changed)
+	assert.Equal(t, ID("90001"), GetDistrictByCity("מצפה חדש"))
+	assert.Equal(t, Transliterate("מצפה חדש"), GetDistricts()["fr"]["90001"].SettlementName, "languages lacking it are completed as usual")
+}
@@ -0,0 +1,191 @@
+package district
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+	"slices"
+)
+
+// mapSize is the width and height of maps in pixels.
+const mapSize = 512
+
+// minMapSpan is the least height of a map in degrees, about 15km, so a single village still shows its surroundings.
+const minMapSpan = 0.15
+
+var (
+	mapBackground    = color.RGBA{R: 0xF4, G: 0xF1, B: 0xEA, A: 0xFF}
+	mapDistrict      = color.RGBA{R: 0xDD, G: 0xDB, B: 0xD5, A: 0xFF}
+	mapBorder        = color.RGBA{R: 0xA8, G: 0xA5, B: 0x9E, A: 0xFF}
+	mapAlerted       = color.RGBA{R: 0xCF, G: 0x14, B: 0x34, A: 0xFF}
+	mapAlertedBorder = color.RGBA{R: 0x7A, G: 0x0B, B: 0x1E, A: 0xFF}
+)
+
+// districts known only by a point are drawn as squares of these half sizes
+const (
+	alertedPointSize  = 6
+	districtPointSize = 2
+)
+
+// RenderMap draws the districts with geometry around ids as a PNG, with those of ids highlighted. It returns nil when
+// none of ids has geometry.
+func RenderMap(ids []ID) []byte {
+	geometry := data().geometry
+	var alerted []*Shape
+	for _, id := range ids {
+		if shape, ok := geometry[id]; ok {
+			alerted = append(alerted, shape)
+		}
+	}
+	if len(alerted) == 0 {
+		return nil
+	}
+	view := newMapView(alerted)
+	img := image.NewRGBA(image.Rect(0, 0, mapSize, mapSize))
+	fill(img, img.Bounds(), mapBackground)
+	for id, shape := range geometry {
+		if !slices.Contains(ids, id) && view.shows(shape) {
+			view.draw(img, shape, mapDistrict, mapBorder, districtPointSize)
+		}
+	}
+	for _, shape := range alerted {
+		view.draw(img, shape, mapAlerted, mapAlertedBorder, alertedPointSize)
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil
+	}
+	return buf.Bytes()
+}
+
+// mapView projects positions onto the map, equirectangular around its center which is accurate enough at the scale
+// of a country.
+type mapView struct {
+	min, max Point
+	// scale is pixels per degree of latitude, lonScale shrinks degrees of longitude by the cosine of the latitude
+	scale, lonScale float64
+}
+
+func newMapView(shapes []*Shape) mapView {
+	lo, hi := bounds(shapes[0])
+	for _, shape := range shapes[1:] {
+		shapeMin, shapeMax := bounds(shape)
+		lo = Point{Lat: min(lo.Lat, shapeMin.Lat), Lon: min(lo.Lon, shapeMin.Lon)}
+		hi = Point{Lat: max(hi.Lat, shapeMax.Lat), Lon: max(hi.Lon, shapeMax.Lon)}
+	}
+	center := Point{Lat: (lo.Lat + hi.Lat) / 2, Lon: (lo.Lon + hi.Lon) / 2}
+	lonScale := math.Cos(center.Lat * math.Pi / 180)
+	// a margin of a tenth on every side, the longer side decides the span of the square map
+	span := max(hi.Lat-lo.Lat, (hi.Lon-lo.Lon)*lonScale, minMapSpan) * 1.2
+	return mapView{
+		min:      Point{Lat: center.Lat - span/2, Lon: center.Lon - span/2/lonScale},
+		max:      Point{Lat: center.Lat + span/2, Lon: center.Lon + span/2/lonScale},
+		scale:    mapSize / span,
+		lonScale: lonScale,
+	}
+}
+
+// bounds returns the corners of the box around the shape, its centroid for shapes without polygons.
+func bounds(shape *Shape) (Point, Point) {
+	if len(shape.Polygons) == 0 {
+		return shape.Centroid, shape.Centroid
+	}
+	return shape.min, shape.max
+}
+
+func (v mapView) shows(shape *Shape) bool {
+	lo, hi := bounds(shape)
+	return hi.Lat >= v.min.Lat && lo.Lat <= v.max.Lat && hi.Lon >= v.min.Lon && lo.Lon <= v.max.Lon
+}
+
+func (v mapView) project(p Point) (float64, float64) {
+	return (p.Lon - v.min.Lon) * v.lonScale * v.scale, (v.max.Lat - p.Lat) * v.scale
+}
+
+func (v mapView) draw(img *image.RGBA, shape *Shape, area color.RGBA, border color.RGBA, pointSize int) {
+	if len(shape.Polygons) == 0 {
+		x, y := v.project(shape.Centroid)
+		cx, cy := int(math.Round(x)), int(math.Round(y))
+		fill(img, image.Rect(cx-pointSize, cy-pointSize, cx+pointSize+1, cy+pointSize+1), border)
+		fill(img, image.Rect(cx-pointSize+1, cy-pointSize+1, cx+pointSize, cy+pointSize), area)
+		return
+	}
+	for _, polygon := range shape.Polygons {
+		rings := make([][][2]float64, len(polygon))
+		for i, ring := range polygon {
+			for _, p := range ring {
+				x, y := v.project(p)
+				rings[i] = append(rings[i], [2]float64{x, y})
+			}
+		}
+		fillPolygon(img, rings, area)
+		for _, ring := range rings {
+			for i := 1; i < len(ring); i++ {
+				line(img, ring[i-1], ring[i], border)
+			}
+		}
+	}
+}
+
+func fill(img *image.RGBA, r image.Rectangle, c color.RGBA) {
+	r = r.Intersect(img.Bounds())
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			img.SetRGBA(x, y, c)
+		}
+	}
+}
+
+// fillPolygon fills the pixels whose centers are inside an odd number of rings, which leaves the holes out. Only the
+// rows the rings span are scanned.
+func fillPolygon(img *image.RGBA, rings [][][2]float64, c color.RGBA) {
+	bounds := img.Bounds()
+	top, bottom := math.Inf(1), math.Inf(-1)
+	for _, ring := range rings {
+		for _, p := range ring {
+			top, bottom = min(top, p[1]), max(bottom, p[1])
+		}
+	}
+	if math.IsInf(top, 1) {
+		return
+	}
+	var crossings []float64
+	for y := max(int(math.Floor(top)), bounds.Min.Y); y < min(int(math.Ceil(bottom))+1, bounds.Max.Y); y++ {
+		scan := float64(y) + 0.5
+		crossings = crossings[:0]
+		for _, ring := range rings {
+			for i := 1; i < len(ring); i++ {
+				a, b := ring[i-1], ring[i]
+				if (a[1] > scan) != (b[1] > scan) {
+					crossings = append(crossings, a[0]+(scan-a[1])*(b[0]-a[0])/(b[1]-a[1]))
+				}
+			}
+		}
+		slices.Sort(crossings)
+		for i := 0; i+1 < len(crossings); i += 2 {
+			from := max(int(math.Ceil(crossings[i]-0.5)), bounds.Min.X)
+			to := min(int(math.Floor(crossings[i+1]-0.5)), bounds.Max.X-1)
+			for x := from; x <= to; x++ {
+				img.SetRGBA(x, y, c)
+			}
+		}
+	}
+}
+
+// line draws a one pixel line from a to b.
+func line(img *image.RGBA, a [2]float64, b [2]float64, c color.RGBA) {
+	steps := int(math.Ceil(max(math.Abs(b[0]-a[0]), math.Abs(b[1]-a[1]))))
+	for i := 0; i <= steps; i++ {
+		t := 0.0
+		if steps > 0 {
+			t = float64(i) / float64(steps)
+		}
+		x := int(math.Floor(a[0] + (b[0]-a[0])*t))
+		y := int(math.Floor(a[1] + (b[1]-a[1])*t))
+		if image.Pt(x, y).In(img.Bounds()) {
+			img.SetRGBA(x, y, c)
+		}
+	}
+}
@@ -0,0 +1,223 @@
+package district
+
+import (
+	_ "embed"
+	"github.com/mattermost/mattermost/server/public/shared/mlog"
+	"gopkg.in/yaml.v3"
+	"strings"
+	"unicode"
+)
+
+// MatchMethod tells how MatchCity found a district.
+type MatchMethod string
+
+const (
+	MatchExact MatchMethod = "exact"
+	// MatchAlias is a name listed in aliases.yaml
+	MatchAlias MatchMethod = "alias"
+	// MatchVariant is a spelling differing in spaces, hyphens or the place of "אזור תעשייה"
+	MatchVariant MatchMethod = "variant"
+	// MatchFuzzy is the only Hebrew name within a few typos
+	MatchFuzzy MatchMethod = "fuzzy"
+)
+
+// ConfidentMatch is the lowest confidence alerts are posted with no remark, below it the name is flagged as matched
+// approximately.
+const ConfidentMatch = 0.9
+
+// Match is the district a city name from an alert stands for. ID is empty when nothing matched.
+type Match struct {
+	ID ID
+	// Name is the city name as the source spelled it
+	Name       string
+	Method     MatchMethod
+	Confidence float64
+}
+
+func (m Match) Confident() bool {
+	return m.ID != "" && m.Confidence >= ConfidentMatch
+}
+
+//go:embed aliases.yaml
+var aliasesYAML []byte
+
+// matcher holds the indexes MatchCity searches once the exact lookup missed, keyed by normalized name.
+type matcher struct {
+	aliases map[string]ID
+	// compact is keyed by the name without spaces, names sharing a key are left out
+	compact map[string]ID
+	hebrew  []fuzzyName
+}
+
+type fuzzyName struct {
+	name []rune
+	id   ID
+}
+
+// newMatcher indexes the Hebrew names, those of the other languages are not what alerts are published in.
+func newMatcher(hebrew map[ID]District, lookup map[string]ID) matcher {
+	m := matcher{
+		aliases: make(map[string]ID),
+		compact: make(map[string]ID, len(hebrew)),
+	}
+	var aliases map[string]string
+	if err := yaml.Unmarshal(aliasesYAML, &aliases); err != nil {
+		mlog.Error("Failed parsing aliases.yaml", mlog.Err(err))
+	}
+	for alias, label := range aliases {
+		id := lookup[normalizeCityName(label)]
+		if id == "" {
+			mlog.Warn("City alias names an unknown district", mlog.String("alias", alias), mlog.String("label", label))
+			continue
+		}
+		m.aliases[normalizeCityName(alias)] = id
+	}
+
+	seen := make(map[string]bool, len(hebrew))
+	ambiguous := make(map[string]bool)
+	for _, d := range hebrew {
+		name := normalizeCityName(d.SettlementName)
+		id := lookup[name]
+		if name == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+		m.hebrew = append(m.hebrew, fuzzyName{name: []rune(name), id: id})
+		key := compactName(name)
+		if other, exists := m.compact[key]; exists && other != id || ambiguous[key] {
+			delete(m.compact, key)
+			ambiguous[key] = true
+			continue
+		}
+		m.compact[key] = id
+	}
+	return m
+}
+
+// compactName drops the spaces of a normalized name and spells איזור as אזור.
+func compactName(normalized string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(normalized, " ", ""), "איזור", "אזור")
+}
+
+// industrialZone starts or ends names of industrial zones, oref puts it on either side of the town.
+const industrialZone = "אזור תעשיה"
+
+// variants returns other spellings of a normalized name.
+func variants(name string) []string {
+	name = strings.ReplaceAll(name, "איזור", "אזור")
+	result := []string{name}
+	if town, found := strings.CutSuffix(name, " "+industrialZone); found {
+		result = append(result, industrialZone+" "+town)
+	}
+	if town, found := strings.CutPrefix(name, industrialZone+" "); found {
+		result = append(result, town+" "+industrialZone)
+	}
+	return result
+}
+
+// MatchCity finds the district an alert's city name stands for. Names missing from the districts are looked up in
+// aliases.yaml, then as variant spellings and last among the Hebrew names a few typos away, with a confidence
+// falling with every edit.
+func MatchCity(city string) Match {
+	d := data()
+	name := normalizeCityName(city)
+	if id := d.lookup[name]; id != "" {
+		return Match{ID: id, Name: city, Method: MatchExact, Confidence: 1}
+	}
+	if id := d.matcher.aliases[name]; id != "" {
+		return Match{ID: id, Name: city, Method: MatchAlias, Confidence: 0.95}
+	}
+	for _, variant := range variants(name) {
+		if id := d.lookup[variant]; id != "" {
+			return Match{ID: id, Name: city, Method: MatchVariant, Confidence: ConfidentMatch}
+		}
+		if id := d.matcher.compact[compactName(variant)]; id != "" {
+			return Match{ID: id, Name: city, Method: MatchVariant, Confidence: ConfidentMatch}
+		}
+	}
+	if id, confidence := d.matcher.fuzzy([]rune(name)); id != "" {
+		return Match{ID: id, Name: city, Method: MatchFuzzy, Confidence: confidence}
+	}
+	return Match{Name: city}
+}
+
+// maxEdits bounds the typos tolerated in a name of n letters, short names are too close to each other for any.
+func maxEdits(n int) int {
+	switch {
+	case n <= 3:
+		return 0
+	case n <= 6:
+		return 1
+	case n <= 12:
+		return 2
+	default:
+		return 3
+	}
+}
+
+// fuzzy returns the Hebrew name with the fewest edits from name, nothing when another one is as close.
+func (m matcher) fuzzy(name []rune) (ID, float64) {
+	limit := maxEdits(len(name))
+	if limit == 0 || !isHebrew(name) {
+		return "", 0
+	}
+	best, bestDistance, tie := fuzzyName{}, limit+1, false
+	for _, candidate := range m.hebrew {
+		distance := editDistance(name, candidate.name, bestDistance+1)
+		switch {
+		case distance < bestDistance:
+			best, bestDistance, tie = candidate, distance, false
+		case distance == bestDistance && candidate.id != best.id:
+			tie = true
+		}
+	}
+	if bestDistance > limit || tie {
+		return "", 0
+	}
+	return best.id, 1 - float64(bestDistance)/float64(max(len(name), len(best.name)))
+}
+
+func isHebrew(name []rune) bool {
+	for _, r := range name {
+		if unicode.Is(unicode.Hebrew, r) {
+			return true
+		}
+	}
+	return false
+}
+
+// editDistance is the Levenshtein distance between a and b, or limit when it is at least limit.
+func editDistance(a []rune, b []rune, limit int) int {
+	if abs(len(a)-len(b)) >= limit {
+		return limit
+	}
+	previous := make([]int, len(b)+1)
+	row := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		row[0] = i
+		smallest := row[0]
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			row[j] = min(previous[j]+1, row[j-1]+1, previous[j-1]+cost)
+			smallest = min(smallest, row[j])
+		}
+		if smallest >= limit {
+			return limit
+		}
+		previous, row = row, previous
+	}
+	return min(previous[len(b)], limit)
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
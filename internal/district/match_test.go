@@ -0,0 +1,55 @@
+package district
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v3"
+)
+
+func TestMatchCity(t *testing.T) {
+	tests := []struct {
+		city   string
+		label  string
+		method MatchMethod
+	}{
+		{"מטולה", "מטולה", MatchExact},
+		{"קרית שמונה", "קריית שמונה", MatchExact},
+		{"נצרת עילית", "נוף הגליל", MatchAlias},
+		{"פתח תקוה", "פתח תקווה", MatchAlias},
+		{"אזור תעשייה עכו", "עכו - אזור תעשייה", MatchVariant},
+		{"אום אל-פחם", "אום אל פחם", MatchVariant},
+		{"אשדוד - אזור תעשייה צפוני", "אשדוד - איזור תעשייה צפוני", MatchVariant},
+		{"באר שבעה", "באר שבע", MatchFuzzy},
+		{"מצפה רמן", "מצפה רמון", MatchFuzzy},
+	}
+	for _, tt := range tests {
+		t.Run(tt.city, func(t *testing.T) {
+			match := MatchCity(tt.city)
+			assert.Equal(t, tt.label, GetDistricts()["he"][match.ID].SettlementName)
+			assert.Equal(t, tt.method, match.Method)
+			assert.Equal(t, tt.city, match.Name)
+			assert.Equal(t, tt.method != MatchFuzzy, match.Confident(), "confidence %v", match.Confidence)
+		})
+	}
+
+	for _, city := range []string{"", "עין", "Springfield", "ירושלים - מרכז העיר"} {
+		assert.Equal(t, Match{Name: city}, MatchCity(city), "%q matches nothing", city)
+	}
+}
+
+func TestAliases(t *testing.T) {
+	var aliases map[string]string
+	assert.NoError(t, yaml.Unmarshal(aliasesYAML, &aliases))
+	for alias, label := range aliases {
+		assert.NotEmpty(t, GetDistrictByCity(label), "alias %q names an unknown district %q", alias, label)
+		assert.Empty(t, GetDistrictByCity(alias), "alias %q is a district of its own", alias)
+	}
+}
+
+func TestEditDistance(t *testing.T) {
+	assert.Equal(t, 0, editDistance([]rune("מטולה"), []rune("מטולה"), 3))
+	assert.Equal(t, 1, editDistance([]rune("מטולה"), []rune("מטולא"), 3))
+	assert.Equal(t, 2, editDistance([]rune("אשקלן"), []rune("אשקלון צפון"), 2), "capped at the limit")
+	assert.Equal(t, 3, editDistance([]rune("kitten"), []rune("sitting"), 4))
+}
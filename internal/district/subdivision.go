@@ -90,6 +90,7 @@ func GetCity(id ID, lang config.Language) (string, []string) {
 		subsetId = id
 	}
 	lid := fmt.Sprintf("subdivision.replace.%s", id)
+	districts := GetDistricts()
 	n1 := config.GetTextOptional(lid, lang, districts[lang][subsetId].SettlementName)
 	n2 := config.GetTextOptional(lid, lang, districts[lang][id].SettlementName)
 	if strings.Contains(n1, " - ") {
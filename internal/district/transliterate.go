@@ -0,0 +1,116 @@
+package district
+
+import "strings"
+
+// hebrewToLatin maps Hebrew letters to the Latin spelling oref uses, context dependent letters are handled in
+// Transliterate.
+var hebrewToLatin = map[rune]string{
+	'ג': "g", 'ד': "d", 'ה': "h", 'ז': "z", 'ח': "h", 'ט': "t", 'ל': "l", 'מ': "m", 'ם': "m", 'נ': "n", 'ן': "n",
+	'ס': "s", 'צ': "tz", 'ץ': "tz", 'ק': "k", 'ר': "r", 'ש': "sh", 'ת': "t", 'ך': "kh", 'ף': "f",
+}
+
+// afterGeresh are the letters a geresh turns into sounds Hebrew lacks, as in ג'ולס.
+var afterGeresh = map[rune]string{'ג': "j", 'ז': "zh", 'צ': "ch", 'ץ': "ch"}
+
+// Transliterate spells a Hebrew place name in Latin letters. Hebrew leaves most vowels out, so the result only
+// approximates the usual spelling, e.g. עין חרוד becomes Ein Harod. It names districts no translation covers.
+func Transliterate(name string) string {
+	words := strings.Fields(name)
+	for i, word := range words {
+		words[i] = capitalizeUnicode(transliterateWord([]rune(word)))
+	}
+	return strings.Join(words, " ")
+}
+
+func transliterateWord(word []rune) string {
+	var b strings.Builder
+	// consonant is set after a letter read as a consonant, two in a row get an "a" between them
+	consonant := false
+	emit := func(s string, isConsonant bool) {
+		if isConsonant && consonant {
+			b.WriteString("a")
+		}
+		b.WriteString(s)
+		consonant = isConsonant
+	}
+	for i := 0; i < len(word); i++ {
+		r := word[i]
+		first, last := i == 0, i == len(word)-1
+		next := rune(0)
+		if !last {
+			next = word[i+1]
+		}
+		if next == '\'' || next == '׳' {
+			if s, ok := afterGeresh[r]; ok {
+				emit(s, true)
+				i++
+				continue
+			}
+		}
+		switch r {
+		case 'א', 'ע':
+			switch {
+			case first && next == 'י':
+				emit("ei", false)
+				i++
+			case next == 'ו':
+				consonant = false
+			default:
+				emit("a", false)
+			}
+		case 'ו':
+			switch {
+			case next == 'ו':
+				emit("v", true)
+				i++
+			case first:
+				emit("v", true)
+			default:
+				emit("o", false)
+			}
+		case 'י':
+			switch {
+			case next == 'י':
+				emit("y", true)
+				i++
+			case first:
+				emit("y", true)
+			default:
+				emit("i", false)
+			}
+		case 'ה':
+			if last && !first {
+				emit("a", false)
+			} else {
+				emit("h", true)
+			}
+		case 'ב':
+			if first {
+				emit("b", true)
+			} else {
+				emit("v", true)
+			}
+		case 'כ':
+			if first {
+				emit("k", true)
+			} else {
+				emit("kh", true)
+			}
+		case 'פ':
+			if first {
+				emit("p", true)
+			} else {
+				emit("f", true)
+			}
+		case '\'', '׳', '"', '״':
+		default:
+			if s, ok := hebrewToLatin[r]; ok {
+				emit(s, true)
+			} else {
+				b.WriteRune(r)
+				consonant = false
+			}
+		}
+	}
+	return b.String()
+}
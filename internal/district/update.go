@@ -0,0 +1,382 @@
+package district
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/phntom/goalert/internal/config"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// UpstreamURL serves oref's city lists, named by the upstream field of languages.yaml.
+const UpstreamURL = "https://www.oref.org.il/districts/"
+
+// UpstreamCity is an entry of oref's city lists. Fields missing upstream are left zero and taken from the
+// existing districts instead.
+type UpstreamCity struct {
+	Label     string     `json:"label"`
+	Value     string     `json:"value"`
+	ID        flexString `json:"id"`
+	AreaID    int        `json:"areaid"`
+	AreaName  string     `json:"areaname"`
+	MigunTime int        `json:"migun_time"`
+	CityAlID  string     `json:"cityAlId"`
+}
+
+// flexString accepts both JSON strings and numbers, oref has published ids as either.
+type flexString string
+
+func (f *flexString) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		*f = flexString(s)
+		return nil
+	}
+	var n json.Number
+	if err := json.Unmarshal(data, &n); err != nil {
+		return err
+	}
+	*f = flexString(n.String())
+	return nil
+}
+
+// label drops the alternative names oref appends to some cities, as in "בית שמש I Beit Shemesh".
+func (c UpstreamCity) label() string {
+	label, _, _ := strings.Cut(c.Label, " I ")
+	label, _, _ = strings.Cut(label, " | ")
+	return strings.TrimSpace(label)
+}
+
+// FetchUpstream reads the city list of every language that has one, from source which is either a URL or a
+// directory holding previously downloaded files.
+func FetchUpstream(ctx context.Context, client *http.Client, source string) (map[config.Language][]UpstreamCity, error) {
+	upstream := make(map[config.Language][]UpstreamCity)
+	for _, info := range config.LanguageInfos() {
+		if info.Upstream == "" {
+			continue
+		}
+		content, err := readSource(ctx, client, source, info.Upstream)
+		if err != nil {
+			return nil, fmt.Errorf("reading %s: %w", info.Upstream, err)
+		}
+		var cities []UpstreamCity
+		if err := json.Unmarshal(bytes.TrimPrefix(content, []byte("\ufeff")), &cities); err != nil {
+			return nil, fmt.Errorf("parsing %s: %w", info.Upstream, err)
+		}
+		upstream[info.Code] = cities
+	}
+	return upstream, nil
+}
+
+// readSource reads the file name from source, a URL or a directory. A file the server does not have is reported as
+// fs.ErrNotExist just like a missing local one.
+func readSource(ctx context.Context, client *http.Client, source string, name string) ([]byte, error) {
+	if !strings.HasPrefix(source, "http://") && !strings.HasPrefix(source, "https://") {
+		return os.ReadFile(filepath.Join(source, name))
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(source, "/")+"/"+name, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	//goland:noinspection GoUnhandledErrorResult
+	defer resp.Body.Close() //nolint:errcheck
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, fmt.Errorf("%s: %w", req.URL, fs.ErrNotExist)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+// UpdateReport lists what an update found in one language.
+type UpdateReport struct {
+	Language config.Language
+	Added    []District
+	Renamed  []Rename
+	// Removed lists districts oref no longer publishes, they are kept as alerts from other sources may name them
+	Removed   []District
+	MigunTime []MigunTimeChange
+}
+
+type Rename struct {
+	ID   ID
+	From []string
+	To   string
+}
+
+type MigunTimeChange struct {
+	ID       ID
+	Label    string
+	From, To int
+}
+
+func (r UpdateReport) Empty() bool {
+	return len(r.Added) == 0 && len(r.Renamed) == 0 && len(r.Removed) == 0 && len(r.MigunTime) == 0
+}
+
+// Update merges oref's city list into the existing districts of a language. Existing entries are never dropped or
+// relabeled, so local fixes survive: a renamed district gets a new entry next to its old name, which keeps finding
+// it by either name. hebrew is oref's Hebrew list, naming new districts in label_he.
+func Update(lang config.Language, existing []District, upstream []UpstreamCity, hebrew []UpstreamCity) ([]District, UpdateReport) {
+	report := UpdateReport{Language: lang}
+	result := slices.Clone(existing)
+	labels := make(map[ID][]string)
+	areas := make(map[int]District)
+	for _, d := range existing {
+		labels[d.ID] = append(labels[d.ID], d.SettlementName)
+		areas[d.AreaID] = d
+	}
+	hebrewLabels := make(map[ID]string, len(hebrew))
+	for _, city := range hebrew {
+		hebrewLabels[ID(city.ID)] = city.label()
+	}
+
+	seen := make(map[ID]bool, len(upstream))
+	for _, city := range upstream {
+		id := ID(city.ID)
+		label := city.label()
+		if id == "" || label == "" || seen[id] {
+			continue
+		}
+		seen[id] = true
+		known, exists := labels[id]
+		if !exists {
+			d := newDistrict(city, label, hebrewLabels[id], areas)
+			report.Added = append(report.Added, d)
+			result = append(result, d)
+			continue
+		}
+		if city.MigunTime > 0 {
+			for i := range result {
+				if result[i].ID != id || result[i].SafetyBufferSeconds == city.MigunTime {
+					continue
+				}
+				if len(report.MigunTime) == 0 || report.MigunTime[len(report.MigunTime)-1].ID != id {
+					report.MigunTime = append(report.MigunTime, MigunTimeChange{ID: id, Label: result[i].SettlementName, From: result[i].SafetyBufferSeconds, To: city.MigunTime})
+				}
+				result[i].SafetyBufferSeconds = city.MigunTime
+			}
+		}
+		if !slices.Contains(known, label) {
+			d := result[slices.IndexFunc(result, func(d District) bool { return d.ID == id })]
+			d.SettlementName = label
+			d.SettlementNameHebrew = cmp.Or(hebrewLabels[id], d.SettlementNameHebrew)
+			report.Renamed = append(report.Renamed, Rename{ID: id, From: known, To: label})
+			result = append(result, d)
+		}
+	}
+	for _, d := range existing {
+		if !seen[d.ID] {
+			seen[d.ID] = true
+			report.Removed = append(report.Removed, d)
+		}
+	}
+	sortDistricts(result)
+	return slices.Compact(result), report
+}
+
+// newDistrict takes what oref left out from the other districts in the same area.
+func newDistrict(city UpstreamCity, label string, hebrewLabel string, areas map[int]District) District {
+	area := areas[city.AreaID]
+	return District{
+		SettlementName:       label,
+		Value:                cmp.Or(city.CityAlID, city.Value),
+		ID:                   ID(city.ID),
+		AreaID:               city.AreaID,
+		AreaName:             cmp.Or(city.AreaName, area.AreaName),
+		SettlementNameHebrew: cmp.Or(hebrewLabel, label),
+		SafetyBufferSeconds:  cmp.Or(city.MigunTime, area.SafetyBufferSeconds),
+	}
+}
+
+// sortDistricts orders districts by numeric id and then name, keeping the files' diffs readable between updates.
+func sortDistricts(districts []District) {
+	slices.SortFunc(districts, func(a, b District) int {
+		an, aErr := strconv.Atoi(string(a.ID))
+		bn, bErr := strconv.Atoi(string(b.ID))
+		if aErr == nil && bErr == nil && an != bn {
+			return cmp.Compare(an, bn)
+		}
+		return cmp.Or(cmp.Compare(a.ID, b.ID), cmp.Compare(a.SettlementName, b.SettlementName), cmp.Compare(a.Value, b.Value))
+	})
+}
+
+// districtFileEntry lays a District out the way districts.*.json has it, keys sorted.
+type districtFileEntry struct {
+	AreaID               int    `json:"areaid"`
+	AreaName             string `json:"areaname"`
+	ID                   ID     `json:"id"`
+	SettlementName       string `json:"label"`
+	SettlementNameHebrew string `json:"label_he"`
+	SafetyBufferSeconds  int    `json:"migun_time"`
+	Value                string `json:"value"`
+}
+
+// ReadDistrictFile reads a districts.*.json file as is, duplicate ids included.
+func ReadDistrictFile(path string) ([]District, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var districtList []District
+	if err := json.Unmarshal(content, &districtList); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	return districtList, nil
+}
+
+// WriteDistrictFile writes districts in the layout of the embedded files, the odd null value becomes an empty string.
+func WriteDistrictFile(path string, districts []District) error {
+	entries := make([]districtFileEntry, 0, len(districts))
+	for _, d := range districts {
+		entries = append(entries, districtFileEntry{
+			AreaID:               d.AreaID,
+			AreaName:             d.AreaName,
+			ID:                   d.ID,
+			SettlementName:       d.SettlementName,
+			SettlementNameHebrew: d.SettlementNameHebrew,
+			SafetyBufferSeconds:  d.SafetyBufferSeconds,
+			Value:                d.Value,
+		})
+	}
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(entries); err != nil {
+		return err
+	}
+	return os.WriteFile(path, bytes.TrimSuffix(buf.Bytes(), []byte("\n")), 0o644)
+}
+
+// UpdateFiles updates the districts.*.json files in dir from upstream, leaving them untouched when nothing changed.
+func UpdateFiles(dir string, upstream map[config.Language][]UpstreamCity) ([]UpdateReport, error) {
+	var reports []UpdateReport
+	for _, lang := range config.Languages {
+		cities, ok := upstream[lang]
+		if !ok {
+			continue
+		}
+		path := filepath.Join(dir, fmt.Sprintf("districts.%s.json", lang))
+		existing, err := ReadDistrictFile(path)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+		updated, report := Update(lang, existing, cities, upstream["he"])
+		reports = append(reports, report)
+		if len(report.Added)+len(report.Renamed)+len(report.MigunTime) == 0 {
+			// removals alone keep the file as it is
+			continue
+		}
+		if err := WriteDistrictFile(path, updated); err != nil {
+			return nil, err
+		}
+	}
+	return reports, nil
+}
+
+// WriteReport describes the updates in markdown, for a pull request refreshing the files.
+func WriteReport(w io.Writer, reports []UpdateReport) error {
+	var b strings.Builder
+	for _, r := range reports {
+		fmt.Fprintf(&b, "## districts.%s.json\n\n", r.Language)
+		if r.Empty() {
+			b.WriteString("No changes.\n\n")
+			continue
+		}
+		for _, d := range r.Added {
+			fmt.Fprintf(&b, "- added %s %s (%s, %ds)\n", d.ID, d.SettlementName, d.AreaName, d.SafetyBufferSeconds)
+		}
+		for _, rename := range r.Renamed {
+			fmt.Fprintf(&b, "- renamed %s %s -> %s\n", rename.ID, strings.Join(rename.From, " / "), rename.To)
+		}
+		for _, d := range r.Removed {
+			fmt.Fprintf(&b, "- removed upstream, kept %s %s\n", d.ID, d.SettlementName)
+		}
+		for _, change := range r.MigunTime {
+			fmt.Fprintf(&b, "- migun_time of %s %s %ds -> %ds\n", change.ID, change.Label, change.From, change.To)
+		}
+		b.WriteString("\n")
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// GeometryReport tells how a districts.geojson covers the Hebrew districts.
+type GeometryReport struct {
+	Shapes int
+	// Unknown lists shapes of ids no district has, they are kept for districts loaded at runtime
+	Unknown []ID
+	// Missing lists districts without a shape, maps leave them out
+	Missing []ID
+}
+
+// UpdateGeometry checks the districts.geojson in source, a URL or a directory, and writes it to dir to be embedded.
+func UpdateGeometry(ctx context.Context, client *http.Client, source string, dir string) (GeometryReport, error) {
+	content, err := readSource(ctx, client, source, GeometryFile)
+	if err != nil {
+		return GeometryReport{}, fmt.Errorf("reading %s: %w", GeometryFile, err)
+	}
+	content = bytes.TrimPrefix(content, []byte("\ufeff"))
+	shapes, err := parseGeometry(content)
+	if err != nil {
+		return GeometryReport{}, fmt.Errorf("parsing %s: %w", GeometryFile, err)
+	}
+	hebrew, err := ReadDistrictFile(filepath.Join(dir, "districts.he.json"))
+	if err != nil {
+		return GeometryReport{}, err
+	}
+	report := GeometryReport{Shapes: len(shapes)}
+	known := make(map[ID]bool, len(hebrew))
+	for _, d := range hebrew {
+		known[d.ID] = true
+		if shapes[d.ID] == nil && !slices.Contains(report.Missing, d.ID) {
+			report.Missing = append(report.Missing, d.ID)
+		}
+	}
+	for id := range shapes {
+		if !known[id] {
+			report.Unknown = append(report.Unknown, id)
+		}
+	}
+	slices.Sort(report.Unknown)
+	slices.Sort(report.Missing)
+	return report, os.WriteFile(filepath.Join(dir, GeometryFile), content, 0o644)
+}
+
+// WriteGeometryReport describes a geometry update in markdown, following WriteReport.
+func WriteGeometryReport(w io.Writer, report GeometryReport) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "## %s\n\n- %d shapes\n", GeometryFile, report.Shapes)
+	if len(report.Unknown) > 0 {
+		fmt.Fprintf(&b, "- shapes of unknown districts: %s\n", joinIDs(report.Unknown))
+	}
+	if len(report.Missing) > 0 {
+		fmt.Fprintf(&b, "- %d districts without a shape: %s\n", len(report.Missing), joinIDs(report.Missing))
+	}
+	b.WriteString("\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func joinIDs(ids []ID) string {
+	names := make([]string, len(ids))
+	for i, id := range ids {
+		names[i] = string(id)
+	}
+	return strings.Join(names, ", ")
+}
@@ -0,0 +1,120 @@
+package district
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUpdateFiles(t *testing.T) {
+	dir := t.TempDir()
+	for _, lang := range []string{"he", "en", "ru", "ar"} {
+		content, err := os.ReadFile(filepath.Join("testdata", "existing", "districts."+lang+".json"))
+		require.NoError(t, err)
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "districts."+lang+".json"), content, 0o644))
+	}
+	upstream, err := FetchUpstream(context.Background(), http.DefaultClient, filepath.Join("testdata", "upstream"))
+	require.NoError(t, err)
+	assert.Len(t, upstream, 4, "only languages with an upstream list are fetched")
+
+	reports, err := UpdateFiles(dir, upstream)
+	require.NoError(t, err)
+	var report strings.Builder
+	require.NoError(t, WriteReport(&report, reports))
+	assert.Equal(t, `## districts.en.json
+
+- added 7000 New Settlement (HaAmakim, 60s)
+- renamed 1231 Shoshanat Ha'Amakim -> Shoshanat HaAmakim
+- migun_time of 999 Ein Harod 60s -> 45s
+
+## districts.he.json
+
+- added 1232 שושנת העמקים מערב (שרון, 90s)
+- added 7000 ישוב חדש (העמקים, 60s)
+- removed upstream, kept 5000 ישוב ישן
+- migun_time of 999 עין חרוד 60s -> 45s
+
+## districts.ru.json
+
+No changes.
+
+## districts.ar.json
+
+No changes.
+
+`, report.String())
+
+	he, err := ReadDistrictFile(filepath.Join(dir, "districts.he.json"))
+	require.NoError(t, err)
+	var ids []ID
+	for _, d := range he {
+		ids = append(ids, d.ID)
+	}
+	assert.Equal(t, []ID{"999", "1231", "1232", "5000", "7000"}, ids, "sorted by id, removed districts kept")
+	assert.Equal(t, District{SettlementName: "ישוב חדש", Value: "D4", ID: "7000", AreaID: 34, AreaName: "העמקים", SettlementNameHebrew: "ישוב חדש", SafetyBufferSeconds: 60}, he[4])
+
+	en, err := ReadDistrictFile(filepath.Join(dir, "districts.en.json"))
+	require.NoError(t, err)
+	var names []string
+	for _, d := range en {
+		if d.ID == "1231" {
+			names = append(names, d.SettlementName)
+		}
+	}
+	assert.Equal(t, []string{"Shoshanat Ha'Amakim", "Shoshanat HaAmakim"}, names, "the old name still finds a renamed district")
+
+	unchanged, err := os.ReadFile(filepath.Join(dir, "districts.ru.json"))
+	require.NoError(t, err)
+	original, err := os.ReadFile(filepath.Join("testdata", "existing", "districts.ru.json"))
+	require.NoError(t, err)
+	assert.Equal(t, string(original), string(unchanged))
+
+	again, err := UpdateFiles(dir, upstream)
+	require.NoError(t, err)
+	for _, r := range again {
+		assert.Empty(t, r.Added, r.Language)
+		assert.Empty(t, r.Renamed, r.Language)
+		assert.Empty(t, r.MigunTime, r.Language)
+	}
+}
+
+func TestWriteDistrictFile_MatchesEmbedded(t *testing.T) {
+	districts, err := ReadDistrictFile("districts.en.json")
+	require.NoError(t, err)
+	path := filepath.Join(t.TempDir(), "districts.en.json")
+	require.NoError(t, WriteDistrictFile(path, districts))
+	written, err := os.ReadFile(path)
+	require.NoError(t, err)
+	embedded, err := os.ReadFile("districts.en.json")
+	require.NoError(t, err)
+	assert.Equal(t, strings.Replace(string(embedded), `"value": null`, `"value": ""`, 1), string(written))
+}
+
+func TestUpdateGeometry(t *testing.T) {
+	dir := t.TempDir()
+	content, err := os.ReadFile(filepath.Join("testdata", "existing", "districts.he.json"))
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "districts.he.json"), content, 0o644))
+
+	report, err := UpdateGeometry(context.Background(), http.DefaultClient, filepath.Join("testdata", "geo"), dir)
+	require.NoError(t, err)
+	var written strings.Builder
+	require.NoError(t, WriteGeometryReport(&written, report))
+	assert.Equal(t, `## districts.geojson
+
+- 4 shapes
+- shapes of unknown districts: 1132, 593, 738
+- 2 districts without a shape: 1231, 5000
+
+`, written.String())
+	assert.FileExists(t, filepath.Join(dir, GeometryFile))
+
+	_, err = UpdateGeometry(context.Background(), http.DefaultClient, filepath.Join("testdata", "upstream"), dir)
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
@@ -3,6 +3,7 @@ package fetcher
 import (
 	"github.com/mattermost/mattermost/server/public/shared/mlog"
 	"github.com/phntom/goalert/internal/monitoring"
+	"github.com/phntom/goalert/internal/recording"
 	"io"
 	"net/http"
 	"time"
@@ -20,7 +21,8 @@ func CreateHTTPClient() *http.Client {
 	return client
 }
 
-func FetchSource(client *http.Client, url string, sourceName string, referrer string, monitor *monitoring.Monitoring) []byte {
+// FetchSource returns the body of a successful fetch, every non-empty response is also appended to recorder.
+func FetchSource(client *http.Client, url string, sourceName string, referrer string, monitor *monitoring.Monitoring, recorder *recording.Recorder) []byte {
 	req, err := http.NewRequest("GET", url, nil)
 	if err != nil {
 		panic(err)
@@ -61,7 +63,12 @@ func FetchSource(client *http.Client, url string, sourceName string, referrer st
 		return nil
 	}
 
+	//goland:noinspection GoUnhandledErrorResult
+	defer res.Body.Close()
+
 	if res.StatusCode != 200 {
+		body, _ := io.ReadAll(res.Body)
+		record(recorder, sourceName, res.StatusCode, body)
 		mlog.Warn("failed to fetch - wrong status code",
 			mlog.Any("status", res.StatusCode),
 			mlog.Any("res", res),
@@ -79,5 +86,17 @@ func FetchSource(client *http.Client, url string, sourceName string, referrer st
 		return nil
 	}
 	monitor.SuccessfulSourceFetches.WithLabelValues(sourceName).Inc()
+	record(recorder, sourceName, res.StatusCode, content)
 	return content
 }
+
+func record(recorder *recording.Recorder, sourceName string, status int, content []byte) {
+	err := recorder.Record(recording.Entry{
+		Source:  sourceName,
+		Status:  status,
+		Payload: string(content),
+	})
+	if err != nil {
+		mlog.Error("failed recording payload", mlog.Err(err), mlog.Any("source", sourceName))
+	}
+}
@@ -0,0 +1,182 @@
+package health
+
+import (
+	"sync"
+	"time"
+)
+
+type State string
+
+const (
+	Healthy  State = "healthy"
+	Degraded State = "degraded"
+	Down     State = "down"
+)
+
+const (
+	// window is the number of recent fetches a source is scored on
+	window = 20
+	// downAfter consecutive failures mark a source down, about two minutes with the backoff below
+	downAfter   = 10
+	slowLatency = time.Second
+	minBackoff  = 250 * time.Millisecond
+	maxBackoff  = 30 * time.Second
+)
+
+type result struct {
+	ok      bool
+	latency time.Duration
+}
+
+type source struct {
+	state    State
+	failures int
+	recent   []result
+}
+
+// Tracker scores every source on its recent fetches:
+// down after 10 consecutive failures, degraded while any of the last 20 fetches failed or they were
+// slow on average, healthy otherwise. OnChange is called on every transition, outside the lock.
+type Tracker struct {
+	OnChange func(name string, from State, to State)
+	mu       sync.Mutex
+	sources  map[string]*source
+}
+
+func NewTracker() *Tracker {
+	return &Tracker{sources: make(map[string]*source)}
+}
+
+// Register adds a source that counts towards AllDown, it starts out healthy.
+func (t *Tracker) Register(name string) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if _, ok := t.sources[name]; !ok {
+		t.sources[name] = &source{state: Healthy}
+	}
+}
+
+// Report records the outcome of a single fetch and returns the resulting state.
+func (t *Tracker) Report(name string, ok bool, latency time.Duration) State {
+	if t == nil {
+		return Healthy
+	}
+	t.mu.Lock()
+	s := t.source(name)
+	s.recent = append(s.recent, result{ok: ok, latency: latency})
+	if len(s.recent) > window {
+		s.recent = s.recent[len(s.recent)-window:]
+	}
+	if ok {
+		s.failures = 0
+	} else {
+		s.failures++
+	}
+	return t.transition(name, s, s.score())
+}
+
+// SetDown marks a source down regardless of its recent fetches, e.g. when its connection was lost.
+func (t *Tracker) SetDown(name string) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	s := t.source(name)
+	s.failures = max(s.failures, downAfter)
+	t.transition(name, s, Down)
+}
+
+// source must be called with the lock held.
+func (t *Tracker) source(name string) *source {
+	s, ok := t.sources[name]
+	if !ok {
+		s = &source{state: Healthy}
+		t.sources[name] = s
+	}
+	return s
+}
+
+// transition must be called with the lock held and releases it.
+func (t *Tracker) transition(name string, s *source, state State) State {
+	from := s.state
+	s.state = state
+	onChange := t.OnChange
+	t.mu.Unlock()
+	if from != state && onChange != nil {
+		onChange(name, from, state)
+	}
+	return state
+}
+
+func (s *source) score() State {
+	if s.failures >= downAfter {
+		return Down
+	}
+	var latency time.Duration
+	succeeded := 0
+	for _, r := range s.recent {
+		if !r.ok {
+			return Degraded
+		}
+		latency += r.latency
+		succeeded++
+	}
+	if succeeded > 0 && latency/time.Duration(succeeded) > slowLatency {
+		return Degraded
+	}
+	return Healthy
+}
+
+// Backoff is how long a source should wait after a failed fetch, doubling with every consecutive failure.
+func (t *Tracker) Backoff(name string) time.Duration {
+	if t == nil {
+		return minBackoff
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	s, ok := t.sources[name]
+	if !ok || s.failures == 0 {
+		return minBackoff
+	}
+	return min(minBackoff<<min(s.failures-1, 16), maxBackoff)
+}
+
+func (t *Tracker) State(name string) State {
+	if t == nil {
+		return Healthy
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if s, ok := t.sources[name]; ok {
+		return s.state
+	}
+	return Healthy
+}
+
+// States returns the current state of every source.
+func (t *Tracker) States() map[string]State {
+	states := make(map[string]State)
+	if t == nil {
+		return states
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for name, s := range t.sources {
+		states[name] = s.state
+	}
+	return states
+}
+
+// AllDown reports whether there are sources and none of them works.
+func (t *Tracker) AllDown() bool {
+	states := t.States()
+	for _, state := range states {
+		if state != Down {
+			return false
+		}
+	}
+	return len(states) > 0
+}
@@ -0,0 +1,73 @@
+package health
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type transition struct {
+	name     string
+	from, to State
+}
+
+func TestTracker(t *testing.T) {
+	tracker := NewTracker()
+	var transitions []transition
+	tracker.OnChange = func(name string, from State, to State) {
+		transitions = append(transitions, transition{name, from, to})
+	}
+	tracker.Register("oref")
+	tracker.Register("ynet")
+
+	assert.Equal(t, Healthy, tracker.Report("oref", true, 100*time.Millisecond))
+	assert.Equal(t, Degraded, tracker.Report("oref", false, time.Second))
+	assert.Equal(t, 250*time.Millisecond, tracker.Backoff("oref"))
+	for i := 1; i < downAfter-1; i++ {
+		assert.Equal(t, Degraded, tracker.Report("oref", false, time.Second))
+	}
+	assert.Equal(t, Down, tracker.Report("oref", false, time.Second))
+	assert.Equal(t, maxBackoff, tracker.Backoff("oref"))
+	assert.False(t, tracker.AllDown())
+
+	// recovers gradually as failures leave the window
+	assert.Equal(t, Degraded, tracker.Report("oref", true, 100*time.Millisecond))
+	assert.Equal(t, minBackoff, tracker.Backoff("oref"))
+	for i := 1; i < window-1; i++ {
+		tracker.Report("oref", true, 100*time.Millisecond)
+	}
+	assert.Equal(t, Healthy, tracker.Report("oref", true, 100*time.Millisecond))
+
+	// slow responses degrade a source too
+	for i := 0; i < window; i++ {
+		tracker.Report("ynet", true, 2*time.Second)
+	}
+	assert.Equal(t, Degraded, tracker.State("ynet"))
+
+	assert.Equal(t, []transition{
+		{"oref", Healthy, Degraded},
+		{"oref", Degraded, Down},
+		{"oref", Down, Degraded},
+		{"oref", Degraded, Healthy},
+		{"ynet", Healthy, Degraded},
+	}, transitions)
+	assert.Equal(t, map[string]State{"oref": Healthy, "ynet": Degraded}, tracker.States())
+}
+
+func TestTracker_AllDown(t *testing.T) {
+	tracker := NewTracker()
+	assert.False(t, tracker.AllDown())
+	tracker.Register("oref")
+	tracker.Register("telegram")
+	tracker.SetDown("telegram")
+	assert.False(t, tracker.AllDown())
+	for i := 0; i < downAfter; i++ {
+		tracker.Report("oref", false, time.Second)
+	}
+	assert.True(t, tracker.AllDown())
+
+	var disabled *Tracker
+	assert.Equal(t, Healthy, disabled.Report("oref", false, 0))
+	assert.False(t, disabled.AllDown())
+}
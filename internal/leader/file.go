@@ -0,0 +1,105 @@
+package leader
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// staleLock is how old a lock file may get before it is considered left behind by a crashed replica
+const staleLock = 5 * time.Second
+
+// FileStore keeps the lease in a JSON file, for replicas sharing a filesystem and for testing.
+// Updates are serialized by an exclusive lock file next to it.
+type FileStore struct {
+	Path string
+}
+
+type fileRecord struct {
+	Record
+	Version int64 `json:"version"`
+}
+
+func (s *FileStore) Get(ctx context.Context) (*Record, error) {
+	stored, err := s.read()
+	if err != nil || stored == nil {
+		return nil, err
+	}
+	return &stored.Record, nil
+}
+
+func (s *FileStore) Update(ctx context.Context, old *Record, record Record) error {
+	unlock, err := s.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	stored, err := s.read()
+	if err != nil {
+		return err
+	}
+	switch {
+	case stored == nil && old != nil, stored != nil && old == nil:
+		return ErrConflict
+	case stored != nil && stored.version != old.version:
+		return ErrConflict
+	}
+	next := fileRecord{Record: record, Version: 1}
+	if stored != nil {
+		next.Version = stored.Version + 1
+	}
+	data, err := json.Marshal(next)
+	if err != nil {
+		return err
+	}
+	tmp := s.Path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.Path)
+}
+
+func (s *FileStore) read() (*fileRecord, error) {
+	data, err := os.ReadFile(s.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var stored fileRecord
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return nil, err
+	}
+	stored.version = strconv.FormatInt(stored.Version, 10)
+	return &stored, nil
+}
+
+// lock creates the lock file, it returns ErrConflict while another replica holds it.
+func (s *FileStore) lock() (func(), error) {
+	path := s.Path + ".lock"
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+	if errors.Is(err, os.ErrExist) {
+		if info, statErr := os.Stat(path); statErr == nil && time.Since(info.ModTime()) > staleLock {
+			//goland:noinspection GoUnhandledErrorResult
+			os.Remove(path) //nolint:errcheck
+		}
+		return nil, ErrConflict
+	}
+	if err != nil {
+		return nil, err
+	}
+	//goland:noinspection GoUnhandledErrorResult
+	f.Close() //nolint:errcheck
+	return func() {
+		//goland:noinspection GoUnhandledErrorResult
+		os.Remove(path) //nolint:errcheck
+	}, nil
+}
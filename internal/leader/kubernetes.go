@@ -0,0 +1,217 @@
+package leader
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	serviceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount"
+	// microTimeFormat is the layout of acquireTime and renewTime in a Lease
+	microTimeFormat = "2006-01-02T15:04:05.000000Z07:00"
+)
+
+// KubernetesStore keeps the lease in a coordination.k8s.io/v1 Lease object,
+// the service account needs get, create and update on leases in Namespace.
+type KubernetesStore struct {
+	Client    *http.Client
+	URL       string
+	Namespace string
+	Name      string
+	// TokenPath is read on every request, service account tokens are rotated
+	TokenPath string
+}
+
+// NewKubernetesStore configures a store for the Lease name from the pod's service account,
+// namespace defaults to the namespace the pod runs in.
+func NewKubernetesStore(namespace string, name string) (*KubernetesStore, error) {
+	host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+	if host == "" || port == "" {
+		return nil, errors.New("not running in a kubernetes cluster")
+	}
+	if name == "" {
+		return nil, errors.New("missing lease name")
+	}
+	if namespace == "" {
+		data, err := os.ReadFile(serviceAccountDir + "/namespace")
+		if err != nil {
+			return nil, err
+		}
+		namespace = strings.TrimSpace(string(data))
+	}
+	ca, err := os.ReadFile(serviceAccountDir + "/ca.crt")
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(ca) {
+		return nil, errors.New("invalid service account ca.crt")
+	}
+	return &KubernetesStore{
+		Client: &http.Client{
+			Timeout:   time.Second,
+			Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool}},
+		},
+		URL:       "https://" + net.JoinHostPort(host, port),
+		Namespace: namespace,
+		Name:      name,
+		TokenPath: serviceAccountDir + "/token",
+	}, nil
+}
+
+type lease struct {
+	APIVersion string        `json:"apiVersion"`
+	Kind       string        `json:"kind"`
+	Metadata   leaseMetadata `json:"metadata"`
+	Spec       leaseSpec     `json:"spec"`
+}
+
+type leaseMetadata struct {
+	Name            string `json:"name"`
+	Namespace       string `json:"namespace"`
+	ResourceVersion string `json:"resourceVersion,omitempty"`
+}
+
+type leaseSpec struct {
+	HolderIdentity       string     `json:"holderIdentity,omitempty"`
+	LeaseDurationSeconds int        `json:"leaseDurationSeconds,omitempty"`
+	AcquireTime          *microTime `json:"acquireTime,omitempty"`
+	RenewTime            *microTime `json:"renewTime,omitempty"`
+	LeaseTransitions     int        `json:"leaseTransitions,omitempty"`
+}
+
+type microTime struct {
+	time.Time
+}
+
+func (t *microTime) MarshalJSON() ([]byte, error) {
+	return json.Marshal(t.UTC().Format(microTimeFormat))
+}
+
+func (t *microTime) UnmarshalJSON(data []byte) error {
+	var value string
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+	parsed, err := time.Parse(microTimeFormat, value)
+	if err != nil {
+		// older servers send seconds only
+		parsed, err = time.Parse(time.RFC3339, value)
+	}
+	t.Time = parsed
+	return err
+}
+
+func (s *KubernetesStore) Get(ctx context.Context) (*Record, error) {
+	var current lease
+	status, err := s.do(ctx, http.MethodGet, s.Name, nil, &current)
+	if status == http.StatusNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	record := Record{
+		Holder:      current.Spec.HolderIdentity,
+		Duration:    time.Duration(current.Spec.LeaseDurationSeconds) * time.Second,
+		Transitions: current.Spec.LeaseTransitions,
+		version:     current.Metadata.ResourceVersion,
+	}
+	if current.Spec.AcquireTime != nil {
+		record.Acquired = current.Spec.AcquireTime.Time
+	}
+	if current.Spec.RenewTime != nil {
+		record.Renewed = current.Spec.RenewTime.Time
+	}
+	return &record, nil
+}
+
+func (s *KubernetesStore) Update(ctx context.Context, old *Record, record Record) error {
+	next := lease{
+		APIVersion: "coordination.k8s.io/v1",
+		Kind:       "Lease",
+		Metadata: leaseMetadata{
+			Name:      s.Name,
+			Namespace: s.Namespace,
+		},
+		Spec: leaseSpec{
+			HolderIdentity: record.Holder,
+			// round up, a lease must not expire before its holder's own deadline
+			LeaseDurationSeconds: int((record.Duration + time.Second - 1) / time.Second),
+			AcquireTime:          &microTime{record.Acquired},
+			RenewTime:            &microTime{record.Renewed},
+			LeaseTransitions:     record.Transitions,
+		},
+	}
+	var status int
+	var err error
+	if old == nil {
+		status, err = s.do(ctx, http.MethodPost, "", next, nil)
+	} else {
+		next.Metadata.ResourceVersion = old.version
+		status, err = s.do(ctx, http.MethodPut, s.Name, next, nil)
+	}
+	if status == http.StatusConflict {
+		return ErrConflict
+	}
+	return err
+}
+
+// do calls the leases API, name is empty for the collection, and decodes a successful response into out.
+func (s *KubernetesStore) do(ctx context.Context, method string, name string, in any, out any) (int, error) {
+	url := fmt.Sprintf("%s/apis/coordination.k8s.io/v1/namespaces/%s/leases", s.URL, s.Namespace)
+	if name != "" {
+		url += "/" + name
+	}
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return 0, err
+		}
+		body = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Accept", "application/json")
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if s.TokenPath != "" {
+		token, err := os.ReadFile(s.TokenPath)
+		if err != nil {
+			return 0, err
+		}
+		req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+	}
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		message, _ := io.ReadAll(io.LimitReader(res.Body, 1024))
+		return res.StatusCode, fmt.Errorf("%s %s: %s: %s", method, url, res.Status, bytes.TrimSpace(message))
+	}
+	if out == nil {
+		return res.StatusCode, nil
+	}
+	return res.StatusCode, json.NewDecoder(res.Body).Decode(out)
+}
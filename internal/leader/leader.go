@@ -0,0 +1,167 @@
+package leader
+
+import (
+	"context"
+	"errors"
+	"github.com/mattermost/mattermost/server/public/shared/mlog"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// DefaultLeaseDuration is how long a standby waits for a silent leader before taking over
+	DefaultLeaseDuration = 2 * time.Second
+	DefaultRetryPeriod   = 500 * time.Millisecond
+)
+
+// ErrConflict is returned by Store.Update when the lease changed since it was read.
+var ErrConflict = errors.New("lease was updated concurrently")
+
+// Record is the state of a lease, modelled after the Kubernetes Lease spec.
+type Record struct {
+	Holder      string        `json:"holder"`
+	Acquired    time.Time     `json:"acquired"`
+	Renewed     time.Time     `json:"renewed"`
+	Duration    time.Duration `json:"duration"`
+	Transitions int           `json:"transitions"`
+	// version changes on every update, stores use it to detect concurrent updates
+	version string
+}
+
+// Store keeps a single lease shared by all replicas.
+// Get returns nil when there is no lease yet, Update replaces the lease read as old (nil to create it)
+// and returns ErrConflict when it was updated in the meantime.
+type Store interface {
+	Get(ctx context.Context) (*Record, error)
+	Update(ctx context.Context, old *Record, record Record) error
+}
+
+// Elector competes with the other replicas for the lease in Store.
+// The holder renews the lease every RetryPeriod, the others take it over once it was not renewed for
+// its duration, measured on their own clock so clock skew between replicas does not matter.
+// OnChange is called whenever this replica becomes leader or stops being one.
+type Elector struct {
+	Store         Store
+	Identity      string
+	LeaseDuration time.Duration
+	RetryPeriod   time.Duration
+	OnChange      func(leading bool)
+	mu            sync.Mutex
+	observed      Record
+	observedAt    time.Time
+	leading       atomic.Bool
+	renewedAt     atomic.Int64
+	released      atomic.Bool
+}
+
+// IsLeader reports whether this replica holds the lease and renewed it recently, a nil Elector always leads.
+func (e *Elector) IsLeader() bool {
+	if e == nil {
+		return true
+	}
+	return e.leading.Load() && time.Since(time.Unix(0, e.renewedAt.Load())) < e.leaseDuration()
+}
+
+// Run keeps acquiring or renewing the lease until ctx is done.
+func (e *Elector) Run(ctx context.Context) {
+	ticker := time.NewTicker(e.retryPeriod())
+	defer ticker.Stop()
+	for {
+		e.tryAcquireOrRenew(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Release gives up the lease for good so a standby takes over right away instead of waiting for it to expire.
+func (e *Elector) Release(ctx context.Context) {
+	if e == nil {
+		return
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.released.Store(true)
+	if !e.leading.Load() {
+		return
+	}
+	e.setLeading(false)
+	current, err := e.Store.Get(ctx)
+	if err != nil || current == nil || current.Holder != e.Identity {
+		return
+	}
+	record := *current
+	record.Holder = ""
+	if err := e.Store.Update(ctx, current, record); err != nil {
+		mlog.Error("failed releasing leader lease", mlog.Err(err))
+	}
+}
+
+func (e *Elector) tryAcquireOrRenew(ctx context.Context) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.released.Load() || ctx.Err() != nil {
+		return
+	}
+	now := time.Now()
+	current, err := e.Store.Get(ctx)
+	if err != nil {
+		mlog.Error("failed reading leader lease", mlog.Err(err))
+		e.setLeading(e.IsLeader())
+		return
+	}
+	record := Record{
+		Holder:   e.Identity,
+		Acquired: now,
+		Renewed:  now,
+		Duration: e.leaseDuration(),
+	}
+	if current != nil {
+		if current.version != e.observed.version {
+			e.observed = *current
+			e.observedAt = now
+		}
+		if current.Holder != "" && current.Holder != e.Identity && now.Before(e.observedAt.Add(current.Duration)) {
+			e.setLeading(false)
+			return
+		}
+		if current.Holder == e.Identity {
+			record.Acquired = current.Acquired
+			record.Transitions = current.Transitions
+		} else {
+			record.Transitions = current.Transitions + 1
+		}
+	}
+	if err := e.Store.Update(ctx, current, record); err != nil {
+		if !errors.Is(err, ErrConflict) {
+			mlog.Error("failed updating leader lease", mlog.Err(err))
+		}
+		e.setLeading(e.IsLeader())
+		return
+	}
+	e.renewedAt.Store(now.UnixNano())
+	e.setLeading(true)
+}
+
+func (e *Elector) setLeading(leading bool) {
+	if e.leading.Swap(leading) != leading && e.OnChange != nil {
+		e.OnChange(leading)
+	}
+}
+
+func (e *Elector) leaseDuration() time.Duration {
+	if e.LeaseDuration <= 0 {
+		return DefaultLeaseDuration
+	}
+	return e.LeaseDuration
+}
+
+func (e *Elector) retryPeriod() time.Duration {
+	if e.RetryPeriod <= 0 {
+		return DefaultRetryPeriod
+	}
+	return e.RetryPeriod
+}
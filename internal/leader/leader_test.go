@@ -0,0 +1,178 @@
+package leader
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newElector(store Store, identity string) *Elector {
+	return &Elector{
+		Store:         store,
+		Identity:      identity,
+		LeaseDuration: 200 * time.Millisecond,
+		RetryPeriod:   20 * time.Millisecond,
+	}
+}
+
+func TestElector_Failover(t *testing.T) {
+	store := &FileStore{Path: filepath.Join(t.TempDir(), "leader.json")}
+	first := newElector(store, "first")
+	second := newElector(store, "second")
+	var changes []bool
+	var changesMutex sync.Mutex
+	second.OnChange = func(leading bool) {
+		changesMutex.Lock()
+		defer changesMutex.Unlock()
+		changes = append(changes, leading)
+	}
+
+	firstCtx, stopFirst := context.WithCancel(context.Background())
+	defer stopFirst()
+	first.tryAcquireOrRenew(firstCtx)
+	require.True(t, first.IsLeader())
+	go first.Run(firstCtx)
+
+	secondCtx, stopSecond := context.WithCancel(context.Background())
+	defer stopSecond()
+	go second.Run(secondCtx)
+	time.Sleep(300 * time.Millisecond)
+	assert.True(t, first.IsLeader())
+	assert.False(t, second.IsLeader())
+
+	// a leader that stops renewing is replaced once its lease expires
+	stopFirst()
+	assert.Eventually(t, second.IsLeader, time.Second, 10*time.Millisecond)
+	assert.Eventually(t, func() bool { return !first.IsLeader() }, time.Second, 10*time.Millisecond)
+
+	record, err := store.Get(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "second", record.Holder)
+	assert.Equal(t, 1, record.Transitions)
+
+	// a released lease is taken over without waiting for it to expire
+	first = newElector(store, "first")
+	firstCtx, stopFirst = context.WithCancel(context.Background())
+	defer stopFirst()
+	go first.Run(firstCtx)
+	time.Sleep(50 * time.Millisecond)
+	assert.False(t, first.IsLeader())
+	second.Release(context.Background())
+	assert.False(t, second.IsLeader())
+	assert.Eventually(t, first.IsLeader, 100*time.Millisecond, 5*time.Millisecond)
+
+	changesMutex.Lock()
+	defer changesMutex.Unlock()
+	assert.Equal(t, []bool{true, false}, changes)
+}
+
+func TestElector_Nil(t *testing.T) {
+	var elector *Elector
+	assert.True(t, elector.IsLeader())
+	elector.Release(context.Background())
+}
+
+func TestFileStore_Conflict(t *testing.T) {
+	store := &FileStore{Path: filepath.Join(t.TempDir(), "leader.json")}
+	ctx := context.Background()
+	require.NoError(t, store.Update(ctx, nil, Record{Holder: "first"}))
+	assert.ErrorIs(t, store.Update(ctx, nil, Record{Holder: "second"}), ErrConflict)
+
+	current, err := store.Get(ctx)
+	require.NoError(t, err)
+	require.NoError(t, store.Update(ctx, current, Record{Holder: "first", Transitions: 1}))
+	assert.ErrorIs(t, store.Update(ctx, current, Record{Holder: "second"}), ErrConflict)
+}
+
+// fakeLeases serves a single Lease the way the Kubernetes API server does.
+type fakeLeases struct {
+	mu      sync.Mutex
+	lease   *lease
+	version int
+}
+
+func (f *fakeLeases) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	const collection = "/apis/coordination.k8s.io/v1/namespaces/alerts/leases"
+	if r.Header.Get("Authorization") != "Bearer secret" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	var in lease
+	if r.Body != nil {
+		//goland:noinspection GoUnhandledErrorResult
+		json.NewDecoder(r.Body).Decode(&in) //nolint:errcheck
+	}
+	switch {
+	case r.Method == http.MethodGet && r.URL.Path == collection+"/goalert":
+		if f.lease == nil {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+	case r.Method == http.MethodPost && r.URL.Path == collection:
+		if f.lease != nil {
+			w.WriteHeader(http.StatusConflict)
+			return
+		}
+		f.store(&in)
+	case r.Method == http.MethodPut && r.URL.Path == collection+"/goalert":
+		if f.lease == nil || in.Metadata.ResourceVersion != f.lease.Metadata.ResourceVersion {
+			w.WriteHeader(http.StatusConflict)
+			return
+		}
+		f.store(&in)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	//goland:noinspection GoUnhandledErrorResult
+	json.NewEncoder(w).Encode(f.lease) //nolint:errcheck
+}
+
+func (f *fakeLeases) store(in *lease) {
+	f.version++
+	in.Metadata.ResourceVersion = strconv.Itoa(f.version)
+	f.lease = in
+}
+
+func TestKubernetesStore(t *testing.T) {
+	fake := &fakeLeases{}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+	token := filepath.Join(t.TempDir(), "token")
+	require.NoError(t, os.WriteFile(token, []byte("secret\n"), 0o600))
+	store := &KubernetesStore{
+		URL:       server.URL,
+		Namespace: "alerts",
+		Name:      "goalert",
+		TokenPath: token,
+	}
+	ctx := context.Background()
+
+	current, err := store.Get(ctx)
+	require.NoError(t, err)
+	assert.Nil(t, current)
+
+	now := time.Date(2025, 6, 13, 3, 0, 0, 123456000, time.UTC)
+	require.NoError(t, store.Update(ctx, nil, Record{Holder: "goalert-0", Acquired: now, Renewed: now, Duration: 1500 * time.Millisecond}))
+	assert.ErrorIs(t, store.Update(ctx, nil, Record{Holder: "goalert-1"}), ErrConflict)
+
+	current, err = store.Get(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, Record{Holder: "goalert-0", Acquired: now, Renewed: now, Duration: 2 * time.Second, version: "1"}, *current)
+
+	require.NoError(t, store.Update(ctx, current, Record{Holder: "goalert-1", Transitions: 1}))
+	assert.ErrorIs(t, store.Update(ctx, current, Record{Holder: "goalert-0"}), ErrConflict)
+	assert.Equal(t, "goalert-1", fake.lease.Spec.HolderIdentity)
+}
@@ -8,12 +8,20 @@ import (
 	"sync"
 )
 
-var registerMetricsOnce sync.Once
+var (
+	registerMetricsOnce sync.Once
+	// registered holds the metrics created by the first Setup, every later Monitoring shares them
+	registered Monitoring
+)
 
 type Monitoring struct {
 	SuccessfulSourceFetches   *prometheus.CounterVec
 	FailedSourceFetches       *prometheus.CounterVec
 	HttpResponseTimeHistogram *prometheus.HistogramVec
+	SuccessfulDeliveries      *prometheus.CounterVec
+	FailedDeliveries          *prometheus.CounterVec
+	SourceHealth              *prometheus.GaugeVec
+	Leader                    prometheus.Gauge
 	SuccessfulPosts           prometheus.Counter
 	SuccessfulPatches         prometheus.Counter
 	FailedPatches             prometheus.Counter
@@ -21,10 +29,13 @@ type Monitoring struct {
 	RegionsHistogram          prometheus.Histogram
 	TimeOfDayHistogram        prometheus.Histogram
 	DayOfWeekHistogram        prometheus.Histogram
+	CityMatches               *prometheus.CounterVec
+	LowConfidenceCityMatches  *prometheus.CounterVec
 }
 
 func (m *Monitoring) Setup() {
 	registerMetricsOnce.Do(func() {
+		m := &registered
 		m.SuccessfulSourceFetches = promauto.NewCounterVec(
 			prometheus.CounterOpts{
 				Name: "successful_source_fetches",
@@ -47,6 +58,33 @@ func (m *Monitoring) Setup() {
 			},
 			[]string{"source"},
 		)
+		m.SuccessfulDeliveries = promauto.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "successful_sink_deliveries",
+				Help: "Number of alerts successfully delivered to an output sink.",
+			},
+			[]string{"sink"},
+		)
+		m.FailedDeliveries = promauto.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "failed_sink_deliveries",
+				Help: "Number of alerts an output sink gave up delivering.",
+			},
+			[]string{"sink"},
+		)
+		m.SourceHealth = promauto.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "source_health",
+				Help: "Health of an alert source, 2 healthy, 1 degraded, 0 down.",
+			},
+			[]string{"source"},
+		)
+		m.Leader = promauto.NewGauge(
+			prometheus.GaugeOpts{
+				Name: "leader",
+				Help: "1 while this replica delivers alerts, 0 while it stands by.",
+			},
+		)
 		m.SuccessfulPosts = promauto.NewCounter(
 			prometheus.CounterOpts{
 				Name: "successful_posts",
@@ -93,9 +131,24 @@ func (m *Monitoring) Setup() {
 				Buckets: prometheus.LinearBuckets(1, 1, 7), // 7 days
 			},
 		)
+		m.CityMatches = promauto.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "city_matches",
+				Help: "Number of alert city names matched to a district, by how they were matched.",
+			},
+			[]string{"source", "method"},
+		)
+		m.LowConfidenceCityMatches = promauto.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "low_confidence_city_matches",
+				Help: "Number of alert city names posted with a district matched approximately.",
+			},
+			[]string{"source"},
+		)
 
 		http.Handle("/metrics", promhttp.Handler())
 		//goland:noinspection GoUnhandledErrorResult
 		go http.ListenAndServe(":3000", nil) //nolint:errcheck
 	})
+	*m = registered
 }
@@ -0,0 +1,126 @@
+package recording
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Recorder appends entries to a JSON lines archive at Path. Once the file grows past MaxSize it is
+// rotated to Path.1, the previous Path.1 to Path.2 and so on, keeping at most MaxBackups old files.
+// A nil Recorder records nothing, so sources can call it unconditionally.
+type Recorder struct {
+	Path       string
+	MaxSize    int64
+	MaxBackups int
+	mu         sync.Mutex
+	file       *os.File
+	size       int64
+}
+
+func NewRecorder(path string, maxSize int64, maxBackups int) (*Recorder, error) {
+	r := &Recorder{
+		Path:       path,
+		MaxSize:    maxSize,
+		MaxBackups: maxBackups,
+	}
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *Recorder) open() error {
+	file, err := os.OpenFile(r.Path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		//goland:noinspection GoUnhandledErrorResult
+		file.Close()
+		return err
+	}
+	r.file = file
+	r.size = info.Size()
+	return nil
+}
+
+// Record appends the entry unless its payload is blank, Time defaults to now.
+func (r *Recorder) Record(entry Entry) error {
+	if r == nil || strings.TrimSpace(strings.TrimPrefix(entry.Payload, "\ufeff")) == "" {
+		return nil
+	}
+	if entry.Time.IsZero() {
+		entry.Time = time.Now()
+	}
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.file == nil {
+		return fmt.Errorf("recorder %s is closed", r.Path)
+	}
+	var rotateErr error
+	if r.size > 0 && r.size+int64(len(line)) > r.MaxSize {
+		rotateErr = r.rotate()
+		if r.file == nil {
+			return rotateErr
+		}
+	}
+	n, err := r.file.Write(line)
+	r.size += int64(n)
+	return errors.Join(rotateErr, err)
+}
+
+// rotate moves the current file to the backups and starts a new one. When that fails it reopens the current file,
+// which keeps growing until a later rotation succeeds.
+func (r *Recorder) rotate() error {
+	err := r.file.Close()
+	r.file = nil
+	if err == nil {
+		err = r.shift()
+	}
+	if openErr := r.open(); openErr != nil {
+		return errors.Join(err, openErr)
+	}
+	return err
+}
+
+func (r *Recorder) shift() error {
+	if r.MaxBackups > 0 {
+		for i := r.MaxBackups - 1; i > 0; i-- {
+			err := os.Rename(fmt.Sprintf("%s.%d", r.Path, i), fmt.Sprintf("%s.%d", r.Path, i+1))
+			if err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+		if err := os.Rename(r.Path, r.Path+".1"); err != nil {
+			return err
+		}
+	} else if err := os.Remove(r.Path); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (r *Recorder) Close() error {
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.file == nil {
+		return nil
+	}
+	err := r.file.Close()
+	r.file = nil
+	return err
+}
@@ -0,0 +1,84 @@
+package recording
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func readAll(t *testing.T, path string) []Entry {
+	t.Helper()
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	//goland:noinspection GoUnhandledErrorResult
+	defer f.Close()
+	var entries []Entry
+	reader := NewReader(f)
+	for {
+		entry, err := reader.Next()
+		if err == io.EOF {
+			return entries
+		}
+		require.NoError(t, err)
+		entries = append(entries, entry)
+	}
+}
+
+func TestRecorder(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "payloads.jsonl")
+	recorder, err := NewRecorder(path, 400, 2)
+	require.NoError(t, err)
+
+	fetched := time.Date(2024, 4, 14, 1, 42, 7, 0, time.UTC)
+	require.NoError(t, recorder.Record(Entry{Time: fetched, Source: "oref", Status: 200, Payload: "\ufeff\r\n"}))
+	payload := strings.Repeat("x", 100)
+	for i := 0; i < 7; i++ {
+		require.NoError(t, recorder.Record(Entry{Time: fetched, Source: "oref", Status: 200, Payload: payload}))
+	}
+	require.NoError(t, recorder.Close())
+
+	current := readAll(t, path)
+	require.Len(t, current, 1)
+	assert.Equal(t, Entry{Time: fetched, Source: "oref", Status: 200, Payload: payload}, current[0])
+	assert.Len(t, readAll(t, path+".1"), 2)
+	assert.Len(t, readAll(t, path+".2"), 2)
+	assert.NoFileExists(t, path+".3")
+
+	// reopening continues the existing file
+	recorder, err = NewRecorder(path, 400, 2)
+	require.NoError(t, err)
+	require.NoError(t, recorder.Record(Entry{Source: "telegram", Channel: 1441886157, Payload: "text"}))
+	require.NoError(t, recorder.Close())
+	current = readAll(t, path)
+	require.Len(t, current, 2)
+	assert.False(t, current[1].Time.IsZero())
+
+	var disabled *Recorder
+	assert.NoError(t, disabled.Record(Entry{Source: "ynet", Payload: "ignored"}))
+}
+
+func TestRecorder_FailedRotation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "payloads.jsonl")
+	recorder, err := NewRecorder(path, 200, 1)
+	require.NoError(t, err)
+	//goland:noinspection GoUnhandledErrorResult
+	defer recorder.Close()
+
+	// a directory in the way of the backup makes the rename fail
+	require.NoError(t, os.MkdirAll(filepath.Join(path+".1", "blocked"), 0o755))
+	payload := strings.Repeat("x", 100)
+	require.NoError(t, recorder.Record(Entry{Source: "oref", Payload: payload}))
+	assert.Error(t, recorder.Record(Entry{Source: "oref", Payload: payload}))
+	assert.Len(t, readAll(t, path), 2, "the entry is kept in the current file")
+
+	require.NoError(t, os.RemoveAll(path+".1"))
+	require.NoError(t, recorder.Record(Entry{Source: "oref", Payload: payload}))
+	assert.Len(t, readAll(t, path), 1)
+	assert.Len(t, readAll(t, path+".1"), 2)
+}
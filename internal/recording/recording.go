@@ -0,0 +1,55 @@
+package recording
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// maxLineSize bounds a single recorded entry, a full barrage from oref fits well within it.
+const maxLineSize = 4 * 1024 * 1024
+
+// Entry is one raw payload as a source received it, recordings are JSON lines of entries.
+// Source is "ynet", "oref" or "telegram", Status is the HTTP status of fetched payloads and
+// Channel the telegram channel a message text was posted to.
+type Entry struct {
+	Time    time.Time `json:"time"`
+	Source  string    `json:"source"`
+	Status  int       `json:"status,omitempty"`
+	Channel int64     `json:"channel,omitempty"`
+	Payload string    `json:"payload"`
+}
+
+type Reader struct {
+	scanner *bufio.Scanner
+	line    int
+}
+
+func NewReader(r io.Reader) *Reader {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxLineSize)
+	return &Reader{scanner: scanner}
+}
+
+// Next returns the following entry, io.EOF once the recording is exhausted.
+func (r *Reader) Next() (Entry, error) {
+	for r.scanner.Scan() {
+		r.line++
+		line := strings.TrimSpace(r.scanner.Text())
+		if line == "" {
+			continue
+		}
+		var entry Entry
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			return Entry{}, fmt.Errorf("line %d: %w", r.line, err)
+		}
+		return entry, nil
+	}
+	if err := r.scanner.Err(); err != nil {
+		return Entry{}, fmt.Errorf("line %d: %w", r.line+1, err)
+	}
+	return Entry{}, io.EOF
+}
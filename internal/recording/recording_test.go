@@ -0,0 +1,36 @@
+package recording
+
+import (
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReader(t *testing.T) {
+	reader := NewReader(strings.NewReader(`{"time":"2024-04-14T01:42:07.25+03:00","source":"oref","payload":"{\"id\": \"1\"}"}
+
+{"time":"2024-04-14T01:42:08+03:00","source":"telegram","payload":"ירי רקטות וטילים"}
+not json
+`))
+
+	entry, err := reader.Next()
+	require.NoError(t, err)
+	assert.Equal(t, "oref", entry.Source)
+	assert.Equal(t, `{"id": "1"}`, entry.Payload)
+	assert.True(t, entry.Time.Equal(time.Date(2024, 4, 13, 22, 42, 7, 250000000, time.UTC)))
+
+	entry, err = reader.Next()
+	require.NoError(t, err)
+	assert.Equal(t, "telegram", entry.Source)
+	assert.Equal(t, "ירי רקטות וטילים", entry.Payload)
+
+	_, err = reader.Next()
+	assert.ErrorContains(t, err, "line 4")
+
+	_, err = reader.Next()
+	assert.ErrorIs(t, err, io.EOF)
+}
@@ -0,0 +1,324 @@
+package sinks
+
+import (
+	"encoding/xml"
+	"fmt"
+	"github.com/phntom/goalert/internal/bot"
+	"github.com/phntom/goalert/internal/config"
+	"github.com/phntom/goalert/internal/district"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	capNamespace  = "urn:oasis:names:tc:emergency:cap:1.2"
+	atomNamespace = "http://www.w3.org/2005/Atom"
+	capTimeFormat = "2006-01-02T15:04:05-07:00"
+	// capActiveDuration is how long an alert stays in the active feed, matching the 10 minutes in shelter.
+	capActiveDuration = 10 * time.Minute
+	capRecentAlerts   = 200
+)
+
+// capCategories maps alert categories to CAP categories and the response expected from the public.
+var capCategories = map[string]struct {
+	category     string
+	responseType string
+}{
+	"rockets":      {"Security", "Shelter"},
+	"uav":          {"Security", "Shelter"},
+	"infiltration": {"Security", "Shelter"},
+	"earthquake":   {"Geo", "Shelter"},
+	"tsunami":      {"Geo", "Evacuate"},
+	"radiological": {"CBRNE", "Shelter"},
+	"biohazard":    {"CBRNE", "Shelter"},
+}
+
+type CAPAlert struct {
+	XMLName    xml.Name  `xml:"alert"`
+	Xmlns      string    `xml:"xmlns,attr"`
+	Identifier string    `xml:"identifier"`
+	Sender     string    `xml:"sender"`
+	Sent       string    `xml:"sent"`
+	Status     string    `xml:"status"`
+	MsgType    string    `xml:"msgType"`
+	Scope      string    `xml:"scope"`
+	References string    `xml:"references,omitempty"`
+	Info       []CAPInfo `xml:"info"`
+}
+
+type CAPInfo struct {
+	Language     string         `xml:"language"`
+	Category     string         `xml:"category"`
+	Event        string         `xml:"event"`
+	ResponseType string         `xml:"responseType"`
+	Urgency      string         `xml:"urgency"`
+	Severity     string         `xml:"severity"`
+	Certainty    string         `xml:"certainty"`
+	EventCode    []CAPValuePair `xml:"eventCode"`
+	Effective    string         `xml:"effective"`
+	Expires      string         `xml:"expires"`
+	SenderName   string         `xml:"senderName"`
+	Headline     string         `xml:"headline"`
+	Instruction  string         `xml:"instruction"`
+	Area         []CAPArea      `xml:"area"`
+}
+
+type CAPValuePair struct {
+	ValueName string `xml:"valueName"`
+	Value     string `xml:"value"`
+}
+
+type CAPArea struct {
+	AreaDesc string         `xml:"areaDesc"`
+	Geocode  []CAPValuePair `xml:"geocode"`
+}
+
+// capEntry is the latest CAP version of one alert, first keeps the original for references.
+type capEntry struct {
+	first   *CAPAlert
+	latest  *CAPAlert
+	updates int
+	expires time.Time
+}
+
+// CAPFeed publishes alerts as CAP 1.2 documents and serves them as ATOM indexes:
+//
+//	GET /cap/active             alerts issued in the last 10 minutes
+//	GET /cap/recent             the latest 200 alerts
+//	GET /cap/alerts/{id}.xml    a single CAP document
+type CAPFeed struct {
+	Sender  string
+	mu      sync.RWMutex
+	entries map[string]*capEntry
+	recent  []string
+}
+
+func NewCAPFeed(sender string) *CAPFeed {
+	return &CAPFeed{
+		Sender:  sender,
+		entries: make(map[string]*capEntry),
+	}
+}
+
+func (f *CAPFeed) Name() string {
+	return "cap"
+}
+
+// Local keeps the feed current on standby replicas, readers see the same feed after a takeover.
+func (f *CAPFeed) Local() bool {
+	return true
+}
+
+func (f *CAPFeed) Create(m *bot.Message) error {
+	f.publish(m, "Alert")
+	return nil
+}
+
+func (f *CAPFeed) Update(m *bot.Message) error {
+	f.publish(m, "Update")
+	return nil
+}
+
+func (f *CAPFeed) React(m *bot.Message, emoji string) error {
+	return nil
+}
+
+func (f *CAPFeed) Retract(m *bot.Message) error {
+	f.publish(m, "Cancel")
+	return nil
+}
+
+func (f *CAPFeed) publish(m *bot.Message, msgType string) {
+	now := time.Now()
+	alert := NewCAPAlert(m, f.Sender, msgType, now)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	entry, ok := f.entries[m.ID]
+	if !ok {
+		alert.MsgType = "Alert"
+		entry = &capEntry{first: alert, expires: now.Add(capActiveDuration)}
+		f.entries[m.ID] = entry
+		f.recent = append(f.recent, m.ID)
+		if len(f.recent) > capRecentAlerts {
+			delete(f.entries, f.recent[0])
+			f.recent = f.recent[1:]
+		}
+	} else {
+		entry.updates++
+		alert.Identifier = fmt.Sprintf("%s.%d", alert.Identifier, entry.updates)
+		alert.References = fmt.Sprintf("%s,%s,%s", entry.first.Sender, entry.first.Identifier, entry.first.Sent)
+		if msgType == "Cancel" {
+			entry.expires = now
+		}
+	}
+	entry.latest = alert
+}
+
+// capTime formats t for CAP, which writes UTC as -00:00 where Go writes +00:00.
+func capTime(t time.Time) string {
+	if _, offset := t.Zone(); offset == 0 {
+		return t.Format("2006-01-02T15:04:05") + "-00:00"
+	}
+	return t.Format(capTimeFormat)
+}
+
+// NewCAPAlert converts a message to a CAP alert with an info block per language.
+func NewCAPAlert(m *bot.Message, sender string, msgType string, sent time.Time) *CAPAlert {
+	category := capCategories[m.Category]
+	if category.category == "" {
+		category.category = "Other"
+		category.responseType = "Monitor"
+	}
+	if m.Instructions == "uav_event_over" {
+		category.responseType = "AllClear"
+	}
+	urgency := "Expected"
+	if m.SafetySeconds <= 90 {
+		urgency = "Immediate"
+	}
+	severity := "Extreme"
+	if m.Category == "" {
+		severity = "Minor"
+	}
+	districts := district.GetDistricts()
+	alert := &CAPAlert{
+		Xmlns:      capNamespace,
+		Identifier: "goalert-" + m.ID,
+		Sender:     sender,
+		Sent:       capTime(sent),
+		Status:     "Actual",
+		MsgType:    msgType,
+		Scope:      "Public",
+	}
+	for _, lang := range config.Languages {
+		info := CAPInfo{
+			Language:     string(lang),
+			Category:     category.category,
+			Event:        bot.RenderTitle(m, lang),
+			ResponseType: category.responseType,
+			Urgency:      urgency,
+			Severity:     severity,
+			Certainty:    "Observed",
+			EventCode:    []CAPValuePair{{ValueName: "goalert", Value: m.Category}},
+			Effective:    capTime(sent),
+			Expires:      capTime(sent.Add(capActiveDuration)),
+			SenderName:   "Pikud HaOref",
+			Headline:     bot.RenderTitle(m, lang),
+			Instruction:  bot.RenderInstructions(m, lang),
+		}
+		if info.Event == "" {
+			info.Event = info.Instruction
+			info.Headline = info.Instruction
+		}
+		for _, id := range m.Cities {
+			info.Area = append(info.Area, CAPArea{
+				AreaDesc: districts[lang][id].SettlementName,
+				Geocode:  []CAPValuePair{{ValueName: "oref-district", Value: string(id)}},
+			})
+		}
+		for _, name := range m.Unrecognized {
+			// no district to geocode, the area is only described by the name the source used
+			info.Area = append(info.Area, CAPArea{AreaDesc: name})
+		}
+		alert.Info = append(alert.Info, info)
+	}
+	return alert
+}
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"feed"`
+	Xmlns   string      `xml:"xmlns,attr"`
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Author  string      `xml:"author>name"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomEntry struct {
+	ID      string   `xml:"id"`
+	Title   string   `xml:"title"`
+	Updated string   `xml:"updated"`
+	Summary string   `xml:"summary"`
+	Link    atomLink `xml:"link"`
+}
+
+type atomLink struct {
+	Rel  string `xml:"rel,attr"`
+	Type string `xml:"type,attr"`
+	Href string `xml:"href,attr"`
+}
+
+func (f *CAPFeed) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimPrefix(r.URL.Path, "/cap/")
+	switch {
+	case path == "active":
+		f.serveIndex(w, "active", true)
+	case path == "recent":
+		f.serveIndex(w, "recent", false)
+	case strings.HasPrefix(path, "alerts/") && strings.HasSuffix(path, ".xml"):
+		f.mu.RLock()
+		entry, ok := f.entries[strings.TrimSuffix(strings.TrimPrefix(path, "alerts/"), ".xml")]
+		var alert *CAPAlert
+		if ok {
+			alert = entry.latest
+		}
+		f.mu.RUnlock()
+		if alert == nil {
+			http.NotFound(w, r)
+			return
+		}
+		writeXML(w, "application/cap+xml", alert)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (f *CAPFeed) serveIndex(w http.ResponseWriter, name string, activeOnly bool) {
+	now := time.Now()
+	feed := atomFeed{
+		Xmlns:   atomNamespace,
+		ID:      "urn:goalert:cap:" + name,
+		Title:   "goalert " + name + " alerts",
+		Updated: now.Format(time.RFC3339),
+		Author:  f.Sender,
+	}
+	f.mu.RLock()
+	for _, id := range slices.Backward(f.recent) {
+		entry := f.entries[id]
+		if activeOnly && now.After(entry.expires) {
+			continue
+		}
+		info := entry.latest.Info[0]
+		var areas []string
+		for _, area := range info.Area {
+			areas = append(areas, area.AreaDesc)
+		}
+		feed.Entries = append(feed.Entries, atomEntry{
+			ID:      entry.first.Identifier,
+			Title:   info.Headline,
+			Updated: entry.latest.Sent,
+			Summary: strings.Join(areas, ", "),
+			Link: atomLink{
+				Rel:  "alternate",
+				Type: "application/cap+xml",
+				Href: "alerts/" + id + ".xml",
+			},
+		})
+	}
+	f.mu.RUnlock()
+	writeXML(w, "application/atom+xml", feed)
+}
+
+func writeXML(w http.ResponseWriter, contentType string, v any) {
+	w.Header().Set("Content-Type", contentType+"; charset=utf-8")
+	//goland:noinspection GoUnhandledErrorResult
+	w.Write([]byte(xml.Header)) //nolint:errcheck
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	//goland:noinspection GoUnhandledErrorResult
+	enc.Encode(v) //nolint:errcheck
+}
@@ -0,0 +1,89 @@
+package sinks
+
+import (
+	"encoding/xml"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/phntom/goalert/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCAPFeed(t *testing.T) {
+	feed := NewCAPFeed("goalert@example.com")
+	server := httptest.NewServer(feed)
+	defer server.Close()
+
+	msg := testMessage()
+	require.NoError(t, feed.Create(msg))
+	msg.RocketIDs["a1b2c3d4"] = true
+	require.NoError(t, feed.Update(msg))
+
+	get := func(path string) (int, []byte) {
+		res, err := http.Get(server.URL + path)
+		require.NoError(t, err)
+		//goland:noinspection GoUnhandledErrorResult
+		defer res.Body.Close()
+		body, err := io.ReadAll(res.Body)
+		require.NoError(t, err)
+		return res.StatusCode, body
+	}
+
+	status, body := get("/cap/alerts/" + msg.ID + ".xml")
+	require.Equal(t, http.StatusOK, status)
+	var alert CAPAlert
+	require.NoError(t, xml.Unmarshal(body, &alert))
+	assert.Equal(t, capNamespace, alert.Xmlns)
+	assert.Equal(t, "Update", alert.MsgType)
+	assert.Equal(t, "goalert-"+msg.ID+".1", alert.Identifier)
+	assert.Contains(t, alert.References, "goalert@example.com,goalert-"+msg.ID+",")
+	require.Len(t, alert.Info, len(config.Languages))
+	he := alert.Info[1]
+	assert.Equal(t, "he", he.Language)
+	assert.Equal(t, "Security", he.Category)
+	assert.Equal(t, "Shelter", he.ResponseType)
+	assert.Equal(t, "Immediate", he.Urgency)
+	assert.Equal(t, "ירי רקטות וטילים (2)", he.Event)
+	assert.Equal(t, "תוך 90 שניות היכנסו למרחב המוגן", he.Instruction)
+	require.Len(t, he.Area, 1)
+	assert.Equal(t, "עין חרוד", he.Area[0].AreaDesc)
+	assert.Equal(t, "999", he.Area[0].Geocode[0].Value)
+
+	var index atomFeed
+	status, body = get("/cap/active")
+	require.Equal(t, http.StatusOK, status)
+	require.NoError(t, xml.Unmarshal(body, &index))
+	require.Len(t, index.Entries, 1)
+	assert.Equal(t, "goalert-"+msg.ID, index.Entries[0].ID)
+	assert.Equal(t, "alerts/"+msg.ID+".xml", index.Entries[0].Link.Href)
+
+	require.NoError(t, feed.Retract(msg))
+	status, body = get("/cap/active")
+	require.Equal(t, http.StatusOK, status)
+	index = atomFeed{}
+	require.NoError(t, xml.Unmarshal(body, &index))
+	assert.Empty(t, index.Entries)
+
+	status, body = get("/cap/recent")
+	require.Equal(t, http.StatusOK, status)
+	require.NoError(t, xml.Unmarshal(body, &index))
+	assert.Len(t, index.Entries, 1)
+
+	status, _ = get("/cap/alerts/missing.xml")
+	assert.Equal(t, http.StatusNotFound, status)
+}
+
+func TestCAPTime(t *testing.T) {
+	sent := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	assert.Equal(t, "2024-01-15T10:00:00-00:00", capTime(sent))
+	assert.Equal(t, "2024-01-15T12:00:00+02:00", capTime(sent.In(time.FixedZone("IST", 2*60*60))))
+
+	alert := NewCAPAlert(testMessage(), "goalert@example.com", "Alert", sent)
+	assert.Equal(t, "2024-01-15T10:00:00-00:00", alert.Sent)
+	assert.Equal(t, "2024-01-15T10:00:00-00:00", alert.Info[0].Effective)
+	assert.Equal(t, "2024-01-15T10:10:00-00:00", alert.Info[0].Expires)
+}
@@ -0,0 +1,82 @@
+package sinks
+
+import (
+	"github.com/mattermost/mattermost/server/public/shared/mlog"
+	"github.com/phntom/goalert/internal/bot"
+	"github.com/phntom/goalert/internal/storage"
+	"slices"
+	"time"
+)
+
+// History records every delivered alert in the embedded database.
+// Register it after the chat sinks so the number of posts created is known.
+type History struct {
+	DB *storage.DB
+	// Retention is how long alerts are kept, RunRetention deletes older ones
+	Retention time.Duration
+}
+
+// pruneInterval is how often RunRetention deletes the alerts past the retention.
+const pruneInterval = time.Hour
+
+func (h *History) Name() string {
+	return "history"
+}
+
+// Local records alerts on standby replicas too, each one keeps its own database.
+func (h *History) Local() bool {
+	return true
+}
+
+func (h *History) Create(m *bot.Message) error {
+	return h.DB.SaveAlert(historyRecord(m, false))
+}
+
+func (h *History) Update(m *bot.Message) error {
+	return h.DB.SaveAlert(historyRecord(m, false))
+}
+
+func (h *History) React(m *bot.Message, emoji string) error {
+	return nil
+}
+
+// Retract keeps the number of posts recorded before the chat sinks deleted them.
+func (h *History) Retract(m *bot.Message) error {
+	return h.DB.RetractAlert(historyRecord(m, true))
+}
+
+// RunRetention deletes the alerts received longer than Retention ago, right away and then every hour.
+func (h *History) RunRetention() {
+	for {
+		deleted, err := h.DB.PruneAlerts(time.Now().Add(-h.Retention))
+		if err != nil {
+			mlog.Error("failed pruning alert history", mlog.Err(err))
+		} else if deleted > 0 {
+			mlog.Info("Pruned alert history", mlog.Any("deleted", deleted), mlog.Any("retention", h.Retention))
+		}
+		time.Sleep(pruneInterval)
+	}
+}
+
+func historyRecord(m *bot.Message, retracted bool) storage.AlertRecord {
+	m.PostMutex.Lock()
+	posts := len(m.PostIDs)
+	m.PostMutex.Unlock()
+	rocketIDs := make([]string, 0, len(m.RocketIDs))
+	for rocketID := range m.RocketIDs {
+		rocketIDs = append(rocketIDs, rocketID)
+	}
+	slices.Sort(rocketIDs)
+	return storage.AlertRecord{
+		ID:           m.ID,
+		Source:       m.Source,
+		Category:     m.Category,
+		Instructions: m.Instructions,
+		Districts:    slices.Clone(m.Cities),
+		RocketIDs:    rocketIDs,
+		PubDate:      m.PubDate,
+		Received:     m.Received,
+		Posts:        posts,
+		Retracted:    retracted,
+	}
+}
@@ -0,0 +1,61 @@
+package sinks
+
+import (
+	"github.com/phntom/goalert/internal/bot"
+	"github.com/phntom/goalert/internal/config"
+	"github.com/phntom/goalert/internal/district"
+	"slices"
+	"time"
+)
+
+const (
+	EventCreated   = "alert.created"
+	EventUpdated   = "alert.updated"
+	EventRetracted = "alert.retracted"
+)
+
+// Payload is the JSON document describing an alert to machine consumers.
+type Payload struct {
+	Event         string                       `json:"event"`
+	ID            string                       `json:"id"`
+	Category      string                       `json:"category"`
+	Instructions  string                       `json:"instructions"`
+	Districts     []district.ID                `json:"districts"`
+	Names         map[config.Language][]string `json:"names"`
+	Unrecognized  []string                     `json:"unrecognized,omitempty"`
+	SafetySeconds uint                         `json:"safety_seconds"`
+	PubDate       string                       `json:"pubdate"`
+	RocketIDs     []string                     `json:"rocket_ids"`
+	ConfirmedBy   []string                     `json:"confirmed_by"`
+	SentAt        time.Time                    `json:"sent_at"`
+}
+
+// NewPayload snapshots m, it must be called from the goroutine that owns the message.
+func NewPayload(event string, m *bot.Message) Payload {
+	districts := district.GetDistricts()
+	names := make(map[config.Language][]string, len(config.Languages))
+	for _, lang := range config.Languages {
+		for _, city := range m.Cities {
+			names[lang] = append(names[lang], districts[lang][city].SettlementName)
+		}
+	}
+	rocketIDs := make([]string, 0, len(m.RocketIDs))
+	for rocketID := range m.RocketIDs {
+		rocketIDs = append(rocketIDs, rocketID)
+	}
+	slices.Sort(rocketIDs)
+	return Payload{
+		Event:         event,
+		ID:            m.ID,
+		Category:      m.Category,
+		Instructions:  m.Instructions,
+		Districts:     slices.Clone(m.Cities),
+		Names:         names,
+		Unrecognized:  slices.Clone(m.Unrecognized),
+		SafetySeconds: m.SafetySeconds,
+		PubDate:       m.PubDate,
+		RocketIDs:     rocketIDs,
+		ConfirmedBy:   m.ConfirmedBy(),
+		SentAt:        time.Now().UTC(),
+	}
+}
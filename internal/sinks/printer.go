@@ -0,0 +1,53 @@
+package sinks
+
+import (
+	"encoding/json"
+	"github.com/phntom/goalert/internal/bot"
+	"io"
+	"sync"
+)
+
+// Printer writes every delivery as a JSON line with its Hebrew title, used to follow a replay.
+type Printer struct {
+	W  io.Writer
+	mu sync.Mutex
+}
+
+type printedEvent struct {
+	Payload
+	Title string `json:"title"`
+}
+
+func (p *Printer) Name() string {
+	return "printer"
+}
+
+func (p *Printer) Create(m *bot.Message) error {
+	return p.print(EventCreated, m)
+}
+
+func (p *Printer) Update(m *bot.Message) error {
+	return p.print(EventUpdated, m)
+}
+
+func (p *Printer) React(m *bot.Message, emoji string) error {
+	return nil
+}
+
+func (p *Printer) Retract(m *bot.Message) error {
+	return p.print(EventRetracted, m)
+}
+
+func (p *Printer) print(event string, m *bot.Message) error {
+	data, err := json.Marshal(printedEvent{
+		Payload: NewPayload(event, m),
+		Title:   bot.RenderTitle(m, "he"),
+	})
+	if err != nil {
+		return err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	_, err = p.W.Write(append(data, '\n'))
+	return err
+}
@@ -0,0 +1,227 @@
+package sinks
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/mattermost/mattermost/server/public/shared/mlog"
+	"github.com/phntom/goalert/internal/bot"
+	"github.com/phntom/goalert/internal/config"
+	"github.com/phntom/goalert/internal/district"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+const (
+	streamClientBuffer = 32
+	streamHeartbeat    = 15 * time.Second
+)
+
+// StreamAlert is the event pushed to dashboards, the payload plus display names per district.
+type StreamAlert struct {
+	Payload
+	Locations []StreamLocation `json:"locations"`
+}
+
+type StreamLocation struct {
+	District district.ID                    `json:"district"`
+	AreaID   int                            `json:"area_id"`
+	Names    map[config.Language]StreamName `json:"names"`
+}
+
+type StreamName struct {
+	City         string   `json:"city"`
+	Subdivisions []string `json:"subdivisions,omitempty"`
+}
+
+type streamClient struct {
+	events    chan []byte
+	districts map[district.ID]bool
+}
+
+// Stream pushes new and patched alerts to dashboards as server-sent events on GET /stream.
+// Clients may narrow it down with city, district and area query parameters.
+// A client that does not keep up is disconnected rather than slowing down the bot, EventSource reconnects on its own.
+type Stream struct {
+	mu      sync.Mutex
+	clients map[*streamClient]bool
+}
+
+func NewStream() *Stream {
+	return &Stream{clients: make(map[*streamClient]bool)}
+}
+
+func (s *Stream) Name() string {
+	return "stream"
+}
+
+// Local keeps streaming to the clients still connected to a replica that lost the lease.
+func (s *Stream) Local() bool {
+	return true
+}
+
+func (s *Stream) Create(m *bot.Message) error {
+	s.publish(NewStreamAlert(EventCreated, m))
+	return nil
+}
+
+func (s *Stream) Update(m *bot.Message) error {
+	s.publish(NewStreamAlert(EventUpdated, m))
+	return nil
+}
+
+func (s *Stream) React(m *bot.Message, emoji string) error {
+	return nil
+}
+
+func (s *Stream) Retract(m *bot.Message) error {
+	s.publish(NewStreamAlert(EventRetracted, m))
+	return nil
+}
+
+func NewStreamAlert(event string, m *bot.Message) StreamAlert {
+	alert := StreamAlert{Payload: NewPayload(event, m)}
+	districts := district.GetDistricts()
+	for _, id := range m.Cities {
+		location := StreamLocation{
+			District: id,
+			AreaID:   districts["he"][id].AreaID,
+			Names:    make(map[config.Language]StreamName, len(config.Languages)),
+		}
+		for _, lang := range config.Languages {
+			city, subdivisions := district.GetCity(id, lang)
+			location.Names[lang] = StreamName{City: city, Subdivisions: subdivisions}
+		}
+		alert.Locations = append(alert.Locations, location)
+	}
+	return alert
+}
+
+// filter narrows the alert to the districts a client asked for, false if none of it is relevant.
+func (a StreamAlert) filter(districts map[district.ID]bool) (StreamAlert, bool) {
+	if districts == nil || len(a.Districts) == 0 {
+		return a, true
+	}
+	result := a
+	result.Districts = nil
+	result.Locations = nil
+	for i, id := range a.Districts {
+		if !districts[id] {
+			continue
+		}
+		result.Districts = append(result.Districts, id)
+		if i < len(a.Locations) {
+			result.Locations = append(result.Locations, a.Locations[i])
+		}
+	}
+	return result, len(result.Districts) > 0
+}
+
+func (s *Stream) publish(alert StreamAlert) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for client := range s.clients {
+		filtered, ok := alert.filter(client.districts)
+		if !ok {
+			continue
+		}
+		data, err := json.Marshal(filtered)
+		if err != nil {
+			mlog.Error("failed encoding stream event", mlog.Err(err))
+			return
+		}
+		select {
+		case client.events <- []byte(fmt.Sprintf("event: %s\nid: %s\ndata: %s\n\n", alert.Event, alert.ID, data)):
+		default:
+			mlog.Warn("dropping slow stream client")
+			delete(s.clients, client)
+			close(client.events)
+		}
+	}
+}
+
+// streamDistricts returns the districts asked for by the query, nil when it asks for all of them. Unknown cities and
+// areas are rejected like /history does, rather than leaving the client with a stream that never sends anything.
+func streamDistricts(query url.Values) (map[district.ID]bool, error) {
+	if !query.Has("city") && !query.Has("district") && !query.Has("area") {
+		return nil, nil
+	}
+	districts := make(map[district.ID]bool)
+	for _, city := range query["city"] {
+		id := district.GetDistrictByCity(city)
+		if id == "" {
+			return nil, errors.New("unknown city " + city)
+		}
+		districts[id] = true
+	}
+	for _, id := range query["district"] {
+		districts[district.ID(id)] = true
+	}
+	for _, area := range query["area"] {
+		ids := district.GetDistrictsByArea(area)
+		if len(ids) == 0 {
+			return nil, errors.New("unknown area " + area)
+		}
+		for id := range ids {
+			districts[id] = true
+		}
+	}
+	return districts, nil
+}
+
+func (s *Stream) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	districts, err := streamDistricts(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	client := &streamClient{events: make(chan []byte, streamClientBuffer), districts: districts}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	s.mu.Lock()
+	s.clients[client] = true
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		if s.clients[client] {
+			delete(s.clients, client)
+			close(client.events)
+		}
+		s.mu.Unlock()
+	}()
+
+	heartbeat := time.NewTicker(streamHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case event, ok := <-client.events:
+			if !ok {
+				return
+			}
+			if _, err := w.Write(event); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-heartbeat.C:
+			if _, err := w.Write([]byte(": keep-alive\n\n")); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
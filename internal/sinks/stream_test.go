@@ -0,0 +1,111 @@
+package sinks
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/phntom/goalert/internal/bot"
+	"github.com/phntom/goalert/internal/district"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func connectStream(t *testing.T, server *httptest.Server, stream *Stream, query string) *bufio.Reader {
+	t.Helper()
+	res, err := http.Get(server.URL + "/stream?" + query)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		//goland:noinspection GoUnhandledErrorResult
+		res.Body.Close()
+	})
+	require.Equal(t, "text/event-stream", res.Header.Get("Content-Type"))
+	require.Eventually(t, func() bool {
+		stream.mu.Lock()
+		defer stream.mu.Unlock()
+		return len(stream.clients) > 0
+	}, time.Second, 5*time.Millisecond)
+	return bufio.NewReader(res.Body)
+}
+
+func readStreamEvent(t *testing.T, reader *bufio.Reader) (string, StreamAlert) {
+	t.Helper()
+	var event string
+	var alert StreamAlert
+	for {
+		line, err := reader.ReadString('\n')
+		require.NoError(t, err)
+		line = strings.TrimRight(line, "\n")
+		switch {
+		case strings.HasPrefix(line, "event: "):
+			event = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			require.NoError(t, json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &alert))
+		case line == "" && event != "":
+			return event, alert
+		}
+	}
+}
+
+func TestStream(t *testing.T) {
+	stream := NewStream()
+	server := httptest.NewServer(stream)
+	defer server.Close()
+
+	msg := bot.NewMessage("instructions", "rockets", 90, "11:40")
+	msg.AppendDistrict("999")
+	msg.AppendDistrict("241")
+
+	t.Run("all alerts", func(t *testing.T) {
+		reader := connectStream(t, server, stream, "")
+		require.NoError(t, stream.Create(&msg))
+		event, alert := readStreamEvent(t, reader)
+		assert.Equal(t, EventCreated, event)
+		assert.Equal(t, []district.ID{"999", "241"}, alert.Districts)
+		require.Len(t, alert.Locations, 2)
+		assert.Equal(t, "Ein Harod", alert.Locations[0].Names["en"].City)
+		assert.Equal(t, 34, alert.Locations[0].AreaID)
+	})
+
+	t.Run("filtered by area", func(t *testing.T) {
+		reader := connectStream(t, server, stream, "area=South+Golan")
+		require.NoError(t, stream.Update(&msg))
+		event, alert := readStreamEvent(t, reader)
+		assert.Equal(t, EventUpdated, event)
+		assert.Equal(t, []district.ID{"241"}, alert.Districts)
+		require.Len(t, alert.Locations, 1)
+		assert.Equal(t, "בני יהודה", alert.Locations[0].Names["he"].City)
+	})
+}
+
+func TestStream_UnknownFilter(t *testing.T) {
+	server := httptest.NewServer(NewStream())
+	defer server.Close()
+	for _, query := range []string{"city=Atlantis", "area=Atlantis", "city=Ein+Harod&area=Atlantis"} {
+		res, err := http.Get(server.URL + "/stream?" + query)
+		require.NoError(t, err)
+		//goland:noinspection GoUnhandledErrorResult
+		res.Body.Close()
+		assert.Equal(t, http.StatusBadRequest, res.StatusCode, query)
+	}
+}
+
+func TestStream_DropsSlowClient(t *testing.T) {
+	stream := NewStream()
+	client := &streamClient{events: make(chan []byte, streamClientBuffer)}
+	stream.clients[client] = true
+	msg := testMessage()
+	for i := 0; i <= streamClientBuffer; i++ {
+		require.NoError(t, stream.Update(msg))
+	}
+	assert.Empty(t, stream.clients)
+	count := 0
+	for range client.events {
+		count++
+	}
+	assert.Equal(t, streamClientBuffer, count)
+}
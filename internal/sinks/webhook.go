@@ -0,0 +1,203 @@
+package sinks
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/shared/mlog"
+	"github.com/phntom/goalert/internal/bot"
+	"github.com/phntom/goalert/internal/monitoring"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	SignatureHeader = "X-Goalert-Signature"
+	TimestampHeader = "X-Goalert-Timestamp"
+	EventHeader     = "X-Goalert-Event"
+	DeliveryHeader  = "X-Goalert-Delivery"
+)
+
+// SignatureTolerance is how far the signed timestamp of a delivery may be from the receiver's clock. Receivers
+// reject older deliveries so a captured one cannot be replayed later, and can drop repeated delivery ids within it.
+const SignatureTolerance = 5 * time.Minute
+
+// Webhook posts every alert as a signed JSON Payload to a list of HTTP endpoints.
+// Each endpoint has its own queue so a slow receiver neither blocks the bot nor the other endpoints,
+// and deliveries to the same endpoint keep their order.
+type Webhook struct {
+	secret      []byte
+	client      *http.Client
+	endpoints   []*webhookEndpoint
+	monitor     *monitoring.Monitoring
+	maxAttempts int
+	backoff     time.Duration
+}
+
+type webhookEndpoint struct {
+	url   string
+	queue chan webhookDelivery
+}
+
+type webhookDelivery struct {
+	id    string
+	event string
+	body  []byte
+}
+
+func NewWebhook(urls []string, secret string, monitor *monitoring.Monitoring) *Webhook {
+	w := &Webhook{
+		secret:      []byte(secret),
+		client:      &http.Client{Timeout: 5 * time.Second},
+		monitor:     monitor,
+		maxAttempts: 6,
+		backoff:     500 * time.Millisecond,
+	}
+	for _, url := range urls {
+		url = strings.TrimSpace(url)
+		if url == "" {
+			continue
+		}
+		endpoint := &webhookEndpoint{
+			url:   url,
+			queue: make(chan webhookDelivery, 100),
+		}
+		w.endpoints = append(w.endpoints, endpoint)
+		go w.run(endpoint)
+	}
+	return w
+}
+
+func (w *Webhook) Name() string {
+	return "webhook"
+}
+
+func (w *Webhook) Create(m *bot.Message) error {
+	return w.enqueue(NewPayload(EventCreated, m))
+}
+
+func (w *Webhook) Update(m *bot.Message) error {
+	return w.enqueue(NewPayload(EventUpdated, m))
+}
+
+func (w *Webhook) React(m *bot.Message, emoji string) error {
+	return nil
+}
+
+func (w *Webhook) Retract(m *bot.Message) error {
+	return w.enqueue(NewPayload(EventRetracted, m))
+}
+
+// Sign returns the value of the signature header for body sent at timestamp, the unix seconds in the timestamp
+// header. The signed material is the timestamp, a dot and the body, receivers recompute it with the shared secret.
+func Sign(secret []byte, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify checks the signature and timestamp headers of a delivery received at now.
+func Verify(secret []byte, timestamp string, signature string, body []byte, now time.Time) error {
+	if !hmac.Equal([]byte(signature), []byte(Sign(secret, timestamp, body))) {
+		return errors.New("invalid signature")
+	}
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid timestamp: %w", err)
+	}
+	if age := now.Sub(time.Unix(seconds, 0)); age > SignatureTolerance || age < -SignatureTolerance {
+		return fmt.Errorf("timestamp %s is outside the tolerance of %s", timestamp, SignatureTolerance)
+	}
+	return nil
+}
+
+func (w *Webhook) enqueue(payload Payload) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	delivery := webhookDelivery{
+		id:    model.NewId(),
+		event: payload.Event,
+		body:  body,
+	}
+	var dropped []string
+	for _, endpoint := range w.endpoints {
+		select {
+		case endpoint.queue <- delivery:
+		default:
+			dropped = append(dropped, endpoint.url)
+			w.monitor.FailedDeliveries.WithLabelValues(w.Name()).Inc()
+		}
+	}
+	if len(dropped) > 0 {
+		return fmt.Errorf("webhook queue full, dropped %s for %v", payload.Event, dropped)
+	}
+	return nil
+}
+
+func (w *Webhook) run(endpoint *webhookEndpoint) {
+	for delivery := range endpoint.queue {
+		backoff := w.backoff
+		for attempt := 1; ; attempt++ {
+			retry, err := w.deliver(endpoint.url, delivery)
+			if err == nil {
+				w.monitor.SuccessfulDeliveries.WithLabelValues(w.Name()).Inc()
+				break
+			}
+			mlog.Warn("failed delivering webhook",
+				mlog.Err(err),
+				mlog.Any("url", endpoint.url),
+				mlog.Any("event", delivery.event),
+				mlog.Any("attempt", attempt),
+			)
+			if !retry || attempt >= w.maxAttempts {
+				mlog.Error("giving up webhook delivery",
+					mlog.Any("url", endpoint.url),
+					mlog.Any("delivery", delivery.id),
+				)
+				w.monitor.FailedDeliveries.WithLabelValues(w.Name()).Inc()
+				break
+			}
+			time.Sleep(backoff)
+			backoff = min(backoff*2, 30*time.Second)
+		}
+	}
+}
+
+// deliver sends a single attempt, the returned bool tells whether the failure is worth retrying.
+func (w *Webhook) deliver(url string, delivery webhookDelivery) (bool, error) {
+	req, err := http.NewRequest("POST", url, bytes.NewReader(delivery.body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "goalert")
+	req.Header.Set(EventHeader, delivery.event)
+	req.Header.Set(DeliveryHeader, delivery.id)
+	if len(w.secret) > 0 {
+		// every attempt is signed when it is sent, retries stay within the receiver's tolerance
+		timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+		req.Header.Set(TimestampHeader, timestamp)
+		req.Header.Set(SignatureHeader, Sign(w.secret, timestamp, delivery.body))
+	}
+	res, err := w.client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	//goland:noinspection GoUnhandledErrorResult
+	defer res.Body.Close()
+	if res.StatusCode >= 200 && res.StatusCode < 300 {
+		return false, nil
+	}
+	retry := res.StatusCode >= 500 || res.StatusCode == http.StatusTooManyRequests || res.StatusCode == http.StatusRequestTimeout
+	return retry, fmt.Errorf("unexpected status %d", res.StatusCode)
+}
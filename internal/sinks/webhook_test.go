@@ -0,0 +1,124 @@
+package sinks
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/phntom/goalert/internal/bot"
+	"github.com/phntom/goalert/internal/district"
+	"github.com/phntom/goalert/internal/monitoring"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testMonitoring() *monitoring.Monitoring {
+	return &monitoring.Monitoring{
+		SuccessfulDeliveries: prometheus.NewCounterVec(prometheus.CounterOpts{Name: "test_successful_deliveries"}, []string{"sink"}),
+		FailedDeliveries:     prometheus.NewCounterVec(prometheus.CounterOpts{Name: "test_failed_deliveries"}, []string{"sink"}),
+	}
+}
+
+func testMessage() *bot.Message {
+	msg := bot.NewMessage("instructions", "rockets", 90, "11:40")
+	msg.AppendDistrict("999")
+	msg.RocketIDs["f038657b"] = true
+	return &msg
+}
+
+type receivedWebhook struct {
+	event     string
+	timestamp string
+	signature string
+	body      []byte
+}
+
+func TestWebhook_SignedDeliveryWithRetry(t *testing.T) {
+	var mu sync.Mutex
+	var received []receivedWebhook
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		calls++
+		if calls == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		received = append(received, receivedWebhook{
+			event:     r.Header.Get(EventHeader),
+			timestamp: r.Header.Get(TimestampHeader),
+			signature: r.Header.Get(SignatureHeader),
+			body:      body,
+		})
+	}))
+	defer server.Close()
+
+	w := NewWebhook([]string{server.URL}, "s3cret", testMonitoring())
+	w.backoff = 10 * time.Millisecond
+	msg := testMessage()
+	require.NoError(t, w.Create(msg))
+	msg.RocketIDs["a1b2c3d4"] = true
+	require.NoError(t, w.Update(msg))
+
+	assert.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(received) == 2
+	}, 2*time.Second, 10*time.Millisecond)
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, EventCreated, received[0].event)
+	assert.Equal(t, EventUpdated, received[1].event)
+	for _, r := range received {
+		assert.NoError(t, Verify([]byte("s3cret"), r.timestamp, r.signature, r.body, time.Now()))
+	}
+	var payload Payload
+	require.NoError(t, json.Unmarshal(received[1].body, &payload))
+	assert.Equal(t, msg.ID, payload.ID)
+	assert.Equal(t, []district.ID{"999"}, payload.Districts)
+	assert.Equal(t, []string{"a1b2c3d4", "f038657b"}, payload.RocketIDs)
+	assert.Equal(t, []string{"עין חרוד"}, payload.Names["he"])
+	assert.Equal(t, uint(90), payload.SafetySeconds)
+}
+
+func TestWebhook_NoRetryOnClientError(t *testing.T) {
+	var mu sync.Mutex
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		calls++
+		mu.Unlock()
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	w := NewWebhook([]string{server.URL}, "", testMonitoring())
+	w.backoff = 10 * time.Millisecond
+	require.NoError(t, w.Create(testMessage()))
+
+	time.Sleep(200 * time.Millisecond)
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, 1, calls)
+}
+
+func TestVerify(t *testing.T) {
+	secret := []byte("s3cret")
+	body := []byte(`{"event":"created"}`)
+	sent := time.Unix(1700000000, 0)
+	signature := Sign(secret, "1700000000", body)
+
+	assert.NoError(t, Verify(secret, "1700000000", signature, body, sent.Add(time.Minute)))
+	assert.Error(t, Verify(secret, "1700000000", signature, body, sent.Add(SignatureTolerance+time.Second)), "replayed too late")
+	assert.Error(t, Verify(secret, "1700000600", signature, body, sent), "the timestamp is signed")
+	assert.Error(t, Verify(secret, "1700000000", signature, []byte(`{}`), sent), "the body is signed")
+	assert.Error(t, Verify([]byte("other"), "1700000000", signature, body, sent))
+}
@@ -1,8 +1,18 @@
 package sources
 
+import "time"
+
 const (
-	YnetURL      = "https://alerts.ynet.co.il/alertsRss/YnetPicodeHaorefAlertFiles.js?callback=jsonCallback"
-	YnetReferrer = "https://www.ynet.co.il/"
-	OrefURL      = "https://www.oref.org.il/WarningMessages/alert/alerts.json"
-	OrefReferrer = "https://www.oref.org.il//12481-he/Pakar.aspx"
+	orefSeenStateKey = "oref.seen"
+	ynetSeenStateKey = "ynet.seen"
 )
+
+// nextPoll is the next time after now that is offset past a multiple of interval,
+// e.g. 200ms after the next round second for an interval of 1s and an offset of 200ms.
+func nextPoll(now time.Time, interval time.Duration, offset time.Duration) time.Time {
+	next := now.Truncate(interval).Add(offset)
+	for !next.After(now) {
+		next = next.Add(interval)
+	}
+	return next
+}
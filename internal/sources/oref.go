@@ -6,8 +6,8 @@ import (
 	"github.com/phntom/goalert/internal/bot"
 	"github.com/phntom/goalert/internal/district"
 	"github.com/phntom/goalert/internal/fetcher"
+	"github.com/phntom/goalert/internal/recording"
 	"net/http"
-	"os"
 	"strconv"
 	"strings"
 	"time"
@@ -32,19 +32,22 @@ type OrefMessage struct {
 }
 
 type SourceOref struct {
-	client *http.Client
-	seen   map[string]bool
-	Bot    *bot.Bot
+	client   *http.Client
+	seen     map[string]bool
+	Bot      *bot.Bot
+	Recorder *recording.Recorder
 }
 
 func (s *SourceOref) Register() {
 	calculatePubTime("133448902120000000")
 	s.client = fetcher.CreateHTTPClient()
 	s.seen = make(map[string]bool)
+	s.Bot.LoadState(orefSeenStateKey, &s.seen)
 }
 
 func (s *SourceOref) Fetch() []byte {
-	return fetcher.FetchSource(s.client, OrefURL, "oref", OrefReferrer, &s.Bot.Monitoring)
+	settings := s.Bot.Settings().Sources.Oref
+	return fetcher.FetchSource(s.client, settings.URL, "oref", settings.Referrer, &s.Bot.Monitoring, s.Recorder)
 }
 
 func (s *SourceOref) Parse(content []byte) []*bot.Message {
@@ -55,6 +58,7 @@ func (s *SourceOref) Parse(content []byte) []*bot.Message {
 	}
 	dedup := make(map[string]*bot.Message)
 	var dedupOrder []string
+	var unrecognized []unrecognizedCity
 	var alerts OrefMessage
 	content = []byte(string(content)[start:])
 	err := json.Unmarshal(content, &alerts)
@@ -80,15 +84,8 @@ func (s *SourceOref) Parse(content []byte) []*bot.Message {
 			mlog.Warn("unknown oref category", mlog.Any("alerts", alerts))
 			continue
 		}
-		districtID := district.GetDistrictByCity(city)
-		if districtID == "" {
-			mlog.Warn("district not found",
-				mlog.Any("data", city),
-				mlog.Any("source", "oref"),
-			)
-			continue
-		}
-		cityObj := districts["he"][districtID]
+		match := s.Bot.MatchCity("oref", city)
+		cityObj := districts["he"][match.ID]
 		instructions := "instructions"
 		if category == "infiltration" || category == "radiological" || category == "biohazard" {
 			instructions = "lockdown"
@@ -96,13 +93,24 @@ func (s *SourceOref) Parse(content []byte) []*bot.Message {
 			instructions = "uav_instructions"
 		}
 		msg := bot.NewMessage(instructions, category, cityObj.SafetyBufferSeconds, calculatePubTime(alerts.ID))
+		msg.Source = "oref"
+		msg.Issued = orefIssued(alerts.ID)
+		if match.ID == "" {
+			mlog.Warn("district not found",
+				mlog.Any("data", city),
+				mlog.Any("source", "oref"),
+			)
+			unrecognized = append(unrecognized, unrecognizedCity{msg: &msg, name: city})
+			continue
+		}
 		hash := msg.GetHash()
 		if _, ok := dedup[hash]; !ok {
 			dedup[hash] = &msg
 			dedupOrder = append(dedupOrder, hash)
 		}
-		dedup[hash].AppendDistrict(districtID)
+		dedup[hash].AppendMatch(match)
 	}
+	dedupOrder = groupUnrecognized(dedup, dedupOrder, unrecognized)
 	var result []*bot.Message
 	for _, hash := range dedupOrder {
 		result = append(result, dedup[hash])
@@ -130,30 +138,33 @@ func calculatePubTime(id string) string {
 	return tInTimeZone.Format("15:04")
 }
 
+// orefIssued converts an oref alert id, a windows file time in 100ns ticks since 1601, to the time it was issued.
+func orefIssued(id string) time.Time {
+	ticks, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		return time.Time{}
+	}
+	const ticksPerSecond = 10000000
+	const secondsFrom1601To1970 = 11644473600
+	return time.Unix(ticks/ticksPerSecond-secondsFrom1601To1970, ticks%ticksPerSecond*100)
+}
+
 func (s *SourceOref) Run() {
-	failed := 0
 	counter := 0
 	for {
-		// Get the current time
-		now := time.Now()
-		// Calculate the time until the next round second
-		untilNextSecond := time.Until(time.Date(now.Year(), now.Month(), now.Day(), now.Hour(), now.Minute(), now.Second()+1, 0, now.Location()))
-		// Add 200 milliseconds
-		sleepDuration := untilNextSecond + 200*time.Millisecond
-		// Sleep for the calculated duration
-		time.Sleep(sleepDuration)
+		settings := s.Bot.Settings().Sources.Oref
+		time.Sleep(time.Until(nextPoll(time.Now(), settings.PollInterval, settings.PollOffset)))
 
+		start := time.Now()
 		content := s.Fetch()
+		s.Bot.Health.Report("oref", content != nil, time.Since(start))
 		//content := []byte("{\"id\": \"133449412450000000\",\"cat\": \"1\",\"title\": \"ירי רקטות וטילים\",\"data\": [\"בית שקמה\"],\"desc\": \"היכנסו למרחב המוגן ושהו בו 10 דקות\"}")
 		//content := []byte("{\n\t\t \"id\": \"133451945860000000\",\n\t\t \"cat\": \"1\",\n\t\t \"title\": \"ירי רקטות וטילים\",\n\t\t \"data\": [\n\t\t   \"ערב אל עראמשה\"\n\t\t ],\n\t\t \"desc\": \"היכנסו למרחב המוגן ושהו בו 10 דקות\"\n\t\t}")
 		if content == nil {
-			failed += 1
-			if failed > 30 {
-				os.Exit(4)
-			}
+			time.Sleep(s.Bot.Health.Backoff("oref"))
 			continue
 		}
-		failed = 0
+		seenBefore := len(s.seen)
 		empty := true
 		for _, m := range s.Parse(content) {
 			mlog.Debug("oref", mlog.Any("content", string(content)))
@@ -171,5 +182,8 @@ func (s *SourceOref) Run() {
 			}
 			counter = 0
 		}
+		if len(s.seen) != seenBefore {
+			s.Bot.SaveState(orefSeenStateKey, s.seen)
+		}
 	}
 }
@@ -1,6 +1,13 @@
 package sources
 
-import "testing"
+import (
+	"testing"
+	"time"
+
+	"github.com/phntom/goalert/internal/bot"
+	"github.com/phntom/goalert/internal/district"
+	"github.com/stretchr/testify/assert"
+)
 
 func Test_calculatePubTime(t *testing.T) {
 	type args struct {
@@ -48,3 +55,49 @@ func Test_calculatePubTime(t *testing.T) {
 		})
 	}
 }
+
+func Test_orefIssued(t *testing.T) {
+	want := time.Date(2023, 11, 19, 17, 56, 52, 0, time.UTC)
+	if got := orefIssued("133448902120000000"); !got.Equal(want) {
+		t.Errorf("orefIssued() = %v, want %v", got, want)
+	}
+	if got := orefIssued("invalid"); !got.IsZero() {
+		t.Errorf("orefIssued() = %v, want zero time", got)
+	}
+}
+
+func Test_nextPoll(t *testing.T) {
+	now := time.Date(2025, 6, 13, 3, 0, 0, 100*int(time.Millisecond), time.UTC)
+	tests := []struct {
+		interval time.Duration
+		offset   time.Duration
+		want     time.Time
+	}{
+		{time.Second, 200 * time.Millisecond, now.Add(100 * time.Millisecond)},
+		{time.Second, 0, now.Add(900 * time.Millisecond)},
+		{250 * time.Millisecond, 0, now.Add(150 * time.Millisecond)},
+	}
+	for _, tt := range tests {
+		if got := nextPoll(now, tt.interval, tt.offset); !got.Equal(tt.want) {
+			t.Errorf("nextPoll(%v, %v) = %v, want %v", tt.interval, tt.offset, got, tt.want)
+		}
+	}
+}
+
+func TestSourceOref_Parse_Unrecognized(t *testing.T) {
+	s := &SourceOref{Bot: &bot.Bot{}, seen: make(map[string]bool)}
+
+	messages := s.Parse([]byte(`{"id": "133730615650000000", "cat": "1", "data": ["מטולה", "ישוב דמיוני", "עין חרוד"]}`))
+	if assert.Len(t, messages, 2, "one message per migun time") {
+		assert.Equal(t, []district.ID{district.GetDistrictByCity("מטולה")}, messages[0].Cities)
+		assert.Equal(t, []string{"ישוב דמיוני"}, messages[0].Unrecognized, "grouped with the first message of the alert")
+		assert.Empty(t, messages[1].Unrecognized)
+	}
+
+	messages = s.Parse([]byte(`{"id": "133730615660000000", "cat": "1", "data": ["ישוב נוסף", "ישוב אחר"]}`))
+	if assert.Len(t, messages, 1) {
+		assert.Empty(t, messages[0].Cities)
+		assert.Equal(t, []string{"ישוב נוסף", "ישוב אחר"}, messages[0].Unrecognized)
+		assert.Equal(t, "rockets", messages[0].Category)
+	}
+}
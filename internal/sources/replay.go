@@ -0,0 +1,57 @@
+package sources
+
+import (
+	"errors"
+	"github.com/mattermost/mattermost/server/public/shared/mlog"
+	"github.com/phntom/goalert/internal/bot"
+	"github.com/phntom/goalert/internal/recording"
+	"io"
+	"net/http"
+	"time"
+)
+
+// Replay feeds a recording through the same parsing the live sources use and submits the result to b.
+// Entries keep their recorded pacing divided by speed, a speed of 0 replays them back to back.
+// Telegram messages are checked for expiry against their recorded time, but alerts still expire
+// from the dedup on the wall clock, so an accelerated replay merges more than the live run did.
+func Replay(b *bot.Bot, reader *recording.Reader, speed float64) error {
+	var last time.Time
+	ynet := SourceYnet{Bot: b, seen: make(map[string]bool), now: func() time.Time { return last }}
+	oref := SourceOref{Bot: b, seen: make(map[string]bool)}
+	for {
+		entry, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if speed > 0 && !last.IsZero() && entry.Time.After(last) {
+			time.Sleep(time.Duration(float64(entry.Time.Sub(last)) / speed))
+		}
+		last = entry.Time
+		if entry.Status != 0 && entry.Status != http.StatusOK {
+			// the live sources discard failed fetches
+			continue
+		}
+		switch entry.Source {
+		case "ynet":
+			for _, m := range ynet.Parse([]byte(entry.Payload)) {
+				b.SubmitMessage(m)
+			}
+		case "oref":
+			for _, m := range oref.Parse([]byte(entry.Payload)) {
+				b.SubmitMessage(m)
+			}
+		case "telegram":
+			if entry.Channel != 0 && entry.Channel != b.Settings().Sources.Telegram.AlertChannel {
+				continue
+			}
+			if err := processAlertChannelMessage(entry.Payload, entry.Time, b); err != nil {
+				mlog.Warn("skipped recorded telegram message", mlog.Err(err), mlog.Any("time", entry.Time))
+			}
+		default:
+			mlog.Warn("unknown recorded source", mlog.Any("source", entry.Source), mlog.Any("time", entry.Time))
+		}
+	}
+}
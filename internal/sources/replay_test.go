@@ -0,0 +1,80 @@
+package sources
+
+import (
+	"bytes"
+	"encoding/json"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/phntom/goalert/internal/bot"
+	"github.com/phntom/goalert/internal/district"
+	"github.com/phntom/goalert/internal/recording"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type capturingSink struct {
+	mu      sync.Mutex
+	created [][]district.ID
+}
+
+func (s *capturingSink) Name() string                             { return "capture" }
+func (s *capturingSink) Update(m *bot.Message) error              { return nil }
+func (s *capturingSink) React(m *bot.Message, emoji string) error { return nil }
+func (s *capturingSink) Retract(m *bot.Message) error             { return nil }
+
+func (s *capturingSink) Create(m *bot.Message) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.created = append(s.created, append([]district.ID(nil), m.Cities...))
+	return nil
+}
+
+func (s *capturingSink) snapshot() [][]district.ID {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([][]district.ID(nil), s.created...)
+}
+
+func TestReplay(t *testing.T) {
+	recorded := time.Date(2024, 10, 10, 11, 19, 5, 0, jerusalem)
+	ynet := `jsonCallback({"alerts": {"items": [{"item": {"guid": "f038657b-99e1-48ec-b5c2-3e49c409b3bb","pubdate": "11:19","title": "עין חרוד","description": "היכנסו למרחב המוגן ושהו בו 10 דקות","link": ""}}]}});`
+	entries := []recording.Entry{
+		{Time: recorded, Source: "ynet", Payload: ynet},
+		// the same ynet response polled again must not alert twice
+		{Time: recorded.Add(250 * time.Millisecond), Source: "ynet", Payload: ynet},
+		{Time: recorded.Add(time.Second), Source: "oref", Payload: "\ufeff{\"id\": \"133730219450000000\",\"cat\": \"1\",\"title\": \"ירי רקטות וטילים\",\"data\": [\"עין חרוד\"],\"desc\": \"היכנסו למרחב המוגן ושהו בו 10 דקות\"}"},
+		{Time: recorded.Add(20 * time.Second), Source: "telegram", Payload: `🚨 ירי רקטות וטילים (10/10/2024) 11:19
+
+אזור קו העימות
+מטולה (מיידי)
+עין חרוד (90 שניות)
+
+היכנסו למרחב המוגן ושהו בו למשך 10 דקות.`},
+		{Time: recorded.Add(21 * time.Second), Source: "unknown", Payload: "ignored"},
+		// failed fetches and other telegram channels are recorded but never parsed
+		{Time: recorded.Add(22 * time.Second), Source: "oref", Status: 503, Payload: "{\"id\": \"133730615650000000\",\"cat\": \"1\",\"data\": [\"מטולה\"]}"},
+		{Time: recorded.Add(23 * time.Second), Source: "telegram", Channel: 1155294424, Payload: "🚨 ירי רקטות וטילים (10/10/2024) 11:19\n\nמטולה (מיידי)"},
+	}
+	var buf bytes.Buffer
+	for _, entry := range entries {
+		line, err := json.Marshal(entry)
+		require.NoError(t, err)
+		buf.Write(append(line, '\n'))
+	}
+
+	b := bot.Bot{}
+	b.Prepare()
+	sink := &capturingSink{}
+	b.AddSink(sink)
+	go b.AwaitMessage()
+
+	require.NoError(t, Replay(&b, recording.NewReader(&buf), 0))
+	require.Eventually(t, func() bool {
+		return len(sink.snapshot()) == 2
+	}, time.Second, 10*time.Millisecond)
+	created := sink.snapshot()
+	assert.Equal(t, []district.ID{district.GetDistrictByCity("עין חרוד")}, created[0])
+	assert.Contains(t, created[1], district.GetDistrictByCity("מטולה"))
+}
@@ -12,10 +12,13 @@ import (
 	"github.com/mattermost/mattermost/server/public/model"
 	"github.com/mattermost/mattermost/server/public/shared/mlog"
 	"github.com/phntom/goalert/internal/bot"
+	"github.com/phntom/goalert/internal/config"
 	"github.com/phntom/goalert/internal/district"
+	"github.com/phntom/goalert/internal/recording"
 	"log"
 	"regexp"
 	"strings"
+	"sync/atomic"
 	"time"
 	"fmt" // Added for formatting
 )
@@ -29,9 +32,13 @@ var extractPubTimeRe = regexp.MustCompile(`\((\d{1,2}/\d{1,2}/\d{4})\) (\d{1,2}:
 var CreatePostTestHook func(post *model.Post) bool
 
 type SourceTelegram struct {
-	Bot *bot.Bot
-	client *telegram.Client
-	gaps   *updates.Manager
+	Bot        *bot.Bot
+	Recorder   *recording.Recorder
+	appID      int
+	appHash    string
+	options    telegram.Options
+	gaps       *updates.Manager
+	authorized atomic.Bool
 }
 
 func (s *SourceTelegram) Register() {
@@ -41,7 +48,7 @@ func (s *SourceTelegram) Register() {
 	})
 	d.OnNewChannelMessage(s.ParseMessage)
 
-	client, err := telegram.ClientFromEnvironment(telegram.Options{
+	opts, err := telegram.OptionsFromEnvironment(telegram.Options{
 		UpdateHandler: gaps,
 		Middlewares: []telegram.Middleware{
 			updhook.UpdateHook(gaps.Handle),
@@ -55,7 +62,10 @@ func (s *SourceTelegram) Register() {
 		mlog.Error("telegram client error", mlog.Err(err))
 		// os.Exit(7) // Consider removing os.Exit from library code.
 	}
-	s.client = client
+	// a client cannot run again once it stopped, Run creates one for every connection
+	settings := s.Bot.Settings().Sources.Telegram
+	s.appID, s.appHash = settings.AppID, settings.AppHash
+	s.options = opts
 	s.gaps = gaps
 }
 
@@ -100,98 +110,104 @@ func (s *SourceTelegram) ParseMessage(ctx context.Context, e tg.Entities, update
 	if text == "" {
 		return nil
 	}
+	err := s.Recorder.Record(recording.Entry{
+		Source:  "telegram",
+		Channel: channelId.ChannelID,
+		Payload: text,
+	})
+	if err != nil {
+		mlog.Error("failed recording payload", mlog.Err(err), mlog.Any("source", "telegram"))
+	}
 
-	if channelId.ChannelID == 1441886157 { // pikudhaoref_all
-		now := time.Now()
-		var err error
-		if strings.Contains(text, "בדקות הקרובות צפויות להתקבל התרעות באזורך") {
-			// Early alert detected, process with "rockets" category
-			err = processMessage(text, district.GetDistricts(), now, s.Bot, "rockets")
-		} else {
-			err = processMessage(text, district.GetDistricts(), now, s.Bot, "")
-		}
-		if err != nil {
+	settings := s.Bot.Settings()
+	if channelId.ChannelID == settings.Sources.Telegram.AlertChannel {
+		if err := processAlertChannelMessage(text, time.Now(), s.Bot); err != nil {
 			return err
 		}
-	} else if channelId.ChannelID == 1155294424 { // idf_telegram
-		important := false
-		if strings.Contains(text, "התרע") || strings.Contains(text, "פיגוע") || strings.Contains(text, "יירט") || strings.Contains(text, "מדיניות") || strings.Contains(text, "הנחיות") {
-			important = true
-		}
-		mlog.Info("IDF message", mlog.String("text", text), mlog.Bool("important", important))
-		post := model.Post{
-			Message: text,
+		return nil
+	}
+	for _, rule := range settings.Sources.Telegram.Rules {
+		if rule.Channel == channelId.ChannelID {
+			s.forward(rule, text)
+			return nil
 		}
-		if important {
-			// post.Metadata.Priority.Priority = model.NewString("important")
-			s.Bot.DirectMessage(&post, "he")
+	}
+	mlog.Debug("Unknown channel id", mlog.Any("channelId", channelId.ChannelID))
+	return nil
+}
+
+// forward posts text to the rule's Mattermost channel, or every channel in its language, when it has one of its keywords.
+func (s *SourceTelegram) forward(rule config.TelegramRule, text string) {
+	foundKeyword := false
+	for _, keyword := range rule.Keywords {
+		if strings.Contains(text, keyword) {
+			foundKeyword = true
+			break
 		}
-	} else if channelId.ChannelID == 2335255539 {
-		keywords := []string{"ירוט", "ירט", "אזעק", "תימן", "תימני", "יורט", "שיגור", "פיצוץ"}
-		foundKeyword := false
-		for _, keyword := range keywords {
-			if strings.Contains(text, keyword) {
-				foundKeyword = true
-				break
-			}
+	}
+	mlog.Info("Telegram rule message", mlog.String("rule", rule.Name), mlog.String("text", text), mlog.Bool("important", foundKeyword))
+	if !foundKeyword {
+		return
+	}
+	post := model.Post{
+		Message: rule.Prefix + text,
+	}
+	if rule.Language != "" {
+		// post.Metadata.Priority.Priority = model.NewString("important")
+		s.Bot.DirectMessage(&post, rule.Language)
+		return
+	}
+	// Note: s.Bot.Channels might not be populated if FindBotChannel hasn't run or is run by a different instance.
+	channels := s.Bot.AlertChannels()
+	if s.Bot.Client == nil || len(channels) == 0 {
+		mlog.Warn("Bot instance or channels list not initialized, cannot find target channel by name", mlog.String("targetChannelName", rule.Target))
+		return
+	}
+	for _, ch := range channels {
+		if ch.Name == rule.Target {
+			post.ChannelId = ch.Id
+			break
 		}
+	}
+	if post.ChannelId == "" {
+		mlog.Warn("Could not find the Mattermost channel of a Telegram rule", mlog.String("rule", rule.Name), mlog.String("target", rule.Target))
+		return
+	}
+	if CreatePostTestHook != nil && CreatePostTestHook(&post) {
+		// Test hook handled the post, do nothing further.
+		return
+	}
+	if !s.Bot.IsLeader() {
+		// A standby replica leaves forwarding to the leader.
+		return
+	}
+	if _, _, err := s.Bot.Client.CreatePost(context.Background(), &post); err != nil {
+		mlog.Error("Failed to send message to specific Mattermost channel", mlog.String("channelId", post.ChannelId), mlog.Err(err))
+	}
+}
 
-		if foundKeyword {
-			modifiedText := "חדשות ישראל בטלגרם: " + text
-			// Attempt to find the corresponding Mattermost channel by a conventional name.
-			// Assumes Mattermost channel is named e.g., "telegram-2335255539"
-			// Note: s.Bot.channels might not be populated if FindBotChannel hasn't run or is run by a different instance.
-			// This part of the logic relies on s.Bot.channels being available and correctly populated.
-			targetChannelName := fmt.Sprintf("telegram-%d", channelId.ChannelID)
-			var targetMattermostChannelID string
-
-			if s.Bot != nil && s.Bot.Client != nil && s.Bot.Channels != nil {
-				for _, ch := range s.Bot.Channels {
-					if ch.Name == targetChannelName {
-						targetMattermostChannelID = ch.Id
-						break
-					}
-				}
-			} else {
-				mlog.Warn("Bot instance or channels list not initialized, cannot find target channel by name", mlog.String("targetChannelName", targetChannelName))
-			}
-
-			if targetMattermostChannelID != "" {
-				post := model.Post{
-					Message:   modifiedText,
-					ChannelId: targetMattermostChannelID,
-				}
-				if CreatePostTestHook != nil && CreatePostTestHook(&post) {
-					// Test hook handled the post, do nothing further for this branch.
-				} else {
-					if _, _, err := s.Bot.Client.CreatePost(context.Background(), &post); err != nil {
-						mlog.Error("Failed to send message to specific Mattermost channel", mlog.String("channelId", targetMattermostChannelID), mlog.Err(err))
-					}
-				}
-			} else {
-				mlog.Warn("Could not find corresponding Mattermost channel for Telegram channel ID", mlog.Any("telegramChannelId", channelId.ChannelID), mlog.String("assumedName", targetChannelName))
-				// Fallback or error: Potentially send to a default channel or log that the specific channel was not found.
-				// For now, it just logs. If general broadcast is desired as fallback, s.Bot.DirectMessage could be called.
-				// However, the requirement is "send back to the same channel".
-			}
-		}
-	} else {
-		mlog.Debug("Unknown channel id", mlog.Any("channelId", channelId.ChannelID))
+// processAlertChannelMessage handles a message of the pikudhaoref_all channel received at now.
+func processAlertChannelMessage(text string, now time.Time, b *bot.Bot) error {
+	if strings.Contains(text, "בדקות הקרובות צפויות להתקבל התרעות באזורך") {
+		// Early alert detected, process with "rockets" category
+		return processMessage(text, district.GetDistricts(), now, b, "rockets")
 	}
-	return nil
+	return processMessage(text, district.GetDistricts(), now, b, "")
 }
 
 func processMessage(text string, districts district.Districts, now time.Time, b *bot.Bot, overrideCategory string) error {
 	dedup := make(map[string]*bot.Message)
 	var dedupOrder []string
+	var unrecognized []unrecognizedCity
 	cities := extractCityNames(text)
 	pubDate := extractPubTime(text)
 
 	isEarlyAlert := overrideCategory == "rockets"
-	err := checkExpired(pubDate, text, now, isEarlyAlert)
+	err := checkExpired(pubDate, text, now, isEarlyAlert, b.Settings().Thresholds)
 	if err != nil {
 		if strings.Contains(text, "האירוע הסתיים") {
 			msg := bot.NewMessage("uav_event_over", "", 0, "")
+			msg.Source = "telegram"
 			b.SubmitMessage(&msg)
 		} else {
 			return err
@@ -218,19 +234,31 @@ func processMessage(text string, districts district.Districts, now time.Time, b
 		instructions = "uav_instructions"
 	}
 	for _, cityName := range cities {
-		districtID := district.GetDistrictByCity(cityName)
-		cityObj := districts["he"][districtID]
+		match := b.MatchCity("telegram", cityName)
+		cityObj := districts["he"][match.ID]
 		msg := bot.NewMessage(instructions, category, cityObj.SafetyBufferSeconds, pubDate)
+		msg.Source = "telegram"
+		msg.Issued = bot.ParsePubDate(pubDate, now)
+		if match.ID == "" {
+			mlog.Warn("district not found", mlog.String("city", cityName), mlog.String("source", "telegram"))
+			unrecognized = append(unrecognized, unrecognizedCity{msg: &msg, name: cityName})
+			continue
+		}
 		hash := msg.GetHash()
 		if _, ok := dedup[hash]; !ok {
 			dedup[hash] = &msg
 			dedupOrder = append(dedupOrder, hash)
 		}
-		msg.AppendDistrict(districtID)
+		dedup[hash].AppendMatch(match)
 	}
+	dedupOrder = groupUnrecognized(dedup, dedupOrder, unrecognized)
 	for _, hash := range dedupOrder {
 		b.SubmitMessage(dedup[hash])
 	}
+	if b.Monitoring.SuccessfulSourceFetches == nil {
+		// replays run without metrics
+		return nil
+	}
 	if len(dedup) > 0 {
 		b.Monitoring.SuccessfulSourceFetches.WithLabelValues("telegram").Inc()
 	} else {
@@ -239,7 +267,7 @@ func processMessage(text string, districts district.Districts, now time.Time, b
 	return nil
 }
 
-func checkExpired(pubDate string, text string, now time.Time, isEarlyAlert bool) error {
+func checkExpired(pubDate string, text string, now time.Time, isEarlyAlert bool, thresholds config.ThresholdsConfig) error {
 	location, _ := time.LoadLocation("Asia/Jerusalem")
 	// currentDate := time.Now().In(location).Format("2006-01-02") // Not needed anymore as date is in pubDate
 	parsedPubDate, err := time.ParseInLocation("02/01/2006 15:04", pubDate, location)
@@ -248,9 +276,9 @@ func checkExpired(pubDate string, text string, now time.Time, isEarlyAlert bool)
 		return err
 	}
 
-	expirationDuration := 90 * time.Second
+	expirationDuration := thresholds.TelegramMaxAge
 	if isEarlyAlert {
-		expirationDuration = 300 * time.Second
+		expirationDuration = thresholds.EarlyWarningMaxAge
 	}
 
 	if parsedPubDate.Add(expirationDuration).Before(now) {
@@ -260,30 +288,54 @@ func checkExpired(pubDate string, text string, now time.Time, isEarlyAlert bool)
 	return nil
 }
 
+// Run keeps the telegram client connected, reconnecting with the source's backoff whenever the connection ends.
+// A failed connection counts as a failed fetch, the source recovers once updates are received again.
 func (s *SourceTelegram) Run() {
+	for {
+		start := time.Now()
+		err := s.connect(start)
+		s.authorized.Store(false)
+		s.gaps.Reset()
+		if err != nil {
+			mlog.Error("telegram run error", mlog.Err(err))
+		}
+		s.Bot.Health.Report("telegram", false, time.Since(start))
+		time.Sleep(s.Bot.Health.Backoff("telegram"))
+	}
+}
+
+// connect runs a new telegram client until its connection ends.
+func (s *SourceTelegram) connect(start time.Time) error {
+	client := telegram.NewClient(s.appID, s.appHash, s.options)
 	flow := auth.NewFlow(examples.Terminal{}, auth.SendCodeOptions{})
 
-	err := s.client.Run(context.Background(), func(ctx context.Context) error {
-		if err := s.client.Auth().IfNecessary(ctx, flow); err != nil {
+	return client.Run(context.Background(), func(ctx context.Context) error {
+		if err := client.Auth().IfNecessary(ctx, flow); err != nil {
 			return errors.Wrap(err, "auth")
 		}
 
 		// Fetch user info.
-		user, err := s.client.Self(ctx)
+		user, err := client.Self(ctx)
 		if err != nil {
 			return errors.Wrap(err, "call self")
 		}
 
-		return s.gaps.Run(ctx, s.client.API(), user.ID, updates.AuthOptions{
+		return s.gaps.Run(ctx, client.API(), user.ID, updates.AuthOptions{
 			OnStart: func(ctx context.Context) {
+				s.authorized.Store(true)
+				s.Bot.Health.Report("telegram", true, time.Since(start))
 				mlog.Info("Telegram gaps message parser started")
 			},
 		})
 	})
-	if err != nil {
-		mlog.Error("telegram run error", mlog.Err(err))
-		//os.Exit(7) // Consider removing os.Exit
+}
+
+// Authorized is the readiness check of telegram, it fails until the client logged in and receives updates.
+func (s *SourceTelegram) Authorized() error {
+	if !s.authorized.Load() {
+		return errors.New("not authorized")
 	}
+	return nil
 }
 
 func extractCityNames(text string) []string {
@@ -0,0 +1,39 @@
+package sources
+
+import (
+	"github.com/phntom/goalert/internal/bot"
+)
+
+// unrecognizedCity is a city name of an alert no district matched, msg is the message it would have been posted
+// in on its own.
+type unrecognizedCity struct {
+	msg  *bot.Message
+	name string
+}
+
+// groupUnrecognized adds the unrecognized cities to the message of the same alert, so they are posted by name along
+// with its other cities. Cities of an alert without any matched city share a message of their own. It returns the
+// order of dedup including the messages added.
+func groupUnrecognized(dedup map[string]*bot.Message, dedupOrder []string, unrecognized []unrecognizedCity) []string {
+	for _, city := range unrecognized {
+		var target *bot.Message
+		for _, hash := range dedupOrder {
+			m := dedup[hash]
+			if m.Category == city.msg.Category && m.Instructions == city.msg.Instructions && m.PubDate == city.msg.PubDate {
+				target = m
+				break
+			}
+		}
+		if target == nil {
+			hash := city.msg.GetHash()
+			dedup[hash] = city.msg
+			dedupOrder = append(dedupOrder, hash)
+			target = city.msg
+		}
+		for rocketID := range city.msg.RocketIDs {
+			target.RocketIDs[rocketID] = true
+		}
+		target.AppendUnrecognized(city.name)
+	}
+	return dedupOrder
+}
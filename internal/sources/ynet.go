@@ -6,8 +6,8 @@ import (
 	"github.com/phntom/goalert/internal/bot"
 	"github.com/phntom/goalert/internal/district"
 	"github.com/phntom/goalert/internal/fetcher"
+	"github.com/phntom/goalert/internal/recording"
 	"net/http"
-	"os"
 	"strings"
 	"time"
 )
@@ -33,19 +33,23 @@ type YnetMessageItemConcrete struct {
 }
 
 type SourceYnet struct {
-	client *http.Client
-	URL    string
-	seen   map[string]bool
-	Bot    *bot.Bot
+	client   *http.Client
+	seen     map[string]bool
+	Bot      *bot.Bot
+	Recorder *recording.Recorder
+	// now resolves the bare pubdate of items, replays set it to the recorded time
+	now func() time.Time
 }
 
 func (s *SourceYnet) Register() {
 	s.client = fetcher.CreateHTTPClient()
 	s.seen = make(map[string]bool)
+	s.Bot.LoadState(ynetSeenStateKey, &s.seen)
 }
 
 func (s *SourceYnet) Fetch() []byte {
-	return fetcher.FetchSource(s.client, s.URL, "ynet", YnetReferrer, &s.Bot.Monitoring)
+	settings := s.Bot.Settings().Sources.Ynet
+	return fetcher.FetchSource(s.client, settings.URL, "ynet", settings.Referrer, &s.Bot.Monitoring, s.Recorder)
 }
 
 func (s *SourceYnet) Parse(content []byte) []*bot.Message {
@@ -65,8 +69,13 @@ func (s *SourceYnet) Parse(content []byte) []*bot.Message {
 		}
 		return nil
 	}
+	now := time.Now()
+	if s.now != nil {
+		now = s.now()
+	}
 	dedup := make(map[string]*bot.Message)
 	var dedupOrder []string
+	var unrecognized []unrecognizedCity
 	txtJson := content[13 : len(content)-2]
 	var alerts YnetMessage
 	err := json.Unmarshal(txtJson, &alerts)
@@ -82,14 +91,6 @@ func (s *SourceYnet) Parse(content []byte) []*bot.Message {
 		if s.seen[item.Item.Guid] {
 			continue
 		}
-		districtID := district.GetDistrictByCity(item.Item.Title)
-		if districtID == "" {
-			mlog.Warn("district not found",
-				mlog.Any("title", item.Item.Title),
-				mlog.Any("source", "ynet"),
-			)
-			continue
-		}
 		category := ""
 		instructions := "instructions"
 		if strings.Contains(item.Item.Description, "אלא אם ניתנה התרעה נוספת") {
@@ -108,16 +109,28 @@ func (s *SourceYnet) Parse(content []byte) []*bot.Message {
 			s.seen[item.Item.Guid] = true
 			continue
 		}
-		cityObj := districts["he"][districtID]
+		match := s.Bot.MatchCity("ynet", item.Item.Title)
+		cityObj := districts["he"][match.ID]
 		msg := bot.NewMessage(instructions, category, cityObj.SafetyBufferSeconds, item.Item.Time)
+		msg.Source = "ynet"
+		msg.Issued = bot.ParsePubDate(item.Item.Time, now)
 		msg.RocketIDs[item.Item.Guid] = true
+		if match.ID == "" {
+			mlog.Warn("district not found",
+				mlog.Any("title", item.Item.Title),
+				mlog.Any("source", "ynet"),
+			)
+			unrecognized = append(unrecognized, unrecognizedCity{msg: &msg, name: item.Item.Title})
+			continue
+		}
 		hash := msg.GetHash()
 		if _, ok := dedup[hash]; !ok {
 			dedup[hash] = &msg
 			dedupOrder = append(dedupOrder, hash)
 		}
-		dedup[hash].AppendDistrict(districtID)
+		dedup[hash].AppendMatch(match)
 	}
+	dedupOrder = groupUnrecognized(dedup, dedupOrder, unrecognized)
 	var result []*bot.Message
 	for _, hash := range dedupOrder {
 		message := dedup[hash]
@@ -130,34 +143,30 @@ func (s *SourceYnet) Parse(content []byte) []*bot.Message {
 }
 
 func (s *SourceYnet) Run() {
-	failed := 0
-	nextQuarterSecond := time.Now()
+	next := time.Now()
 	for {
-		if nextQuarterSecond.After(time.Now()) {
-			// Calculate the duration until the next quarter second
-			durationUntilNextQuarter := time.Until(nextQuarterSecond)
-			// Sleep until the next quarter second
-			time.Sleep(durationUntilNextQuarter)
+		if next.After(time.Now()) {
+			time.Sleep(time.Until(next))
 		}
 
+		start := time.Now()
 		content := s.Fetch()
+		s.Bot.Health.Report("ynet", content != nil, time.Since(start))
 		//content := []byte("jsonCallback({\"alerts\": {\"items\": [{\"item\": {\"guid\": \"f038657b-99e1-48ec-b5c2-3e49c409b3bb\",\"pubdate\": \"11:40\",\"title\": \"בית שקמה\",\"description\": \"היכנסו למרחב המוגן\",\"link\": \"\"}}]}});")
 		if content == nil {
-			failed += 1
-			if failed > 30 {
-				os.Exit(4)
-			}
-			time.Sleep(200 * time.Millisecond)
+			time.Sleep(s.Bot.Health.Backoff("ynet"))
 			continue
 		}
-		failed = 0
+		seenBefore := len(s.seen)
 		for _, m := range s.Parse(content) {
 			mlog.Debug("ynet", mlog.Any("content", string(content)))
 			s.Bot.SubmitMessage(m)
 		}
+		if len(s.seen) != seenBefore {
+			s.Bot.SaveState(ynetSeenStateKey, s.seen)
+		}
 
-		// Calculate the next quarter-second boundary
-		// Add 250ms (a quarter second), then truncate to the nearest quarter second
-		nextQuarterSecond = time.Now().Add(250 * time.Millisecond).Truncate(250 * time.Millisecond)
+		settings := s.Bot.Settings().Sources.Ynet
+		next = nextPoll(time.Now(), settings.PollInterval, settings.PollOffset)
 	}
 }
@@ -0,0 +1,194 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"github.com/phntom/goalert/internal/district"
+	bolt "go.etcd.io/bbolt"
+	"time"
+)
+
+var (
+	// alertsBucket holds AlertRecord JSON keyed by receive time and ID, so cursors walk it chronologically.
+	alertsBucket = []byte("alerts")
+	// alertKeysBucket maps an alert ID to its key in alertsBucket.
+	alertKeysBucket = []byte("alert_keys")
+)
+
+// AlertRecord is a delivered alert as kept in the history.
+type AlertRecord struct {
+	ID           string        `json:"id"`
+	Source       string        `json:"source"`
+	Category     string        `json:"category"`
+	Instructions string        `json:"instructions"`
+	Districts    []district.ID `json:"districts"`
+	RocketIDs    []string      `json:"rocket_ids,omitempty"`
+	PubDate      string        `json:"pubdate"`
+	Received     time.Time     `json:"received"`
+	Posts        int           `json:"posts"`
+	Retracted    bool          `json:"retracted,omitempty"`
+}
+
+// AlertQuery selects history records, zero values do not filter.
+type AlertQuery struct {
+	Districts map[district.ID]bool
+	Since     time.Time
+	Until     time.Time
+	Limit     int
+}
+
+func (q *AlertQuery) matches(r *AlertRecord) bool {
+	if r.Retracted {
+		return false
+	}
+	if q.Districts == nil {
+		return true
+	}
+	for _, id := range r.Districts {
+		if q.Districts[id] {
+			return true
+		}
+	}
+	return false
+}
+
+func recordKey(received time.Time, id string) []byte {
+	key := make([]byte, 8, 8+len(id))
+	binary.BigEndian.PutUint64(key, uint64(received.UnixNano()))
+	return append(key, id...)
+}
+
+// SaveAlert stores the record, replacing an earlier version with the same ID.
+func (db *DB) SaveAlert(r AlertRecord) error {
+	value, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	return db.bolt.Update(func(tx *bolt.Tx) error {
+		keys := tx.Bucket(alertKeysBucket)
+		key := keys.Get([]byte(r.ID))
+		if key == nil {
+			key = recordKey(r.Received, r.ID)
+			if err := keys.Put([]byte(r.ID), key); err != nil {
+				return err
+			}
+		}
+		return tx.Bucket(alertsBucket).Put(key, value)
+	})
+}
+
+// RetractAlert stores the record as retracted. An earlier version keeps everything but its retraction, as the posts
+// it counted are already deleted when the alert is retracted.
+func (db *DB) RetractAlert(r AlertRecord) error {
+	return db.bolt.Update(func(tx *bolt.Tx) error {
+		alerts := tx.Bucket(alertsBucket)
+		key := tx.Bucket(alertKeysBucket).Get([]byte(r.ID))
+		if key == nil {
+			key = recordKey(r.Received, r.ID)
+			if err := tx.Bucket(alertKeysBucket).Put([]byte(r.ID), key); err != nil {
+				return err
+			}
+		} else {
+			var stored AlertRecord
+			if err := json.Unmarshal(alerts.Get(key), &stored); err != nil {
+				return err
+			}
+			r = stored
+		}
+		r.Retracted = true
+		value, err := json.Marshal(r)
+		if err != nil {
+			return err
+		}
+		return alerts.Put(key, value)
+	})
+}
+
+// PruneAlerts deletes the records received before cutoff and returns how many it deleted. bbolt reuses the pages
+// they took up, so pruning regularly keeps the database from growing once it covers the retention.
+func (db *DB) PruneAlerts(cutoff time.Time) (int, error) {
+	var expired [][]byte
+	err := db.bolt.Update(func(tx *bolt.Tx) error {
+		alerts := tx.Bucket(alertsBucket)
+		keys := tx.Bucket(alertKeysBucket)
+		c := alerts.Cursor()
+		end := recordKey(cutoff, "")
+		// deleting under the cursor skips records, the keys are collected first
+		for k, _ := c.First(); k != nil && bytes.Compare(k, end) < 0; k, _ = c.Next() {
+			expired = append(expired, bytes.Clone(k))
+		}
+		for _, k := range expired {
+			// the key is the receive time followed by the ID
+			if err := keys.Delete(k[8:]); err != nil {
+				return err
+			}
+			if err := alerts.Delete(k); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return len(expired), nil
+}
+
+// scan walks the records matching q from the newest, until fn returns false.
+func (db *DB) scan(q AlertQuery, fn func(r *AlertRecord) bool) error {
+	return db.bolt.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(alertsBucket).Cursor()
+		var k, v []byte
+		if q.Until.IsZero() {
+			k, v = c.Last()
+		} else if k, v = c.Seek(recordKey(q.Until, "")); k == nil {
+			k, v = c.Last()
+		} else {
+			k, v = c.Prev()
+		}
+		since := recordKey(q.Since, "")
+		for ; k != nil; k, v = c.Prev() {
+			if !q.Since.IsZero() && bytes.Compare(k, since) < 0 {
+				break
+			}
+			var r AlertRecord
+			if err := json.Unmarshal(v, &r); err != nil {
+				return err
+			}
+			if q.matches(&r) && !fn(&r) {
+				break
+			}
+		}
+		return nil
+	})
+}
+
+// Alerts returns the records matching q, newest first.
+func (db *DB) Alerts(q AlertQuery) ([]AlertRecord, error) {
+	var result []AlertRecord
+	err := db.scan(q, func(r *AlertRecord) bool {
+		result = append(result, *r)
+		return q.Limit <= 0 || len(result) < q.Limit
+	})
+	return result, err
+}
+
+// CountAlerts returns the number of records matching q, ignoring its limit.
+func (db *DB) CountAlerts(q AlertQuery) (int, error) {
+	count := 0
+	err := db.scan(q, func(r *AlertRecord) bool {
+		count++
+		return true
+	})
+	return count, err
+}
+
+// LastAlert returns the most recent alert for any of the districts, nil if there was none.
+func (db *DB) LastAlert(districts map[district.ID]bool) (*AlertRecord, error) {
+	records, err := db.Alerts(AlertQuery{Districts: districts, Limit: 1})
+	if err != nil || len(records) == 0 {
+		return nil, err
+	}
+	return &records[0], nil
+}
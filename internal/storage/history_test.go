@@ -0,0 +1,158 @@
+package storage
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/phntom/goalert/internal/district"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	bolt "go.etcd.io/bbolt"
+)
+
+func openTestDB(t *testing.T) *DB {
+	t.Helper()
+	db, err := Open(filepath.Join(t.TempDir(), "goalert.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		//goland:noinspection GoUnhandledErrorResult
+		db.Close()
+	})
+	return db
+}
+
+func seedHistory(t *testing.T, db *DB, now time.Time) {
+	t.Helper()
+	records := []AlertRecord{
+		{ID: "a", Source: "oref", Category: "rockets", Districts: []district.ID{"999"}, Received: now.Add(-10 * 24 * time.Hour)},
+		{ID: "b", Source: "ynet", Category: "rockets", Districts: []district.ID{"999", "1231"}, Received: now.Add(-2 * time.Hour)},
+		{ID: "c", Source: "oref", Category: "uav", Districts: []district.ID{"241"}, Received: now.Add(-time.Hour)},
+		{ID: "d", Source: "oref", Category: "rockets", Districts: []district.ID{"1333"}, Received: now.Add(-30 * time.Minute)},
+	}
+	for _, r := range records {
+		require.NoError(t, db.SaveAlert(r))
+	}
+}
+
+func TestDB_Alerts(t *testing.T) {
+	db := openTestDB(t)
+	now := time.Now()
+	seedHistory(t, db, now)
+
+	last, err := db.LastAlert(map[district.ID]bool{"999": true})
+	require.NoError(t, err)
+	assert.Equal(t, "b", last.ID)
+
+	last, err = db.LastAlert(map[district.ID]bool{"6004": true})
+	require.NoError(t, err)
+	assert.Nil(t, last)
+
+	count, err := db.CountAlerts(AlertQuery{Districts: district.GetDistrictsByArea("HaAmakim"), Since: now.Add(-7 * 24 * time.Hour)})
+	require.NoError(t, err)
+	assert.Equal(t, 2, count)
+
+	records, err := db.Alerts(AlertQuery{Limit: 2})
+	require.NoError(t, err)
+	if assert.Len(t, records, 2) {
+		assert.Equal(t, "d", records[0].ID)
+		assert.Equal(t, "c", records[1].ID)
+	}
+
+	records, err = db.Alerts(AlertQuery{Until: now.Add(-90 * time.Minute)})
+	require.NoError(t, err)
+	if assert.Len(t, records, 2) {
+		assert.Equal(t, "b", records[0].ID)
+	}
+
+	// updating keeps a single record in its original position
+	require.NoError(t, db.SaveAlert(AlertRecord{ID: "c", Category: "uav", Districts: []district.ID{"241"}, Received: now.Add(-time.Hour), Posts: 3, Retracted: true}))
+	count, err = db.CountAlerts(AlertQuery{})
+	require.NoError(t, err)
+	assert.Equal(t, 3, count)
+}
+
+func TestDB_RetractAlert(t *testing.T) {
+	db := openTestDB(t)
+	now := time.Now()
+	require.NoError(t, db.SaveAlert(AlertRecord{ID: "a", Districts: []district.ID{"999"}, Received: now, Posts: 3}))
+	require.NoError(t, db.RetractAlert(AlertRecord{ID: "a", Districts: []district.ID{"999"}, Received: now}))
+	require.NoError(t, db.RetractAlert(AlertRecord{ID: "b", Districts: []district.ID{"241"}, Received: now}))
+
+	var records []AlertRecord
+	require.NoError(t, db.scan(AlertQuery{}, func(r *AlertRecord) bool {
+		records = append(records, *r)
+		return true
+	}))
+	assert.Empty(t, records, "retracted alerts are not listed")
+	require.NoError(t, db.bolt.View(func(tx *bolt.Tx) error {
+		var r AlertRecord
+		require.NoError(t, json.Unmarshal(tx.Bucket(alertsBucket).Get(recordKey(now, "a")), &r))
+		assert.True(t, r.Retracted)
+		assert.Equal(t, 3, r.Posts, "the posts delivered before the retraction are kept")
+		return nil
+	}))
+}
+
+func TestDB_PruneAlerts(t *testing.T) {
+	db := openTestDB(t)
+	now := time.Now()
+	seedHistory(t, db, now)
+
+	deleted, err := db.PruneAlerts(now.Add(-90 * time.Minute))
+	require.NoError(t, err)
+	assert.Equal(t, 2, deleted)
+	records, err := db.Alerts(AlertQuery{})
+	require.NoError(t, err)
+	require.Len(t, records, 2)
+	assert.Equal(t, "d", records[0].ID)
+	assert.Equal(t, "c", records[1].ID)
+
+	// a pruned alert saved again starts a new record
+	require.NoError(t, db.SaveAlert(AlertRecord{ID: "a", Districts: []district.ID{"999"}, Received: now}))
+	count, err := db.CountAlerts(AlertQuery{})
+	require.NoError(t, err)
+	assert.Equal(t, 3, count)
+
+	deleted, err = db.PruneAlerts(now.Add(-90 * time.Minute))
+	require.NoError(t, err)
+	assert.Zero(t, deleted)
+}
+
+func TestHistoryHandler(t *testing.T) {
+	db := openTestDB(t)
+	seedHistory(t, db, time.Now())
+	server := httptest.NewServer(NewHistoryHandler(db))
+	defer server.Close()
+
+	get := func(path string, query url.Values, body any) int {
+		res, err := http.Get(server.URL + path + "?" + query.Encode())
+		require.NoError(t, err)
+		//goland:noinspection GoUnhandledErrorResult
+		defer res.Body.Close()
+		if res.StatusCode == http.StatusOK && body != nil {
+			require.NoError(t, json.NewDecoder(res.Body).Decode(body))
+		}
+		return res.StatusCode
+	}
+
+	var last AlertRecord
+	assert.Equal(t, http.StatusOK, get("/history/last", url.Values{"city": {"עין חרוד"}}, &last))
+	assert.Equal(t, "b", last.ID)
+
+	var count struct{ Count int }
+	assert.Equal(t, http.StatusOK, get("/history/count", url.Values{"area": {"העמקים"}, "since": {"168h"}}, &count))
+	assert.Equal(t, 2, count.Count)
+
+	var list struct{ Alerts []AlertRecord }
+	assert.Equal(t, http.StatusOK, get("/history", url.Values{"limit": {"3"}}, &list))
+	assert.Len(t, list.Alerts, 3)
+
+	assert.Equal(t, http.StatusBadRequest, get("/history/last", url.Values{}, nil))
+	assert.Equal(t, http.StatusBadRequest, get("/history", url.Values{"city": {"אטלנטיס"}}, nil))
+	assert.Equal(t, http.StatusNotFound, get("/history/last", url.Values{"district": {"6004"}}, nil))
+}
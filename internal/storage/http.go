@@ -0,0 +1,131 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/mattermost/mattermost/server/public/shared/mlog"
+	"github.com/phntom/goalert/internal/district"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+const defaultHistoryLimit = 100
+
+// NewHistoryHandler serves read-only queries over the alert history:
+//
+//	GET /history?city=&district=&area=&since=&until=&limit=  matching alerts, newest first
+//	GET /history/count?city=&district=&area=&since=&until=   number of matching alerts
+//	GET /history/last?city=&district=&area=                  the latest matching alert
+//
+// since and until take RFC 3339 timestamps or a duration back from now, e.g. since=168h.
+func NewHistoryHandler(db *DB) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /history", func(w http.ResponseWriter, r *http.Request) {
+		q, err := parseAlertQuery(r.URL.Query())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if q.Limit <= 0 {
+			q.Limit = defaultHistoryLimit
+		}
+		records, err := db.Alerts(q)
+		writeHistoryResponse(w, map[string]any{"alerts": records}, err)
+	})
+	mux.HandleFunc("GET /history/count", func(w http.ResponseWriter, r *http.Request) {
+		q, err := parseAlertQuery(r.URL.Query())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		count, err := db.CountAlerts(q)
+		writeHistoryResponse(w, map[string]any{"count": count}, err)
+	})
+	mux.HandleFunc("GET /history/last", func(w http.ResponseWriter, r *http.Request) {
+		q, err := parseAlertQuery(r.URL.Query())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if q.Districts == nil {
+			http.Error(w, "city, district or area is required", http.StatusBadRequest)
+			return
+		}
+		record, err := db.LastAlert(q.Districts)
+		if err == nil && record == nil {
+			http.Error(w, "no alerts", http.StatusNotFound)
+			return
+		}
+		writeHistoryResponse(w, record, err)
+	})
+	return mux
+}
+
+func writeHistoryResponse(w http.ResponseWriter, body any, err error) {
+	if err != nil {
+		mlog.Error("failed querying history", mlog.Err(err))
+		http.Error(w, "failed querying history", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	//goland:noinspection GoUnhandledErrorResult
+	json.NewEncoder(w).Encode(body) //nolint:errcheck
+}
+
+func parseAlertQuery(values url.Values) (AlertQuery, error) {
+	var q AlertQuery
+	addDistricts := func(ids map[district.ID]bool) {
+		if q.Districts == nil {
+			q.Districts = make(map[district.ID]bool)
+		}
+		for id := range ids {
+			q.Districts[id] = true
+		}
+	}
+	for _, city := range values["city"] {
+		id := district.GetDistrictByCity(city)
+		if id == "" {
+			return q, errors.New("unknown city " + city)
+		}
+		addDistricts(map[district.ID]bool{id: true})
+	}
+	for _, id := range values["district"] {
+		addDistricts(map[district.ID]bool{district.ID(id): true})
+	}
+	for _, area := range values["area"] {
+		ids := district.GetDistrictsByArea(area)
+		if len(ids) == 0 {
+			return q, errors.New("unknown area " + area)
+		}
+		addDistricts(ids)
+	}
+	var err error
+	if q.Since, err = parseTime(values.Get("since")); err != nil {
+		return q, err
+	}
+	if q.Until, err = parseTime(values.Get("until")); err != nil {
+		return q, err
+	}
+	if limit := values.Get("limit"); limit != "" {
+		if q.Limit, err = strconv.Atoi(limit); err != nil {
+			return q, errors.New("invalid limit " + limit)
+		}
+	}
+	return q, nil
+}
+
+func parseTime(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if d, err := time.ParseDuration(value); err == nil {
+		return time.Now().Add(-d), nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return t, errors.New("invalid time " + value)
+	}
+	return t, nil
+}
@@ -0,0 +1,31 @@
+package storage
+
+import (
+	"encoding/json"
+	bolt "go.etcd.io/bbolt"
+)
+
+// stateBucket holds JSON snapshots of in-memory state, see bot.StateStore.
+var stateBucket = []byte("state")
+
+func (db *DB) SaveState(key string, value any) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return db.bolt.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(stateBucket).Put([]byte(key), data)
+	})
+}
+
+func (db *DB) LoadState(key string, value any) (bool, error) {
+	var data []byte
+	err := db.bolt.View(func(tx *bolt.Tx) error {
+		data = tx.Bucket(stateBucket).Get([]byte(key))
+		if data != nil {
+			return json.Unmarshal(data, value)
+		}
+		return nil
+	})
+	return data != nil, err
+}
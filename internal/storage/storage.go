@@ -0,0 +1,36 @@
+package storage
+
+import (
+	bolt "go.etcd.io/bbolt"
+	"time"
+)
+
+// DB is the embedded database keeping alert history and restart state on local disk.
+type DB struct {
+	bolt *bolt.DB
+}
+
+func Open(path string) (*DB, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, err
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, bucket := range [][]byte{alertsBucket, alertKeysBucket, stateBucket} {
+			if _, err := tx.CreateBucketIfNotExists(bucket); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		//goland:noinspection GoUnhandledErrorResult
+		db.Close()
+		return nil, err
+	}
+	return &DB{bolt: db}, nil
+}
+
+func (db *DB) Close() error {
+	return db.bolt.Close()
+}